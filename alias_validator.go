@@ -0,0 +1,216 @@
+// alias_validator.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// aliasLookupTimeout bounds the address lookup of an ALIAS target outside NetBox.
+const aliasLookupTimeout = 5 * time.Second
+
+// isAliasType reports whether recordType is an ALIAS/ANAME pseudo-record, which
+// servers expand to the target's A/AAAA records instead of serving on the wire.
+func isAliasType(recordType string) bool {
+	switch strings.ToUpper(recordType) {
+	case "ALIAS", "ANAME":
+		return true
+	}
+	return false
+}
+
+// validateAliasRecords checks that every server expands each NetBox ALIAS/ANAME
+// record into the A and AAAA records its target currently resolves to.
+func validateAliasRecords(records []Record, servers []string, logger log.Logger, zoneViewToNameservers map[string][]string, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	var wg sync.WaitGroup
+	var findings findingSet
+
+	for _, record := range records {
+		if !isAliasType(record.Type) {
+			continue
+		}
+
+		recordServers := zoneViewToNameservers[zoneViewKey(record.ZoneName, record.ViewName)]
+		if len(recordServers) == 0 {
+			level.Warn(logger).Log("msg", "No nameservers found for zone in view, skipping validation", "zone", record.ZoneName, "view", record.ViewName)
+			continue
+		}
+
+		wg.Add(1)
+		go func(record Record, recordServers []string) {
+			defer wg.Done()
+
+			discrepancies, successfulValidations := validateAliasRecord(record, recordServers, logger, zoneViewToNameservers, opts)
+			findings.add(discrepancies, successfulValidations, nil)
+		}(record, recordServers)
+	}
+
+	wg.Wait()
+	return findings.discrepancies, findings.successful
+}
+
+// validateAliasRecord resolves the target of one ALIAS record and compares its
+// addresses with the A and AAAA records each server answers for the alias.
+func validateAliasRecord(record Record, servers []string, logger log.Logger, zoneViewToNameservers map[string][]string, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	recordType := strings.ToUpper(record.Type)
+	target := qualifyName(strings.TrimSpace(record.Value), record.ZoneName)
+
+	expected, err := resolveAliasTarget(target, record.ViewName, zoneViewToNameservers, opts)
+	if err != nil {
+		level.Warn(logger).Log("msg", "Could not resolve ALIAS target, skipping validation", "fqdn", record.FQDN, "target", target, "err", err)
+		return nil, nil
+	}
+	if len(expected[dns.TypeA]) == 0 && len(expected[dns.TypeAAAA]) == 0 {
+		level.Warn(logger).Log("msg", "ALIAS target has no addresses", "fqdn", record.FQDN, "target", target)
+		discrepancy := Discrepancy{
+			FQDN:       record.FQDN,
+			RecordType: recordType,
+			ZoneName:   record.ZoneName,
+			Expected:   []string{target},
+			Actual:     []string{},
+			Message:    fmt.Sprintf("%s target %s has no A or AAAA records", recordType, target),
+			Category:   CategoryMissing,
+		}
+		opts.FailFast.record([]Discrepancy{discrepancy})
+		return []Discrepancy{discrepancy}, nil
+	}
+
+	var discrepancies []Discrepancy
+	var successfulValidations []ValidationRecord
+
+	for _, server := range servers {
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			expectedValues := expected[qtype]
+			addressType := dns.TypeToString[qtype]
+
+			level.Debug(logger).Log("msg", "Validating ALIAS expansion", "fqdn", record.FQDN, "type", addressType, "target", target, "server", server)
+			opts.Throttle.acquire(server)
+			actualValues, err := queryAddresses(record.FQDN, qtype, server, opts.Query)
+			opts.Throttle.release(server)
+			if err != nil {
+				level.Warn(logger).Log("msg", "DNS query error", "fqdn", record.FQDN, "server", server, "err", err)
+				discrepancies = append(discrepancies, Discrepancy{
+					FQDN:       record.FQDN,
+					RecordType: recordType,
+					ZoneName:   record.ZoneName,
+					Expected:   expectedValues,
+					Server:     server,
+					Message:    fmt.Sprintf("DNS query error: %v", err),
+					Category:   CategoryQueryError,
+				})
+				continue
+			}
+
+			if stringSlicesEqualUnordered(expectedValues, actualValues) {
+				level.Info(logger).Log("msg", "ALIAS expansion validated successfully", "fqdn", record.FQDN, "type", addressType, "server", server)
+				if opts.RecordSuccessful && len(expectedValues) > 0 {
+					successfulValidations = append(successfulValidations, ValidationRecord{
+						FQDN:       record.FQDN,
+						RecordType: recordType,
+						ZoneName:   record.ZoneName,
+						Expected:   expectedValues,
+						Actual:     actualValues,
+						Server:     server,
+						Message:    fmt.Sprintf("%s to %s expanded correctly to %s records", recordType, target, addressType),
+					})
+				}
+				continue
+			}
+
+			category := CategoryMismatch
+			message := fmt.Sprintf("%s to %s expands to the wrong %s records", recordType, target, addressType)
+			if len(actualValues) == 0 {
+				category = CategoryMissing
+				message = fmt.Sprintf("%s to %s is not expanded to %s records", recordType, target, addressType)
+			}
+			level.Warn(logger).Log("msg", "ALIAS expansion mismatch", "fqdn", record.FQDN, "type", addressType, "target", target, "server", server)
+			discrepancies = append(discrepancies, Discrepancy{
+				FQDN:       record.FQDN,
+				RecordType: recordType,
+				ZoneName:   record.ZoneName,
+				Expected:   expectedValues,
+				Actual:     actualValues,
+				Server:     server,
+				Message:    message,
+				Category:   category,
+			})
+		}
+	}
+
+	tagClientSubnet(discrepancies, opts.Query)
+	opts.FailFast.record(discrepancies)
+	return discrepancies, successfulValidations
+}
+
+// resolveAliasTarget returns the A and AAAA addresses of target, keyed by query
+// type. Targets inside a NetBox zone are asked of that zone's nameservers; other
+// targets are looked up through the system resolver.
+func resolveAliasTarget(target, viewName string, zoneViewToNameservers map[string][]string, opts ValidationOptions) (map[uint16][]string, error) {
+	addresses := make(map[uint16][]string)
+
+	if zoneServers := zoneServersFor(target, viewName, zoneViewToNameservers); len(zoneServers) > 0 {
+		server := zoneServers[0]
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			opts.Throttle.acquire(server)
+			values, err := queryAddresses(target, qtype, server, opts.Query)
+			opts.Throttle.release(server)
+			if err != nil {
+				return nil, err
+			}
+			addresses[qtype] = values
+		}
+		return addresses, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), aliasLookupTimeout)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", strings.TrimSuffix(target, "."))
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return addresses, nil
+		}
+		return nil, err
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			addresses[dns.TypeA] = append(addresses[dns.TypeA], ip.String())
+		} else {
+			addresses[dns.TypeAAAA] = append(addresses[dns.TypeAAAA], ip.String())
+		}
+	}
+	sort.Strings(addresses[dns.TypeA])
+	sort.Strings(addresses[dns.TypeAAAA])
+	return addresses, nil
+}
+
+// queryAddresses returns the addresses of the given type (A or AAAA) that server
+// answers for name. A name that doesn't exist has no addresses.
+func queryAddresses(name string, qtype uint16, server string, opts QueryOptions) ([]string, error) {
+	resp, err := queryDNSWithRetry(dns.Fqdn(name), qtype, server, opts)
+	if err != nil {
+		if resp != nil && resp.Rcode == dns.RcodeNameError {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	values := []string{}
+	for _, ans := range resp.Answer {
+		if ans.Header().Rrtype == qtype {
+			values = append(values, extractRRValue(ans))
+		}
+	}
+	sort.Strings(values)
+	return values, nil
+}
@@ -0,0 +1,124 @@
+// answerpolicy.go
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// Answer policy modes: how the values a server returns are compared with the
+// set NetBox expects.
+const (
+	// AnswerExact requires the answer to equal the expected set.
+	AnswerExact = "exact"
+	// AnswerSubset accepts any part of the expected set, for pools of which a
+	// server returns a rotating selection.
+	AnswerSubset = "subset"
+	// AnswerSuperset requires every expected value but accepts extra ones.
+	AnswerSuperset = "superset"
+)
+
+// AnswerPolicy sets how the answers for names matching an FQDN glob pattern,
+// and optionally a record type, are compared with NetBox. With the subset mode,
+// MinAnswers is the fewest values an answer may hold (default 1). ExpectedCount,
+// if set, is the exact number of records an answer must hold, checked apart
+// from the values, so a backend silently dropped from a pool is reported.
+type AnswerPolicy struct {
+	FQDN          string `mapstructure:"fqdn"`
+	Type          string `mapstructure:"type"`
+	Match         string `mapstructure:"match"`
+	MinAnswers    int    `mapstructure:"min_answers"`
+	ExpectedCount int    `mapstructure:"expected_count"`
+}
+
+// AnswerPolicies are the answer policies from the configuration file, checked
+// in order; the first match wins.
+type AnswerPolicies []AnswerPolicy
+
+// validate checks the patterns and modes of the policies.
+func (p AnswerPolicies) validate() error {
+	for i, policy := range p {
+		if policy.FQDN == "" {
+			return fmt.Errorf("answer policy %d: no fqdn", i+1)
+		}
+		for _, pattern := range []string{policy.FQDN, policy.Type} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("answer policy %d: invalid pattern %q", i+1, pattern)
+			}
+		}
+		switch strings.ToLower(policy.Match) {
+		case "", AnswerExact, AnswerSubset, AnswerSuperset:
+		default:
+			return fmt.Errorf("answer policy %d: invalid match %q (expected %s, %s or %s)", i+1, policy.Match, AnswerExact, AnswerSubset, AnswerSuperset)
+		}
+		if policy.MinAnswers < 0 {
+			return fmt.Errorf("answer policy %d: min_answers must not be negative", i+1)
+		}
+		if policy.ExpectedCount < 0 {
+			return fmt.Errorf("answer policy %d: expected_count must not be negative", i+1)
+		}
+	}
+	return nil
+}
+
+// policyFor returns the first policy matching fqdn and recordType.
+func (p AnswerPolicies) policyFor(fqdn, recordType string) (AnswerPolicy, bool) {
+	for _, policy := range p {
+		if matchIgnorePattern(policy.FQDN, strings.TrimSuffix(fqdn, ".")) && matchIgnorePattern(policy.Type, recordType) {
+			return policy, true
+		}
+	}
+	return AnswerPolicy{}, false
+}
+
+// answerMatches reports whether the actual values a server returned for key
+// satisfy the expected ones under the answer policy for key, falling back to
+// valuesMatch when no policy applies. It also returns the mode applied.
+func (o ValidationOptions) answerMatches(key RecordKey, expected, actual []string) (bool, string) {
+	policy, ok := o.AnswerPolicies.policyFor(key.FQDN, key.RecordType)
+	if !ok {
+		return o.valuesMatch(key.RecordType, expected, actual), AnswerExact
+	}
+
+	mode := strings.ToLower(policy.Match)
+	if mode == "" {
+		mode = AnswerExact
+	}
+	switch mode {
+	case AnswerSubset:
+		minAnswers := policy.MinAnswers
+		if minAnswers == 0 {
+			minAnswers = 1
+		}
+		return len(actual) >= minAnswers && valueSetContains(key.RecordType, actual, expected, o.CaseSensitive), mode
+	case AnswerSuperset:
+		return valueSetContains(key.RecordType, expected, actual, o.CaseSensitive), mode
+	default:
+		return o.valuesMatch(key.RecordType, expected, actual), mode
+	}
+}
+
+// answerCountMismatch reports when the answer policy for key sets an expected
+// count and the server returned a different number of records.
+func (o ValidationOptions) answerCountMismatch(key RecordKey, server string, expected, actual []string, logger log.Logger) (Discrepancy, bool) {
+	policy, ok := o.AnswerPolicies.policyFor(key.FQDN, key.RecordType)
+	if !ok || policy.ExpectedCount == 0 || len(actual) == policy.ExpectedCount {
+		return Discrepancy{}, false
+	}
+
+	level.Warn(logger).Log("msg", "Unexpected number of records in answer", "fqdn", key.FQDN, "type", key.RecordType, "server", server, "expected", policy.ExpectedCount, "actual", len(actual))
+	return Discrepancy{
+		FQDN:       key.FQDN,
+		RecordType: key.RecordType,
+		ZoneName:   key.ZoneName,
+		Expected:   expected,
+		Actual:     actual,
+		Server:     server,
+		Message:    fmt.Sprintf("Answer holds %d %s records, expected %d", len(actual), key.RecordType, policy.ExpectedCount),
+		Category:   CategoryAnswerCount,
+	}, true
+}
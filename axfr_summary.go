@@ -0,0 +1,204 @@
+// axfr_summary.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// AXFRTypeCount tallies the comparison of one record type of a transferred zone.
+type AXFRTypeCount struct {
+	Type       string
+	Matched    int
+	Mismatched int
+	Missing    int
+	Extra      int
+}
+
+// AXFRZoneSummary is the per-type comparison of one transferred zone.
+type AXFRZoneSummary struct {
+	Zone   string
+	Server string
+	Types  []AXFRTypeCount
+}
+
+// axfrSummaries collects the per-zone, per-type results of an AXFR run, so the
+// report can be grouped the way operators read zone contents.
+type axfrSummaries struct {
+	mu        sync.Mutex
+	summaries []AXFRZoneSummary
+}
+
+// newAXFRSummaries returns an empty axfrSummaries.
+func newAXFRSummaries() *axfrSummaries {
+	return &axfrSummaries{}
+}
+
+// add tallies the comparison of a transferred zone by record type. It is safe
+// to call on a nil axfrSummaries, which records nothing.
+func (s *axfrSummaries) add(zoneName, server string, expectedRecordsMap map[string]Record, discrepancies []Discrepancy, extraRecords []MissingRecord, logger log.Logger) {
+	if s == nil {
+		return
+	}
+
+	counts := make(map[string]*AXFRTypeCount)
+	countFor := func(recordType string) *AXFRTypeCount {
+		recordType = strings.ToUpper(recordType)
+		c, ok := counts[recordType]
+		if !ok {
+			c = &AXFRTypeCount{Type: recordType}
+			counts[recordType] = c
+		}
+		return c
+	}
+
+	// Every expected record matched unless a discrepancy says otherwise
+	for _, record := range expectedRecordsMap {
+		if recordInZone(record, zoneName) {
+			countFor(record.Type).Matched++
+		}
+	}
+	for _, d := range discrepancies {
+		switch d.Category {
+		case CategoryMissing:
+			c := countFor(d.RecordType)
+			c.Matched--
+			c.Missing++
+		case CategoryMismatch, CategoryTTLDrift:
+			c := countFor(d.RecordType)
+			c.Matched--
+			c.Mismatched++
+		}
+	}
+	for _, m := range extraRecords {
+		countFor(m.RecordType).Extra++
+	}
+
+	summary := AXFRZoneSummary{Zone: zoneName, Server: server}
+	for _, c := range counts {
+		summary.Types = append(summary.Types, *c)
+	}
+	sort.Slice(summary.Types, func(i, j int) bool { return summary.Types[i].Type < summary.Types[j].Type })
+
+	for _, c := range summary.Types {
+		level.Info(logger).Log("msg", "AXFR comparison", "zone", zoneName, "server", server, "type", c.Type, "matched", c.Matched, "mismatched", c.Mismatched, "missing", c.Missing, "extra", c.Extra)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summaries = append(s.summaries, summary)
+}
+
+// all returns the zone summaries recorded so far, ordered by zone.
+func (s *axfrSummaries) all() []AXFRZoneSummary {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summaries := append([]AXFRZoneSummary(nil), s.summaries...)
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Zone < summaries[j].Zone })
+	return summaries
+}
+
+// sortByZoneAndType orders discrepancies by zone, then record type, then name,
+// keeping the order of the built-in formats in line with the grouped table.
+func sortByZoneAndType(discrepancies []Discrepancy) {
+	sort.SliceStable(discrepancies, func(i, j int) bool {
+		a, b := discrepancies[i], discrepancies[j]
+		if a.ZoneName != b.ZoneName {
+			return a.ZoneName < b.ZoneName
+		}
+		if a.RecordType != b.RecordType {
+			return a.RecordType < b.RecordType
+		}
+		return a.FQDN < b.FQDN
+	})
+}
+
+// generateAXFRReport writes the discrepancies of an AXFR run. The table format
+// groups them by zone, then record type, under each type's match counts, and is
+// written even for a clean run; JSON and CSV keep their flat layout, ordered by
+// zone and type.
+func generateAXFRReport(discrepancies []Discrepancy, summaries []AXFRZoneSummary, reportFile string, reportFormat string, logger log.Logger) error {
+	discrepancies = append([]Discrepancy(nil), discrepancies...)
+	sortByZoneAndType(discrepancies)
+
+	if reportFormat == "json" || reportFormat == "csv" {
+		return generateReport(discrepancies, reportFile, reportFormat, logger)
+	}
+
+	file, err := createReportFile(reportFile)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %v", err)
+	}
+	defer file.Close()
+
+	byZoneType := make(map[string][]Discrepancy)
+	for _, d := range discrepancies {
+		key := d.ZoneName + "|" + strings.ToUpper(d.RecordType)
+		byZoneType[key] = append(byZoneType[key], d)
+	}
+
+	for _, summary := range summaries {
+		fmt.Fprintf(file, "Zone: %s (server %s)\n", summary.Zone, summary.Server)
+		for _, c := range summary.Types {
+			fmt.Fprintf(file, "  %s: %d matched, %d mismatched, %d missing, %d extra\n", c.Type, c.Matched, c.Mismatched, c.Missing, c.Extra)
+		}
+		fmt.Fprintln(file)
+
+		for _, c := range summary.Types {
+			for _, d := range byZoneType[summary.Zone+"|"+c.Type] {
+				writeDiscrepancyEntry(file, d)
+			}
+			delete(byZoneType, summary.Zone+"|"+c.Type)
+		}
+	}
+
+	// Findings outside the transferred zones' record types, such as record counts
+	var rest []Discrepancy
+	for _, d := range discrepancies {
+		if _, ok := byZoneType[d.ZoneName+"|"+strings.ToUpper(d.RecordType)]; ok {
+			rest = append(rest, d)
+		}
+	}
+	if len(rest) > 0 {
+		fmt.Fprintln(file, "Other findings:")
+		fmt.Fprintln(file)
+		for _, d := range rest {
+			writeDiscrepancyEntry(file, d)
+		}
+	}
+
+	level.Debug(logger).Log("msg", "Wrote AXFR report grouped by zone and type", "zones", len(summaries), "discrepancies", len(discrepancies))
+	return nil
+}
+
+// writeDiscrepancyEntry writes one discrepancy in the table report format.
+func writeDiscrepancyEntry(file io.Writer, d Discrepancy) {
+	fmt.Fprintf(file, "FQDN: %s\nZone Name: %s\nType: %s\nExpected: %v\nActual: %v\nExpected TTL: %d\nActual TTL: %d\nServer: %s\nMessage: %s\n",
+		d.FQDN, d.ZoneName, d.RecordType, d.Expected, d.Actual, d.ExpectedTTL, d.ActualTTL, d.Server, d.Message)
+	if d.ClientSubnet != "" {
+		fmt.Fprintf(file, "Client Subnet: %s\n", d.ClientSubnet)
+	}
+	if d.Severity != "" {
+		fmt.Fprintf(file, "Severity: %s (%s)\n", d.Severity, d.Category)
+	}
+	if d.ExtendedError != "" {
+		fmt.Fprintf(file, "Extended Error: %s\n", d.ExtendedError)
+	}
+	if d.ElapsedMs != 0 {
+		fmt.Fprintf(file, "Elapsed: %d ms\n", d.ElapsedMs)
+	}
+	if d.Description != "" {
+		fmt.Fprintf(file, "Description: %s\n", d.Description)
+	}
+	fmt.Fprintln(file)
+}
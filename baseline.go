@@ -0,0 +1,92 @@
+// baseline.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// baselineReport is the JSON discrepancy report of a previous run.
+type baselineReport struct {
+	SchemaVersion int           `json:"SchemaVersion"`
+	Results       []JSONFinding `json:"Results"`
+}
+
+// loadBaseline reads the findings of a previous JSON discrepancy report.
+func loadBaseline(path string) ([]JSONFinding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %v", err)
+	}
+
+	var report baselineReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline: %v", err)
+	}
+	if report.SchemaVersion != reportSchemaVersion {
+		return nil, fmt.Errorf("baseline has report schema version %d, expected %d", report.SchemaVersion, reportSchemaVersion)
+	}
+	return report.Results, nil
+}
+
+// findingKey identifies a finding across runs. Values and messages are left out
+// so a finding whose observed value changes is still the same finding.
+func findingKey(fqdn, recordType, server, clientSubnet, category string) string {
+	return strings.Join([]string{
+		strings.ToLower(strings.TrimSuffix(fqdn, ".")),
+		strings.ToUpper(recordType),
+		server,
+		clientSubnet,
+		category,
+	}, "|")
+}
+
+// diffBaseline splits the current discrepancies into those not present in the
+// baseline, and returns the baseline findings that are no longer reported.
+func diffBaseline(discrepancies []Discrepancy, baseline []JSONFinding) ([]Discrepancy, []Discrepancy) {
+	known := make(map[string]bool)
+	for _, f := range baseline {
+		known[findingKey(f.FQDN, f.RecordType, f.Server, f.ClientSubnet, f.Category)] = true
+	}
+
+	current := make(map[string]bool)
+	var added []Discrepancy
+	for _, d := range discrepancies {
+		key := findingKey(d.FQDN, d.RecordType, d.Server, d.ClientSubnet, d.Category)
+		current[key] = true
+		if !known[key] {
+			added = append(added, d)
+		}
+	}
+
+	var resolved []Discrepancy
+	for _, f := range baseline {
+		key := findingKey(f.FQDN, f.RecordType, f.Server, f.ClientSubnet, f.Category)
+		if current[key] {
+			continue
+		}
+		// Only report a finding once, however often the baseline repeats it
+		current[key] = true
+		resolved = append(resolved, Discrepancy{
+			FQDN:          f.FQDN,
+			RecordType:    f.RecordType,
+			ZoneName:      f.ZoneName,
+			Expected:      f.Expected,
+			Actual:        f.Actual,
+			ExpectedTTL:   f.ExpectedTTL,
+			ActualTTL:     f.ActualTTL,
+			Server:        f.Server,
+			Message:       f.Message,
+			ClientSubnet:  f.ClientSubnet,
+			Category:      f.Category,
+			Severity:      f.Severity,
+			ExtendedError: f.ExtendedError,
+			Description:   f.Description,
+			ElapsedMs:     f.ElapsedMs,
+		})
+	}
+
+	return added, resolved
+}
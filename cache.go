@@ -0,0 +1,160 @@
+// cache.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// cacheServer is the Server reported for findings raised from a resolver cache dump.
+const cacheServer = "cache"
+
+// parseUnboundCacheDump reads the RRset section of an `unbound-control dump_cache`
+// dump. Lines that cannot be parsed as resource records are skipped and counted.
+func parseUnboundCacheDump(r io.Reader) ([]dns.RR, int, error) {
+	var rrs []dns.RR
+	skipped := 0
+	inRRsets := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "START_RRSET_CACHE":
+			inRRsets = true
+			continue
+		case line == "END_RRSET_CACHE":
+			inRRsets = false
+			continue
+		}
+
+		// Skip the message cache, ";rrset" headers and blank lines
+		if !inRRsets || line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		rr, err := dns.NewRR(line)
+		if err != nil || rr == nil {
+			skipped++
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, skipped, err
+	}
+
+	return rrs, skipped, nil
+}
+
+// validateAllRecordsCache validates NetBox records against a resolver cache dump
+// instead of live queries, using the same comparison as AXFR validation.
+func validateAllRecordsCache(
+	records []Record,
+	dumpFile string,
+	logger log.Logger,
+	zoneFilter string,
+	zonesByName map[string]Zone,
+	opts ValidationOptions,
+) ([]Discrepancy, []ValidationRecord, []MissingRecord, error) {
+	file, err := os.Open(dumpFile)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to open cache dump: %v", err)
+	}
+	defer file.Close()
+
+	cachedRRs, skipped, err := parseUnboundCacheDump(file)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read cache dump: %v", err)
+	}
+	level.Info(logger).Log("msg", "Loaded resolver cache dump", "file", dumpFile, "records", len(cachedRRs), "skipped", skipped)
+
+	// Build a map of expected records
+	expectedRecordsMap := make(map[string]Record)
+	for _, record := range records {
+		fqdnType := fmt.Sprintf("%s|%s", record.FQDN, strings.ToUpper(record.Type))
+		expectedRecordsMap[fqdnType] = record
+	}
+
+	var allDiscrepancies []Discrepancy
+	var successfulValidations []ValidationRecord
+	var missingRecords []MissingRecord
+
+	// Assign each cached record to the most specific zone containing it
+	zoneRRs := make(map[string][]dns.RR)
+	for _, rr := range cachedRRs {
+		bestZone := ""
+		for _, zone := range zonesByName {
+			zoneName := zone.Name
+			if zoneFilter != "" && zoneName != zoneFilter {
+				continue
+			}
+			if dns.IsSubDomain(dns.Fqdn(zoneName), rr.Header().Name) && len(zoneName) > len(bestZone) {
+				bestZone = zoneName
+			}
+		}
+		if bestZone != "" {
+			zoneRRs[bestZone] = append(zoneRRs[bestZone], rr)
+		}
+	}
+
+	for zoneName, rrs := range zoneRRs {
+		rrs, delegated := splitDelegatedRRs(zoneName, rrs, records)
+		if delegated > 0 {
+			level.Debug(logger).Log("msg", "Skipped cached records at or below a delegation point", "zone", zoneName, "records", delegated)
+		}
+		discrepancies, validations, missing := compareZoneRecords(zoneName, cacheServer, rrs, expectedRecordsMap, true, logger, opts)
+		opts.FailFast.record(discrepancies)
+		allDiscrepancies = append(allDiscrepancies, discrepancies...)
+		successfulValidations = append(successfulValidations, validations...)
+		missingRecords = append(missingRecords, missing...)
+	}
+
+	return allDiscrepancies, successfulValidations, missingRecords, nil
+}
+
+// splitDelegatedRRs drops the cached records of zoneName owned by a name at or
+// below one of the zone's delegation points, and returns how many it dropped.
+// A resolver caches the child's authoritative data for those names, not the
+// parent's delegation NS records and glue that NetBox holds. Delegation points
+// are the non-apex names with an NS RRset in the cache or in NetBox.
+func splitDelegatedRRs(zoneName string, rrs []dns.RR, records []Record) ([]dns.RR, int) {
+	apex := dns.CanonicalName(zoneName)
+	cuts := make(map[string]bool)
+	for _, rr := range rrs {
+		if name := dns.CanonicalName(rr.Header().Name); rr.Header().Rrtype == dns.TypeNS && name != apex {
+			cuts[name] = true
+		}
+	}
+	for _, record := range records {
+		if name := dns.CanonicalName(record.FQDN); strings.ToUpper(record.Type) == "NS" && name != apex && inZone(name, zoneName) {
+			cuts[name] = true
+		}
+	}
+	if len(cuts) == 0 {
+		return rrs, 0
+	}
+
+	var kept []dns.RR
+	for _, rr := range rrs {
+		delegated := false
+		for cut := range cuts {
+			if dns.IsSubDomain(cut, rr.Header().Name) {
+				delegated = true
+				break
+			}
+		}
+		if !delegated {
+			kept = append(kept, rr)
+		}
+	}
+	return kept, len(rrs) - len(kept)
+}
@@ -0,0 +1,54 @@
+// cache_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+const testCacheDump = `START_RRSET_CACHE
+;rrset 3600 1 0 8 3
+www.example.com.	3600	IN	A	192.0.2.1
+;rrset 3600 1 0 8 3
+sub.example.com.	3600	IN	NS	ns1.sub.example.com.
+;rrset 3600 1 0 8 3
+ns1.sub.example.com.	3600	IN	A	192.0.2.54
+;rrset 3600 1 0 8 3
+host.sub.example.com.	3600	IN	A	192.0.2.9
+;rrset 3600 1 0 8 3
+stale.example.com.	3600	IN	A	192.0.2.99
+END_RRSET_CACHE
+START_MSG_CACHE
+msg www.example.com. IN A 33152 1 3600 0 1 0 0
+END_MSG_CACHE
+EOF
+`
+
+func TestValidateAllRecordsCacheSkipsDelegations(t *testing.T) {
+	dump := filepath.Join(t.TempDir(), "cache.txt")
+	if err := os.WriteFile(dump, []byte(testCacheDump), 0644); err != nil {
+		t.Fatal(err)
+	}
+	zonesByName := map[string]Zone{zoneViewKey("example.com", "default"): {Name: "example.com", DefaultTTL: 3600}}
+	records := []Record{
+		testRecord("www", "A", "192.0.2.1", 3600),
+		// The delegation and its glue, which the child zone's servers answer differently for
+		testRecord("sub", "NS", "ns1.sub", 3600),
+		testRecord("ns1.sub", "A", "192.0.2.53", 3600),
+	}
+
+	discrepancies, _, extra, err := validateAllRecordsCache(records, dump, log.NewNopLogger(), "", zonesByName, ValidationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discrepancies) != 0 {
+		t.Errorf("got findings %+v, want none below the delegation", discrepancies)
+	}
+	// Only the record above the delegation point is extra
+	if len(extra) != 1 || extra[0].FQDN != "stale.example.com." {
+		t.Errorf("got extra records %+v, want only stale.example.com.", extra)
+	}
+}
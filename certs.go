@@ -0,0 +1,47 @@
+// certs.go
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// normalizeCERTValue renders a CERT value as "type keytag algorithm certificate"
+// with a numeric type and algorithm, so "PKIX 0 RSASHA256 ..." and
+// "1 0 8 ..." compare equal, and joins a certificate split across fields.
+func normalizeCERTValue(value string) string {
+	parts := strings.Fields(value)
+	if len(parts) < 4 {
+		return strings.Join(parts, " ")
+	}
+
+	certType := parts[0]
+	if t, ok := dns.StringToCertType[strings.ToUpper(certType)]; ok {
+		certType = strconv.Itoa(int(t))
+	}
+	algorithm := parts[2]
+	if a, ok := dns.StringToAlgorithm[strings.ToUpper(algorithm)]; ok {
+		algorithm = strconv.Itoa(int(a))
+	}
+	return fmt.Sprintf("%s %s %s %s", certType, parts[1], algorithm, strings.Join(parts[3:], ""))
+}
+
+// normalizeCertAssociationValue renders a TLSA or SMIMEA value as "usage selector
+// matching-type data", with the hex certificate association data upper-cased
+// and joined across fields.
+func normalizeCertAssociationValue(value string) string {
+	parts := strings.Fields(value)
+	if len(parts) < 4 {
+		return strings.Join(parts, " ")
+	}
+	return fmt.Sprintf("%s %s %s %s", parts[0], parts[1], parts[2], strings.ToUpper(strings.Join(parts[3:], "")))
+}
+
+// normalizeBase64Value joins base64 data split across fields, as OPENPGPKEY
+// keys often are in zone files.
+func normalizeBase64Value(value string) string {
+	return strings.Join(strings.Fields(value), "")
+}
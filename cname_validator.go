@@ -0,0 +1,250 @@
+// cname_validator.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// maxCNAMEChain is the number of CNAMEs a chain may hold before it is reported
+// as too long. Resolvers give up after about as many and answer SERVFAIL.
+const maxCNAMEChain = 8
+
+// cnameLookupTimeout bounds the address lookup of a CNAME target outside NetBox.
+const cnameLookupTimeout = 5 * time.Second
+
+// cnameTarget is a CNAME target as seen from one view.
+type cnameTarget struct {
+	Target   string
+	ViewName string
+}
+
+// validateCNAMETargets reports CNAME records whose target does not resolve, and
+// chains of CNAMEs that loop or run longer than maxCNAMEChain. Targets inside a
+// NetBox zone are queried on that zone's nameservers; other targets are looked
+// up through the system resolver.
+func validateCNAMETargets(records []Record, servers []string, logger log.Logger, zoneViewToNameservers map[string][]string, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	// Each target is resolved once, however many records point at it
+	owners := make(map[cnameTarget][]Record)
+	for _, record := range records {
+		if strings.ToUpper(record.Type) != "CNAME" {
+			continue
+		}
+		target := cnameTarget{
+			Target:   strings.ToLower(normalizeExpectedValue("CNAME", record.Value, record.ZoneName)),
+			ViewName: record.ViewName,
+		}
+		owners[target] = append(owners[target], record)
+	}
+
+	var wg sync.WaitGroup
+	var findings findingSet
+
+	for target, targetOwners := range owners {
+		wg.Add(1)
+		go func(target cnameTarget, targetOwners []Record) {
+			defer wg.Done()
+
+			var discrepancies []Discrepancy
+			var successful []ValidationRecord
+			chain := followCNAMEChain(target, zoneViewToNameservers, opts)
+			reason, server := "", ""
+			var err error
+			if !chainLoops(chain) && len(chain) <= maxCNAMEChain {
+				reason, server, err = danglingReason(target, zoneViewToNameservers, opts)
+			}
+			for _, record := range targetOwners {
+				if d, ok := cnameLoop(record, chain); ok {
+					level.Warn(logger).Log("msg", "CNAME loop", "fqdn", record.FQDN, "reason", d.Message)
+					discrepancies = append(discrepancies, d)
+					continue
+				}
+				if err != nil {
+					level.Warn(logger).Log("msg", "Could not resolve CNAME target", "fqdn", record.FQDN, "target", target.Target, "err", err)
+					discrepancies = append(discrepancies, Discrepancy{
+						FQDN:       record.FQDN,
+						RecordType: "CNAME",
+						ZoneName:   record.ZoneName,
+						Expected:   []string{target.Target},
+						Server:     server,
+						Message:    fmt.Sprintf("Could not resolve CNAME target %s: %v", target.Target, err),
+						Category:   CategoryQueryError,
+					})
+					continue
+				}
+				if reason == "" {
+					level.Debug(logger).Log("msg", "CNAME target resolves", "fqdn", record.FQDN, "target", target.Target)
+					if opts.RecordSuccessful {
+						successful = append(successful, ValidationRecord{
+							FQDN:       record.FQDN,
+							RecordType: "CNAME",
+							ZoneName:   record.ZoneName,
+							Expected:   []string{target.Target},
+							Server:     server,
+							Message:    "CNAME target resolves",
+						})
+					}
+					continue
+				}
+
+				level.Warn(logger).Log("msg", "Dangling CNAME", "fqdn", record.FQDN, "target", target.Target, "reason", reason)
+				discrepancies = append(discrepancies, Discrepancy{
+					FQDN:       record.FQDN,
+					RecordType: "CNAME",
+					ZoneName:   record.ZoneName,
+					Expected:   []string{target.Target},
+					Server:     server,
+					Message:    fmt.Sprintf("Dangling CNAME: target %s %s", target.Target, reason),
+					Category:   CategoryDanglingCNAME,
+				})
+			}
+			findings.add(discrepancies, successful, nil)
+		}(target, targetOwners)
+	}
+
+	wg.Wait()
+
+	tagClientSubnet(findings.discrepancies, opts.Query)
+	opts.FailFast.record(findings.discrepancies)
+	return findings.discrepancies, findings.successful
+}
+
+// followCNAMEChain returns the names reached from target by following CNAMEs,
+// starting with target itself. It stops at a name without a CNAME, at a name
+// outside the NetBox zones, at the first name seen twice, or once the chain is
+// longer than maxCNAMEChain.
+func followCNAMEChain(target cnameTarget, zoneViewToNameservers map[string][]string, opts ValidationOptions) []string {
+	chain := []string{target.Target}
+	seen := map[string]bool{target.Target: true}
+	for len(chain) <= maxCNAMEChain {
+		next := nextCNAME(chain[len(chain)-1], target.ViewName, zoneViewToNameservers, opts)
+		if next == "" {
+			break
+		}
+		chain = append(chain, next)
+		if seen[next] {
+			break
+		}
+		seen[next] = true
+	}
+	return chain
+}
+
+// nextCNAME returns the target of the CNAME at name, asked of the first server
+// of name's NetBox zone that answers, or "" if there is none.
+func nextCNAME(name, viewName string, zoneViewToNameservers map[string][]string, opts ValidationOptions) string {
+	for _, server := range zoneServersFor(name, viewName, zoneViewToNameservers) {
+		opts.Throttle.acquire(server)
+		resp, _ := queryDNSWithRetry(name, dns.TypeCNAME, server, opts.Query)
+		opts.Throttle.release(server)
+		if resp == nil {
+			continue
+		}
+		for _, rr := range resp.Answer {
+			if cname, ok := rr.(*dns.CNAME); ok && strings.EqualFold(cname.Hdr.Name, name) {
+				return strings.ToLower(dns.Fqdn(cname.Target))
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// chainLoops reports whether a name appears twice in chain.
+func chainLoops(chain []string) bool {
+	seen := make(map[string]bool)
+	for _, name := range chain {
+		if seen[name] {
+			return true
+		}
+		seen[name] = true
+	}
+	return false
+}
+
+// cnameLoop returns a cname_loop discrepancy for record if the chain starting
+// at it, followed by chain, loops or holds more than maxCNAMEChain CNAMEs. The
+// chain is cut after the first name seen twice.
+func cnameLoop(record Record, chain []string) (Discrepancy, bool) {
+	owner := strings.ToLower(dns.Fqdn(record.FQDN))
+	full := []string{owner}
+	seen := map[string]bool{owner: true}
+	loops := false
+	for _, name := range chain {
+		full = append(full, name)
+		if seen[name] {
+			loops = true
+			break
+		}
+		seen[name] = true
+	}
+
+	// Every name but the last holds a CNAME
+	var message string
+	switch {
+	case loops:
+		message = fmt.Sprintf("CNAME loop: %s", strings.Join(full, " -> "))
+	case len(full)-1 > maxCNAMEChain:
+		message = fmt.Sprintf("CNAME chain longer than %d: %s", maxCNAMEChain, strings.Join(full, " -> "))
+	default:
+		return Discrepancy{}, false
+	}
+	return Discrepancy{
+		FQDN:       record.FQDN,
+		RecordType: "CNAME",
+		ZoneName:   record.ZoneName,
+		Expected:   []string{chain[0]},
+		Actual:     []string{strings.Join(full, " -> ")},
+		Message:    message,
+		Category:   CategoryCNAMELoop,
+	}, true
+}
+
+// danglingReason explains why target does not resolve, or returns "" if it
+// does. It also returns the server that was asked, if any. A target resolves
+// when a server answers NOERROR, with or without records of the type asked
+// for; if no server answers NOERROR or NXDOMAIN, the last failure is returned
+// instead.
+func danglingReason(target cnameTarget, zoneViewToNameservers map[string][]string, opts ValidationOptions) (string, string, error) {
+	zoneServers := zoneServersFor(target.Target, target.ViewName, zoneViewToNameservers)
+	if len(zoneServers) > 0 {
+		// Ask the target zone's first server that answers
+		var lastErr error
+		var lastServer string
+		for _, server := range zoneServers {
+			opts.Throttle.acquire(server)
+			resp, err := queryDNSWithRetry(target.Target, dns.TypeA, server, opts.Query)
+			opts.Throttle.release(server)
+			if resp == nil || (resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError) {
+				lastErr, lastServer = lookupFailure(resp, err), server
+				continue
+			}
+			if resp.Rcode == dns.RcodeNameError {
+				return "does not exist (NXDOMAIN)", server, nil
+			}
+			return "", server, nil
+		}
+		return "", lastServer, lastErr
+	}
+
+	// Targets outside NetBox are resolved like any client would
+	ctx, cancel := context.WithTimeout(opts.Query.context(), cnameLookupTimeout)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, strings.TrimSuffix(target.Target, ".")); err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return "does not resolve", "", nil
+		}
+		return "", "", err
+	}
+	return "", "", nil
+}
@@ -0,0 +1,61 @@
+// cname_validator_test.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/miekg/dns"
+)
+
+func TestValidateCNAMETargets(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		rcodes map[uint16]int
+		want   []string
+	}{
+		// NODATA for A: the target exists, just not with an address
+		{"target without address", "web", nil, nil},
+		{"target missing", "gone", nil, []string{CategoryDanglingCNAME}},
+		// A target the server didn't answer for may well exist
+		{"A SERVFAIL", "web", map[uint16]int{dns.TypeA: dns.RcodeServerFailure}, []string{CategoryQueryError}},
+		{"A REFUSED", "web", map[uint16]int{dns.TypeA: dns.RcodeRefused}, []string{CategoryQueryError}},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := fmt.Sprintf("127.0.56.%d", i+1)
+			zone := newTestZone(t, "example.com", "web 3600 IN TXT exists")
+			zone.rcodes = tt.rcodes
+			serveZone(t, addr, zone)
+
+			opts := ValidationOptions{Query: QueryOptions{Retries: 1}}
+			zoneViewToNameservers := map[string][]string{zoneViewKey("example.com", "default"): {addr}}
+			var got []Discrepancy
+			finishWithin(t, 10*time.Second, func() {
+				got, _ = validateCNAMETargets([]Record{testRecord("www", "CNAME", tt.target, 3600)}, []string{addr}, log.NewNopLogger(), zoneViewToNameservers, opts)
+			})
+			if len(got) != len(tt.want) || (len(got) == 1 && got[0].Category != tt.want[0]) {
+				t.Errorf("got findings %v, want %v", categories(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestDanglingReasonCancelled(t *testing.T) {
+	// A run stopped by --fail-fast doesn't wait on the system resolver
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts := ValidationOptions{Query: QueryOptions{Context: ctx}}
+	target := cnameTarget{Target: "host.example.net.", ViewName: "default"}
+
+	finishWithin(t, time.Second, func() {
+		if reason, _, err := danglingReason(target, nil, opts); err == nil {
+			t.Errorf("got reason %q and no error for a cancelled lookup of a CNAME target", reason)
+		}
+	})
+}
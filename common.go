@@ -2,7 +2,13 @@
 package main
 
 import (
+	"fmt"
 	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
 )
 
 // Discrepancy represents a mismatch between expected and actual DNS records.
@@ -16,6 +22,20 @@ type Discrepancy struct {
 	ActualTTL   int         `json:"ActualTTL"`
 	Server      string      `json:"Server"`
 	Message     string      `json:"Message,omitempty"`
+	// ClientSubnet is the EDNS client subnet the query was issued with, if any.
+	ClientSubnet string `json:"ClientSubnet,omitempty"`
+	// Category classifies the finding, e.g. "nxdomain" or "ttl_drift".
+	Category string `json:"Category,omitempty"`
+	// Severity is assigned from Category by the severity rules.
+	Severity string `json:"Severity,omitempty"`
+	// ExtendedError holds the Extended DNS Errors the server returned, if any.
+	ExtendedError string `json:"ExtendedError,omitempty"`
+	// Description is the NetBox description of the records involved, which
+	// often names their owner or purpose.
+	Description string `json:"Description,omitempty"`
+	// ElapsedMs is how long the server took to answer, retries included, for
+	// findings from a query.
+	ElapsedMs int64 `json:"ElapsedMs,omitempty"`
 }
 
 // ValidationRecord represents a successful validation of DNS records.
@@ -29,6 +49,144 @@ type ValidationRecord struct {
 	ActualTTL   int         `json:"ActualTTL"`
 	Server      string      `json:"Server"`
 	Message     string      `json:"Message,omitempty"`
+	// NearMiss marks a validation that passed only because the TTL drift was within tolerance.
+	NearMiss bool `json:"NearMiss,omitempty"`
+	// ElapsedMs is how long the server took to answer, retries included.
+	ElapsedMs int64 `json:"ElapsedMs,omitempty"`
+}
+
+// findingSet collects what concurrent validators report. A validator may report
+// any number of findings per record and server, so they are appended as they
+// come rather than sent into a channel sized for one each.
+type findingSet struct {
+	mu            sync.Mutex
+	discrepancies []Discrepancy
+	successful    []ValidationRecord
+	missing       []MissingRecord
+}
+
+// add appends the findings of one validator.
+func (s *findingSet) add(discrepancies []Discrepancy, successful []ValidationRecord, missing []MissingRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.discrepancies = append(s.discrepancies, discrepancies...)
+	s.successful = append(s.successful, successful...)
+	s.missing = append(s.missing, missing...)
+}
+
+// ValidationOptions holds the run-wide settings shared by the validators.
+type ValidationOptions struct {
+	IgnoreSerialNumbers bool
+	RecordSuccessful    bool
+	// NSApexTTLFromSOA makes apex NS records without their own TTL expect the zone's SOA TTL.
+	NSApexTTLFromSOA bool
+	Throttle         *serverThrottle
+	Query            QueryOptions
+	Denylist         Denylist
+	// MaxConcurrency caps how many SOA records are validated at once.
+	MaxConcurrency int
+	// TTLTolerance is the TTL drift, in seconds, still accepted as a match.
+	TTLTolerance int
+	// RecordNearMisses records validations that passed within TTLTolerance but
+	// not with an exact TTL, even when RecordSuccessful is off.
+	RecordNearMisses bool
+	// SerialLagTolerance is how far apart the SOA serials of a zone's servers may be.
+	SerialLagTolerance int
+	// CaseSensitive compares the values of every record type exactly, including host names.
+	CaseSensitive bool
+	// CheckSOAMName checks that the SOA MName host resolves and answers authoritatively.
+	CheckSOAMName bool
+	// ConfirmOverTCP repeats queries whose UDP answer produced a discrepancy over
+	// TCP and only reports the discrepancy if it persists.
+	ConfirmOverTCP bool
+	// FailFast, when set, cancels the run on the first discrepancy found.
+	FailFast *failFast
+	// ExactKeySets requires DS and DNSKEY sets to equal NetBox's, rather than
+	// merely contain every expected key.
+	ExactKeySets bool
+	// HiddenPrimary is a primary server not listed in NetBox whose SOA serial the
+	// zone's servers are compared with during SOA validation.
+	HiddenPrimary string
+	// HiddenPrimaryTolerance is how far the servers' serials may trail the hidden primary's.
+	HiddenPrimaryTolerance int
+	// RecordCounts, when set, compares each zone's NetBox and AXFR record counts.
+	RecordCounts *recordCounts
+	// AXFRSummaries, when set, tallies each transferred zone's results by record type.
+	AXFRSummaries *axfrSummaries
+	// SerialTable, when set, collects each zone's SOA serials for the serial report.
+	SerialTable *serialTable
+	// Comparisons, when set, receives every expected-vs-actual comparison made.
+	Comparisons *comparisonDump
+	// FQDNFilter, when set, limits validation to records whose FQDN matches it.
+	FQDNFilter *fqdnFilter
+	// Transport, when set, picks UDP or TCP for each query by record type and
+	// expected answer size.
+	Transport *transportSelector
+	// CheckNSEC verifies the NSEC or NSEC3 chain of every transferred zone.
+	CheckNSEC bool
+	// CrossCheckSample is the percentage of transferred RRsets queried directly
+	// to confirm the server answers them as it transfers them; 0 disables it.
+	CrossCheckSample int
+	// CheckRRsetTTLs reports RRsets whose records have different TTLs in NetBox.
+	CheckRRsetTTLs bool
+	// AnswerPolicies relax the comparison of answers for pools of records, such
+	// as round-robin names of which servers return a rotating subset.
+	AnswerPolicies AnswerPolicies
+	// DMARCAware compares DMARC TXT records as sets of tags rather than as strings.
+	DMARCAware bool
+	// WarnOnly logs every finding at warn level, for read-only monitoring.
+	WarnOnly bool
+}
+
+// severeFindingLogger returns logger leveled for findings that are normally
+// logged as errors, demoted to warnings in warn-only runs.
+func (o ValidationOptions) severeFindingLogger(logger log.Logger) log.Logger {
+	if o.WarnOnly {
+		return level.Warn(logger)
+	}
+	return level.Error(logger)
+}
+
+// ttlWithinTolerance reports whether actual differs from expected by at most tolerance seconds.
+func ttlWithinTolerance(expected, actual, tolerance int) bool {
+	diff := expected - actual
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= tolerance
+}
+
+// tagClientSubnet records the client subnet used for the queries behind each discrepancy.
+func tagClientSubnet(discrepancies []Discrepancy, opts QueryOptions) {
+	subnet := opts.clientSubnetString()
+	for i := range discrepancies {
+		discrepancies[i].ClientSubnet = subnet
+	}
+}
+
+// describeDiscrepancies sets the NetBox description of the records behind each
+// discrepancy that doesn't have one yet.
+func describeDiscrepancies(discrepancies []Discrepancy, description string) {
+	for i := range discrepancies {
+		if discrepancies[i].Description == "" {
+			discrepancies[i].Description = description
+		}
+	}
+}
+
+// recordDescriptions joins the distinct descriptions of records, in order.
+func recordDescriptions(records []Record) string {
+	var descriptions []string
+	seen := make(map[string]bool)
+	for _, record := range records {
+		description := strings.TrimSpace(record.Description)
+		if description == "" || seen[description] {
+			continue
+		}
+		seen[description] = true
+		descriptions = append(descriptions, description)
+	}
+	return strings.Join(descriptions, "; ")
 }
 
 // RecordKey is used to group records by FQDN and RecordType.
@@ -67,6 +225,91 @@ func stringSlicesEqualUnordered(a, b []string) bool {
 	return true
 }
 
+// caseInsensitiveTypes are the record types whose values are host names,
+// addresses or hex digests, where case carries no meaning. Values of every other
+// type, such as TXT or SSHFP, are compared exactly.
+var caseInsensitiveTypes = map[string]bool{
+	"A":     true,
+	"AAAA":  true,
+	"CNAME": true,
+	"DNAME": true,
+	"DS":    true,
+	"MX":    true,
+	"NS":    true,
+	"PTR":   true,
+	"SRV":   true,
+}
+
+// comparisonKey returns the form in which a value of recordType is compared.
+// Unless caseSensitive is set, values of case-insensitive types are lower-cased.
+func comparisonKey(recordType, value string, caseSensitive bool) string {
+	value = strings.TrimSpace(value)
+	if !caseSensitive && caseInsensitiveTypes[strings.ToUpper(recordType)] {
+		return strings.ToLower(value)
+	}
+	return value
+}
+
+// valueSetsEqual reports whether the expected and actual values of recordType
+// match, regardless of order.
+func valueSetsEqual(recordType string, expected, actual []string, caseSensitive bool) bool {
+	expectedKeys := make([]string, 0, len(expected))
+	for _, value := range expected {
+		expectedKeys = append(expectedKeys, comparisonKey(recordType, value, caseSensitive))
+	}
+	actualKeys := make([]string, 0, len(actual))
+	for _, value := range actual {
+		actualKeys = append(actualKeys, comparisonKey(recordType, value, caseSensitive))
+	}
+	return stringSlicesEqualUnordered(expectedKeys, actualKeys)
+}
+
+// rolloverTypes are the DNSSEC key types whose sets legitimately hold both the
+// old and the new keys while a rollover is in progress.
+var rolloverTypes = map[string]bool{
+	"DS":     true,
+	"DNSKEY": true,
+}
+
+// valueSetContains reports whether every expected value of recordType is among
+// the actual values.
+func valueSetContains(recordType string, expected, actual []string, caseSensitive bool) bool {
+	actualKeys := make(map[string]int)
+	for _, value := range actual {
+		actualKeys[comparisonKey(recordType, value, caseSensitive)]++
+	}
+	for _, value := range expected {
+		key := comparisonKey(recordType, value, caseSensitive)
+		if actualKeys[key] == 0 {
+			return false
+		}
+		actualKeys[key]--
+	}
+	return true
+}
+
+// valuesMatch reports whether the actual values of recordType satisfy the
+// expected ones. Key sets only need to contain the expected keys unless
+// ExactKeySets is set, so a rollover in progress doesn't flag.
+func (o ValidationOptions) valuesMatch(recordType string, expected, actual []string) bool {
+	if o.DMARCAware && recordType == "TXT" {
+		expected, actual = dmarcComparisonValues(expected), dmarcComparisonValues(actual)
+	}
+	if rolloverTypes[recordType] && !o.ExactKeySets {
+		return valueSetContains(recordType, expected, actual, o.CaseSensitive)
+	}
+	return valueSetsEqual(recordType, expected, actual, o.CaseSensitive)
+}
+
+// dmarcComparisonValues applies dmarcComparisonValue to each value.
+func dmarcComparisonValues(values []string) []string {
+	compared := make([]string, 0, len(values))
+	for _, value := range values {
+		compared = append(compared, dmarcComparisonValue(value))
+	}
+	return compared
+}
+
 // Helper function to check if a string exists in a slice.
 func stringInSlice(str string, list []string) bool {
 	for _, v := range list {
@@ -86,3 +329,168 @@ func getParentZoneName(zoneName string) string {
 	}
 	return ""
 }
+
+// zoneViewKey builds the key used to map a zone within a view to its nameservers.
+func zoneViewKey(zoneName, viewName string) string {
+	return fmt.Sprintf("%s|%s", zoneName, viewName)
+}
+
+// buildZoneViewNameservers creates a mapping of (zone, view) to the names of the
+// nameservers serving that zone.
+func buildZoneViewNameservers(nameservers []Nameserver, logger log.Logger) map[string][]string {
+	zoneViewToNameservers := make(map[string][]string)
+	for _, ns := range nameservers {
+		for _, zone := range ns.Zones {
+			if zone.View != nil {
+				key := zoneViewKey(zone.Name, zone.View.Name)
+				zoneViewToNameservers[key] = append(zoneViewToNameservers[key], ns.Name)
+			} else {
+				level.Warn(logger).Log("msg", "Zone has no associated view", "zone", zone.Name)
+			}
+		}
+	}
+	return zoneViewToNameservers
+}
+
+// findParentZoneServers walks up from the parent of fqdn until it finds a zone in
+// the given view that has nameservers, returning that zone's name and servers.
+func findParentZoneServers(fqdn, viewName string, zoneViewToNameservers map[string][]string) (string, []string) {
+	name := getParentZoneName(strings.TrimSuffix(fqdn, "."))
+	for name != "" {
+		if servers := zoneViewToNameservers[zoneViewKey(name, viewName)]; len(servers) > 0 {
+			return name, servers
+		}
+		name = getParentZoneName(name)
+	}
+	return "", nil
+}
+
+// zoneServersFor returns the nameservers of the NetBox zone in the given view
+// that holds name, which may be the zone apex itself.
+func zoneServersFor(name, viewName string, zoneViewToNameservers map[string][]string) []string {
+	if servers := zoneViewToNameservers[zoneViewKey(strings.TrimSuffix(name, "."), viewName)]; len(servers) > 0 {
+		return servers
+	}
+	_, servers := findParentZoneServers(name, viewName, zoneViewToNameservers)
+	return servers
+}
+
+// prepareRecords fills in the zone default and SOA TTLs of each record from
+// zonesMap and, unless includeInactive is set, drops records NetBox isn't
+// publishing. With apexOnly, only the records at each zone apex are kept. It
+// also returns the unpublished records it dropped that passed every other
+// filter, for checking that they are absent from DNS.
+func prepareRecords(records []Record, zonesMap map[int]Zone, includeInactive, apexOnly, skipManaged, onlyManaged bool, logger log.Logger) ([]Record, []Record) {
+	// Assign ZoneDefaultTTL and SoaTTL to each record
+	for i := range records {
+		record := &records[i]
+		if record.Zone != nil {
+			if zone, ok := zonesMap[record.Zone.ID]; ok {
+				record.ZoneDefaultTTL = zone.DefaultTTL
+				// Update the Zone struct in the record to include SoaTTL
+				record.Zone.SoaTTL = zone.SoaTTL
+			} else {
+				level.Warn(logger).Log("msg", "Zone not found in zones map", "zone_id", record.Zone.ID)
+			}
+		}
+	}
+
+	if apexOnly {
+		records = filterApexRecords(records)
+	}
+
+	// Managed PTRs are derived from address records, whose findings they'd repeat
+	if skipManaged || onlyManaged {
+		var skipped int
+		records, skipped = filterManagedRecords(records, onlyManaged)
+		if skipped > 0 {
+			level.Info(logger).Log("msg", "Skipping records by managed state", "only_managed", onlyManaged, "count", skipped)
+		}
+	}
+
+	// Skip records NetBox isn't publishing unless asked to include them
+	var inactive []Record
+	if !includeInactive {
+		records, inactive = splitInactiveRecords(records)
+		if len(inactive) > 0 {
+			level.Info(logger).Log("msg", "Skipping inactive records", "count", len(inactive))
+		}
+	}
+
+	return records, inactive
+}
+
+// filterManagedRecords keeps the records NetBox manages itself if managed is
+// true. Otherwise it drops the PTRs NetBox generates for address records and
+// keeps everything else, including the SOA and NS records NetBox manages for
+// each zone, which nothing else validates.
+func filterManagedRecords(records []Record, managed bool) ([]Record, int) {
+	var kept []Record
+	skipped := 0
+	for _, record := range records {
+		derived := record.Managed && strings.ToUpper(record.Type) == "PTR"
+		if (managed && !record.Managed) || (!managed && derived) {
+			skipped++
+			continue
+		}
+		kept = append(kept, record)
+	}
+	return kept, skipped
+}
+
+// filterApexRecords keeps only the records at a zone apex, of any type.
+func filterApexRecords(records []Record) []Record {
+	var apex []Record
+	for _, record := range records {
+		if isApexRecord(record) {
+			apex = append(apex, record)
+		}
+	}
+	return apex
+}
+
+// isApexRecord reports whether a record sits at the apex of its zone.
+func isApexRecord(record Record) bool {
+	if record.Name == "@" || record.Name == "" {
+		return true
+	}
+	return record.ZoneName != "" && strings.EqualFold(dns.Fqdn(record.FQDN), dns.Fqdn(record.ZoneName))
+}
+
+// splitInactiveRecords separates the records NetBox publishes from those it
+// marks as not published.
+func splitInactiveRecords(records []Record) ([]Record, []Record) {
+	var active, inactive []Record
+	for _, record := range records {
+		if isDisabled(record) {
+			inactive = append(inactive, record)
+			continue
+		}
+		active = append(active, record)
+	}
+	return active, inactive
+}
+
+// isTTLOnlyMismatch reports whether a discrepancy is purely TTL drift: the
+// server returned exactly the expected values, but with a different TTL. Values
+// differing only in the case of host names count as the same unless
+// caseSensitive is set, as they do when validating.
+func isTTLOnlyMismatch(d Discrepancy, caseSensitive bool) bool {
+	expected, _ := reportValues(d.Expected)
+	actual, _ := reportValues(d.Actual)
+	if len(actual) == 0 || d.ExpectedTTL == d.ActualTTL {
+		return false
+	}
+	return valueSetsEqual(d.RecordType, expected, actual, caseSensitive)
+}
+
+// filterTTLOnlyMismatches keeps only the discrepancies that are pure TTL drift.
+func filterTTLOnlyMismatches(discrepancies []Discrepancy, caseSensitive bool) []Discrepancy {
+	var ttlDiscrepancies []Discrepancy
+	for _, d := range discrepancies {
+		if isTTLOnlyMismatch(d, caseSensitive) {
+			ttlDiscrepancies = append(ttlDiscrepancies, d)
+		}
+	}
+	return ttlDiscrepancies
+}
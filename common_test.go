@@ -0,0 +1,58 @@
+// common_test.go
+package main
+
+import "testing"
+
+func TestIsTTLOnlyMismatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		d             Discrepancy
+		caseSensitive bool
+		want          bool
+	}{
+		{"TTL drift", Discrepancy{RecordType: "A", Expected: []string{"192.0.2.1"}, Actual: []string{"192.0.2.1"}, ExpectedTTL: 300, ActualTTL: 600}, false, true},
+		{"same TTL", Discrepancy{RecordType: "A", Expected: []string{"192.0.2.1"}, Actual: []string{"192.0.2.1"}, ExpectedTTL: 300, ActualTTL: 300}, false, false},
+		{"values differ", Discrepancy{RecordType: "A", Expected: []string{"192.0.2.1"}, Actual: []string{"192.0.2.2"}, ExpectedTTL: 300, ActualTTL: 600}, false, false},
+		{"nothing served", Discrepancy{RecordType: "A", Expected: []string{"192.0.2.1"}, Actual: []string{}, ExpectedTTL: 300}, false, false},
+		{"host name case", Discrepancy{RecordType: "CNAME", Expected: []string{"Target.example.com."}, Actual: []string{"target.example.com."}, ExpectedTTL: 300, ActualTTL: 600}, false, true},
+		{"host name case, case-sensitive", Discrepancy{RecordType: "CNAME", Expected: []string{"Target.example.com."}, Actual: []string{"target.example.com."}, ExpectedTTL: 300, ActualTTL: 600}, true, false},
+		{"TXT case", Discrepancy{RecordType: "TXT", Expected: []string{"Hello"}, Actual: []string{"hello"}, ExpectedTTL: 300, ActualTTL: 600}, false, false},
+	}
+	for _, tt := range tests {
+		if got := isTTLOnlyMismatch(tt.d, tt.caseSensitive); got != tt.want {
+			t.Errorf("%s: isTTLOnlyMismatch = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterManagedRecords(t *testing.T) {
+	managed := func(r Record) Record {
+		r.Managed = true
+		return r
+	}
+	records := []Record{
+		managed(testRecord("@", "SOA", "ns1 hostmaster 1 7200 3600 1209600 3600", 3600)),
+		managed(testRecord("@", "NS", "ns1", 3600)),
+		managed(testRecord("1", "PTR", "www.example.com.", 3600)),
+		testRecord("www", "A", "192.0.2.1", 3600),
+		testRecord("2", "PTR", "mail.example.com.", 3600),
+	}
+	types := func(records []Record) []string {
+		var got []string
+		for _, r := range records {
+			got = append(got, r.Name+"/"+r.Type)
+		}
+		return got
+	}
+
+	// Skipping managed records only drops the generated PTRs
+	kept, skipped := filterManagedRecords(records, false)
+	if want := []string{"@/SOA", "@/NS", "www/A", "2/PTR"}; skipped != 1 || !stringSlicesEqualUnordered(types(kept), want) {
+		t.Errorf("skipping managed: kept %v (skipped %d), want %v", types(kept), skipped, want)
+	}
+
+	kept, skipped = filterManagedRecords(records, true)
+	if want := []string{"@/SOA", "@/NS", "1/PTR"}; skipped != 2 || !stringSlicesEqualUnordered(types(kept), want) {
+		t.Errorf("only managed: kept %v (skipped %d), want %v", types(kept), skipped, want)
+	}
+}
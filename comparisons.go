@@ -0,0 +1,149 @@
+// comparisons.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Comparison outcomes besides the category of a discrepancy.
+const (
+	OutcomeMatch    = "match"
+	OutcomeNearMiss = "near_miss"
+)
+
+// Comparison is one expected-vs-actual comparison, as written to the
+// --dump-comparisons file. Outcome is OutcomeMatch, OutcomeNearMiss, or the
+// category of the discrepancy found.
+type Comparison struct {
+	Time        time.Time `json:"Time"`
+	FQDN        string    `json:"FQDN"`
+	RecordType  string    `json:"RecordType"`
+	ZoneName    string    `json:"ZoneName"`
+	Server      string    `json:"Server"`
+	Expected    []string  `json:"Expected"`
+	Actual      []string  `json:"Actual"`
+	ExpectedTTL int       `json:"ExpectedTTL"`
+	ActualTTL   int       `json:"ActualTTL"`
+	DurationMs  float64   `json:"DurationMs,omitempty"`
+	Outcome     string    `json:"Outcome"`
+	Message     string    `json:"Message,omitempty"`
+}
+
+// comparisonDump writes every comparison of a run as JSON Lines, one object per
+// line, for loading into a database. A nil comparisonDump writes nothing, so
+// runs without --dump-comparisons pay no overhead.
+type comparisonDump struct {
+	mu      sync.Mutex
+	out     io.WriteCloser
+	encoder *json.Encoder
+	count   int
+	err     error
+}
+
+// newComparisonDump opens path for writing comparisons, treating "-" as stdout.
+func newComparisonDump(path string) (*comparisonDump, error) {
+	out, err := createReportFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create comparison dump: %v", err)
+	}
+	return &comparisonDump{out: out, encoder: json.NewEncoder(out)}, nil
+}
+
+// record writes one comparison. The first write error is kept and returned by close.
+func (c *comparisonDump) record(comparison Comparison) {
+	if c == nil {
+		return
+	}
+	if comparison.Time.IsZero() {
+		comparison.Time = time.Now().UTC()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	if err := c.encoder.Encode(comparison); err != nil {
+		c.err = err
+		return
+	}
+	c.count++
+}
+
+// recordServerResult writes the comparison of a record set with one server's
+// answer: a match or near-miss when there were no discrepancies, otherwise one
+// line per discrepancy.
+func (c *comparisonDump) recordServerResult(key RecordKey, qtype uint16, server string, expectedValues []string, expectedTTL int, resp *dns.Msg, discrepancies []Discrepancy, elapsed time.Duration) {
+	if c == nil {
+		return
+	}
+	durationMs := float64(elapsed) / float64(time.Millisecond)
+
+	for _, d := range discrepancies {
+		actual, _ := reportValues(d.Actual)
+		c.record(Comparison{
+			FQDN:        key.FQDN,
+			RecordType:  key.RecordType,
+			ZoneName:    key.ZoneName,
+			Server:      server,
+			Expected:    expectedValues,
+			Actual:      actual,
+			ExpectedTTL: expectedTTL,
+			ActualTTL:   d.ActualTTL,
+			DurationMs:  durationMs,
+			Outcome:     d.Category,
+			Message:     d.Message,
+		})
+	}
+	if len(discrepancies) > 0 || resp == nil {
+		return
+	}
+
+	actual := []string{}
+	actualTTL := 0
+	for _, ans := range resp.Answer {
+		if ans.Header().Rrtype != qtype {
+			continue
+		}
+		actual = append(actual, comparableRRValue(ans))
+		if actualTTL == 0 {
+			actualTTL = int(ans.Header().Ttl)
+		}
+	}
+	outcome := OutcomeMatch
+	if actualTTL != expectedTTL {
+		outcome = OutcomeNearMiss
+	}
+	c.record(Comparison{
+		FQDN:        key.FQDN,
+		RecordType:  key.RecordType,
+		ZoneName:    key.ZoneName,
+		Server:      server,
+		Expected:    expectedValues,
+		Actual:      actual,
+		ExpectedTTL: expectedTTL,
+		ActualTTL:   actualTTL,
+		DurationMs:  durationMs,
+		Outcome:     outcome,
+	})
+}
+
+// close flushes the dump and returns the number of comparisons written.
+func (c *comparisonDump) close() (int, error) {
+	if c == nil {
+		return 0, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.out.Close(); err != nil && c.err == nil {
+		c.err = err
+	}
+	return c.count, c.err
+}
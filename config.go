@@ -3,10 +3,13 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/spf13/viper"
 )
 
 type Config struct {
@@ -62,3 +65,81 @@ func mergeConfigs(base, override *Config) {
 		base.NameServers = override.NameServers
 	}
 }
+
+// expandConfigEnv expands ${VAR} references in the string values of a config
+// file that has been read. The expanded values replace the file's values only, so
+// environment variables and flags keep their precedence over the config file.
+func expandConfigEnv(file string) error {
+	fileConfig := viper.New()
+	fileConfig.SetConfigType("yaml")
+	fileConfig.SetConfigFile(file)
+	if err := fileConfig.ReadInConfig(); err != nil {
+		return err
+	}
+
+	expanded, err := expandEnvValue(fileConfig.AllSettings())
+	if err != nil {
+		return err
+	}
+	return viper.MergeConfigMap(expanded.(map[string]interface{}))
+}
+
+// expandEnvValue expands environment references in every string nested in value.
+func expandEnvValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return expandEnvRefs(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			expanded, err := expandEnvValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			v[key] = expanded
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			expanded, err := expandEnvValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+		return v, nil
+	default:
+		return value, nil
+	}
+}
+
+// expandEnvRefs replaces ${VAR} with the value of the environment variable VAR.
+// "$${" is an escape for a literal "${", and a "$" not followed by "{" is kept
+// as is, so secrets containing dollar signs need no escaping unless they
+// contain "${". Referencing an unset variable is an error.
+func expandEnvRefs(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], "$${") {
+			b.WriteString("${")
+			i += 2
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		end := strings.IndexByte(s[i+2:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated environment reference")
+		}
+		name := s[i+2 : i+2+end]
+		envValue, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		b.WriteString(envValue)
+		i += 2 + end
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,101 @@
+// connpool.go
+package main
+
+import (
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// defaultConnPoolSize is the number of idle connections kept per server when no
+// pool size is configured.
+const defaultConnPoolSize = 4
+
+// connPool keeps open TCP (or DNS-over-TLS) connections keyed by server address
+// so consecutive queries reuse them instead of paying connection setup every
+// time. A connection is used by one query at a time; concurrent validators that
+// find no idle connection dial a new one, and up to size connections per server
+// are kept afterwards.
+type connPool struct {
+	size   int
+	mu     sync.Mutex
+	idle   map[string][]*dns.Conn
+	closed bool
+}
+
+// newConnPool returns a pool keeping up to size idle connections per server.
+// A size of zero or less uses defaultConnPoolSize.
+func newConnPool(size int) *connPool {
+	if size <= 0 {
+		size = defaultConnPoolSize
+	}
+	return &connPool{
+		size: size,
+		idle: make(map[string][]*dns.Conn),
+	}
+}
+
+// get returns an idle connection to address, dialing a new one if none is available.
+func (p *connPool) get(client *dns.Client, address string) (*dns.Conn, error) {
+	p.mu.Lock()
+	conns := p.idle[address]
+	if n := len(conns); n > 0 {
+		conn := conns[n-1]
+		p.idle[address] = conns[:n-1]
+		p.mu.Unlock()
+		return conn, nil
+	}
+	p.mu.Unlock()
+
+	return client.Dial(address)
+}
+
+// put hands a healthy connection back to the pool, closing it if the pool for
+// address is already full or the pool has been shut down.
+func (p *connPool) put(address string, conn *dns.Conn) {
+	p.mu.Lock()
+	if p.closed || len(p.idle[address]) >= p.size {
+		p.mu.Unlock()
+		conn.Close()
+		return
+	}
+	p.idle[address] = append(p.idle[address], conn)
+	p.mu.Unlock()
+}
+
+// exchange sends msg to address over a pooled connection. A connection that
+// fails is discarded rather than returned, so the next attempt redials.
+func (p *connPool) exchange(client *dns.Client, msg *dns.Msg, address string) (*dns.Msg, error) {
+	conn, err := p.get(client, address)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, _, err := client.ExchangeWithConn(msg, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	p.put(address, conn)
+	return resp, nil
+}
+
+// shutdown closes every idle connection; connections still in use are closed
+// when they are handed back.
+func (p *connPool) shutdown() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	for address, conns := range p.idle {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		delete(p.idle, address)
+	}
+}
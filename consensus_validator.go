@@ -0,0 +1,122 @@
+// consensus_validator.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// consensusServer is the Server reported for findings raised by resolver consensus.
+const consensusServer = "resolvers"
+
+// validateRecordsConsensus queries every record set against a list of recursive
+// resolvers and reports a discrepancy only when at least quorum resolvers return an
+// answer that disagrees with NetBox, so that a single stale cache doesn't create
+// noise. A quorum of zero or less requires a simple majority. TTLs are not compared
+// since resolvers serve decremented cache TTLs.
+func validateRecordsConsensus(records []Record, resolvers []string, quorum int, logger log.Logger, opts ValidationOptions) []Discrepancy {
+	if quorum <= 0 {
+		quorum = len(resolvers)/2 + 1
+	}
+
+	var wg sync.WaitGroup
+
+	// Group records by FQDN and Record Type using RecordKey
+	expectedRecords := make(map[RecordKey][]Record)
+	for _, record := range records {
+		if strings.ToUpper(record.Type) == "SOA" {
+			continue
+		}
+		key := RecordKey{
+			FQDN:       record.FQDN,
+			RecordType: strings.ToUpper(record.Type),
+			ZoneName:   record.ZoneName,
+			ViewName:   record.ViewName,
+		}
+		expectedRecords[key] = append(expectedRecords[key], record)
+	}
+
+	var findings findingSet
+
+	for key, records := range expectedRecords {
+		wg.Add(1)
+		go func(key RecordKey, records []Record) {
+			defer wg.Done()
+			if d := validateRecordSetConsensus(key, records, resolvers, quorum, logger, opts); d != nil {
+				opts.FailFast.record([]Discrepancy{*d})
+				findings.add([]Discrepancy{*d}, nil, nil)
+			}
+		}(key, records)
+	}
+
+	wg.Wait()
+	return findings.discrepancies
+}
+
+// validateRecordSetConsensus checks one record set against all resolvers and returns
+// a discrepancy when the number of disagreeing resolvers reaches the quorum.
+func validateRecordSetConsensus(key RecordKey, records []Record, resolvers []string, quorum int, logger log.Logger, opts ValidationOptions) *Discrepancy {
+	qtype, ok := dns.StringToType[key.RecordType]
+	if !ok {
+		return nil
+	}
+
+	expectedValues := []string{}
+	for _, record := range records {
+		expectedValues = append(expectedValues, normalizeExpectedValue(key.RecordType, record.Value, record.ZoneName))
+	}
+
+	var disagreements []string
+	for _, resolver := range resolvers {
+		opts.Throttle.acquire(resolver)
+		resp, err := queryDNSWithRetry(key.FQDN, qtype, resolver, opts.Query.recursive())
+		opts.Throttle.release(resolver)
+		if err != nil {
+			// An unreachable resolver neither agrees nor disagrees
+			level.Warn(logger).Log("msg", "Resolver query error", "fqdn", key.FQDN, "resolver", resolver, "err", err)
+			continue
+		}
+
+		actualValues := []string{}
+		for _, ans := range resp.Answer {
+			// Resolvers include the CNAME chain; only compare the requested type
+			if ans.Header().Rrtype != qtype {
+				continue
+			}
+			if val := comparableRRValue(ans); val != "" {
+				actualValues = append(actualValues, val)
+			}
+		}
+
+		if !valueSetsEqual(key.RecordType, expectedValues, actualValues, opts.CaseSensitive) {
+			level.Debug(logger).Log("msg", "Resolver disagrees with NetBox", "fqdn", key.FQDN, "type", key.RecordType, "resolver", resolver, "actual", strings.Join(actualValues, ","))
+			disagreements = append(disagreements, fmt.Sprintf("%s=%s", resolver, strings.Join(actualValues, ",")))
+		}
+	}
+
+	if len(disagreements) < quorum {
+		if len(disagreements) > 0 {
+			level.Info(logger).Log("msg", "Resolver disagreement below quorum", "fqdn", key.FQDN, "type", key.RecordType, "disagreeing", len(disagreements), "quorum", quorum)
+		}
+		return nil
+	}
+
+	level.Warn(logger).Log("msg", "Resolvers disagree with NetBox", "fqdn", key.FQDN, "type", key.RecordType, "disagreeing", len(disagreements), "quorum", quorum)
+	discrepancy := Discrepancy{
+		FQDN:         key.FQDN,
+		RecordType:   key.RecordType,
+		ZoneName:     key.ZoneName,
+		Expected:     expectedValues,
+		Actual:       disagreements,
+		Server:       consensusServer,
+		Message:      fmt.Sprintf("%d of %d resolvers disagree with NetBox (quorum %d)", len(disagreements), len(resolvers), quorum),
+		ClientSubnet: opts.Query.clientSubnetString(),
+		Category:     CategoryPropagation,
+	}
+	return &discrepancy
+}
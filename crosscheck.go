@@ -0,0 +1,154 @@
+// crosscheck.go
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// crossCheckTransfer queries server directly for the RRsets it transferred for
+// zoneName and reports those it answers differently, or not at all, which
+// points at a serving bug rather than a data problem. Only sample percent of
+// the RRsets are queried, picked by a hash of their name and type so repeated
+// runs check the same ones. Glue and DNSSEC records are skipped, as a direct
+// query for them isn't answered from the zone's own data.
+func crossCheckTransfer(zoneName, server string, rrs []dns.RR, sample int, logger log.Logger, opts ValidationOptions) []Discrepancy {
+	apex := dns.Fqdn(strings.ToLower(zoneName))
+
+	delegations := make(map[string]bool)
+	for _, rr := range rrs {
+		name := strings.ToLower(rr.Header().Name)
+		if rr.Header().Rrtype == dns.TypeNS && name != apex {
+			delegations[name] = true
+		}
+	}
+
+	rrsets := make(map[RecordKey][]string)
+	seen := make(map[string]bool)
+	for _, rr := range rrs {
+		name := strings.ToLower(rr.Header().Name)
+		switch rr.Header().Rrtype {
+		case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3:
+			continue
+		case dns.TypeDS:
+		default:
+			// The parent side of a delegation only holds its DS record
+			if delegations[name] {
+				continue
+			}
+		}
+		if !dns.IsSubDomain(apex, name) || belowDelegation(name, apex, delegations) {
+			continue
+		}
+
+		key := RecordKey{FQDN: name, RecordType: dns.TypeToString[rr.Header().Rrtype], ZoneName: zoneName}
+		value := rrValueString(rr)
+		if id := name + "|" + key.RecordType + "|" + value; !seen[id] {
+			seen[id] = true
+			rrsets[key] = append(rrsets[key], value)
+		}
+	}
+
+	keys := make([]RecordKey, 0, len(rrsets))
+	for key := range rrsets {
+		if inCrossCheckSample(key, sample) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].FQDN != keys[j].FQDN {
+			return keys[i].FQDN < keys[j].FQDN
+		}
+		return keys[i].RecordType < keys[j].RecordType
+	})
+
+	var discrepancies []Discrepancy
+	for _, key := range keys {
+		if d, ok := crossCheckRRset(key, rrsets[key], server, logger, opts); ok {
+			discrepancies = append(discrepancies, d)
+		}
+	}
+
+	level.Info(logger).Log("msg", "Cross-checked transferred records with direct queries", "zone", zoneName, "server", server, "rrsets", len(rrsets), "queried", len(keys), "inconsistent", len(discrepancies))
+	return discrepancies
+}
+
+// crossCheckRRset queries server for one transferred RRset and reports whether
+// the answer differs from the transferred values.
+func crossCheckRRset(key RecordKey, transferred []string, server string, logger log.Logger, opts ValidationOptions) (Discrepancy, bool) {
+	sort.Strings(transferred)
+	discrepancy := Discrepancy{
+		FQDN:       key.FQDN,
+		RecordType: key.RecordType,
+		ZoneName:   key.ZoneName,
+		Expected:   transferred,
+		Server:     server,
+		Category:   CategoryTransferMismatch,
+	}
+
+	opts.Throttle.acquire(server)
+	resp, err := queryDNSWithRetry(key.FQDN, dns.StringToType[key.RecordType], server, opts.Query)
+	opts.Throttle.release(server)
+	switch {
+	case err != nil:
+		level.Warn(logger).Log("msg", "Direct query failed for transferred record", "fqdn", key.FQDN, "type", key.RecordType, "server", server, "err", err)
+		discrepancy.Message = fmt.Sprintf("In the zone transfer, but the direct query failed: %v", err)
+		discrepancy.ExtendedError = extendedErrorText(resp)
+		return discrepancy, true
+	case resp.Rcode != dns.RcodeSuccess:
+		level.Warn(logger).Log("msg", "Direct query failed for transferred record", "fqdn", key.FQDN, "type", key.RecordType, "server", server, "rcode", dns.RcodeToString[resp.Rcode])
+		discrepancy.Message = fmt.Sprintf("In the zone transfer, but the direct query returned %s", dns.RcodeToString[resp.Rcode])
+		discrepancy.ExtendedError = extendedErrorText(resp)
+		return discrepancy, true
+	}
+
+	var answered []string
+	seen := make(map[string]bool)
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype != dns.StringToType[key.RecordType] || !strings.EqualFold(rr.Header().Name, key.FQDN) {
+			continue
+		}
+		if value := rrValueString(rr); !seen[value] {
+			seen[value] = true
+			answered = append(answered, value)
+		}
+	}
+	sort.Strings(answered)
+	discrepancy.Actual = answered
+
+	if strings.Join(answered, "\n") == strings.Join(transferred, "\n") {
+		level.Debug(logger).Log("msg", "Direct query matches transferred record", "fqdn", key.FQDN, "type", key.RecordType, "server", server)
+		return Discrepancy{}, false
+	}
+
+	level.Warn(logger).Log("msg", "Direct query differs from transferred record", "fqdn", key.FQDN, "type", key.RecordType, "server", server)
+	if len(answered) == 0 {
+		discrepancy.Message = "In the zone transfer, but the direct query returned no records"
+	} else {
+		discrepancy.Message = "Direct query answers differently from the zone transfer"
+	}
+	return discrepancy, true
+}
+
+// rrValueString returns the data of rr in presentation format, without its
+// owner, TTL, class and type.
+func rrValueString(rr dns.RR) string {
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+// inCrossCheckSample reports whether the RRset of key falls in the sampled
+// percentage of RRsets.
+func inCrossCheckSample(key RecordKey, sample int) bool {
+	if sample >= 100 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key.FQDN + "|" + key.RecordType))
+	return int(h.Sum32()%100) < sample
+}
@@ -0,0 +1,177 @@
+// delegation_ttl.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// parentLookupTimeout bounds the lookup of a parent zone's nameservers outside
+// NetBox.
+const parentLookupTimeout = 5 * time.Second
+
+// validateDelegationTTLs compares the TTL the parent zone's servers give the NS
+// records delegating each zone of records with the expected TTL: expectedTTL if
+// set, otherwise the TTL of the zone's apex NS records in NetBox. Parents often
+// set their own TTL, and a long one slows down nameserver changes.
+func validateDelegationTTLs(records []Record, logger log.Logger, zoneViewToNameservers map[string][]string, expectedTTL int, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	// Each zone and view is checked once, expecting its apex NS TTL
+	zones := make(map[RecordKey]int)
+	for _, record := range records {
+		if record.ZoneName == "" || record.ViewName == "" {
+			continue
+		}
+		key := RecordKey{FQDN: dns.Fqdn(strings.ToLower(record.ZoneName)), RecordType: "NS", ZoneName: record.ZoneName, ViewName: record.ViewName}
+		if _, ok := zones[key]; !ok {
+			zones[key] = expectedTTL
+		}
+		if expectedTTL == 0 && strings.ToUpper(record.Type) == "NS" && strings.EqualFold(dns.Fqdn(record.FQDN), key.FQDN) {
+			zones[key] = record.ZoneDefaultTTL
+			if record.TTL != nil && *record.TTL > 0 {
+				zones[key] = *record.TTL
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var allDiscrepancies []Discrepancy
+	var successfulValidations []ValidationRecord
+
+	for key, ttl := range zones {
+		if ttl == 0 {
+			level.Debug(logger).Log("msg", "No apex NS TTL in NetBox, skipping delegation TTL check", "zone", key.ZoneName, "view", key.ViewName)
+			continue
+		}
+
+		wg.Add(1)
+		go func(key RecordKey, ttl int) {
+			defer wg.Done()
+
+			parent, parentServers := delegatingServers(key.ZoneName, key.ViewName, zoneViewToNameservers, logger, opts)
+			if len(parentServers) == 0 {
+				level.Warn(logger).Log("msg", "No parent zone nameservers found, skipping delegation TTL check", "zone", key.ZoneName)
+				return
+			}
+
+			discrepancies, validations := validateDelegationTTL(key, parent, parentServers, ttl, logger, opts)
+			mu.Lock()
+			defer mu.Unlock()
+			allDiscrepancies = append(allDiscrepancies, discrepancies...)
+			successfulValidations = append(successfulValidations, validations...)
+		}(key, ttl)
+	}
+
+	wg.Wait()
+	tagClientSubnet(allDiscrepancies, opts.Query)
+	opts.FailFast.record(allDiscrepancies)
+	return allDiscrepancies, successfulValidations
+}
+
+// delegatingServers returns the parent zone of zoneName and its servers. A
+// parent in NetBox is asked on its own nameservers; otherwise, as for a zone
+// delegated from a registry's TLD, the parent's servers are looked up through
+// the system resolver.
+func delegatingServers(zoneName, viewName string, zoneViewToNameservers map[string][]string, logger log.Logger, opts ValidationOptions) (string, []string) {
+	if parent, servers := findParentZoneServers(zoneName, viewName, zoneViewToNameservers); len(servers) > 0 {
+		return parent, servers
+	}
+
+	for parent := getParentZoneName(strings.TrimSuffix(zoneName, ".")); parent != ""; parent = getParentZoneName(parent) {
+		if opts.Query.context().Err() != nil {
+			return "", nil
+		}
+		ctx, cancel := context.WithTimeout(opts.Query.context(), parentLookupTimeout)
+		nameservers, err := net.DefaultResolver.LookupNS(ctx, parent)
+		cancel()
+		if err != nil || len(nameservers) == 0 {
+			level.Debug(logger).Log("msg", "No nameservers found for parent zone", "zone", zoneName, "parent", parent, "err", err)
+			continue
+		}
+		servers := make([]string, 0, len(nameservers))
+		for _, ns := range nameservers {
+			servers = append(servers, strings.TrimSuffix(ns.Host, "."))
+		}
+		return parent, servers
+	}
+	return "", nil
+}
+
+// validateDelegationTTL asks each parent server for the NS records of the zone
+// and reports servers whose delegation TTL differs from ttl by more than the
+// TTL tolerance.
+func validateDelegationTTL(key RecordKey, parent string, servers []string, ttl int, logger log.Logger, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	var discrepancies []Discrepancy
+	var successfulValidations []ValidationRecord
+
+	for _, server := range servers {
+		level.Debug(logger).Log("msg", "Checking delegation TTL", "zone", key.ZoneName, "parent", parent, "server", server)
+		opts.Throttle.acquire(server)
+		resp, err := queryDNSWithRetry(key.FQDN, dns.TypeNS, server, opts.Query)
+		opts.Throttle.release(server)
+		if err != nil {
+			level.Warn(logger).Log("msg", "DNS query error", "zone", key.ZoneName, "server", server, "err", err)
+			discrepancies = append(discrepancies, Discrepancy{
+				FQDN:       key.FQDN,
+				RecordType: "NS",
+				ZoneName:   key.ZoneName,
+				Server:     server,
+				Message:    fmt.Sprintf("DNS query error asking parent zone %s for the delegation: %v", parent, err),
+				Category:   CategoryQueryError,
+			})
+			continue
+		}
+
+		// A referral carries the delegation in the authority section; a parent
+		// also serving the child answers with it
+		actualTTL := -1
+		for _, rr := range append(append([]dns.RR(nil), resp.Ns...), resp.Answer...) {
+			if ns, ok := rr.(*dns.NS); ok && strings.EqualFold(ns.Hdr.Name, key.FQDN) {
+				actualTTL = int(ns.Hdr.Ttl)
+				break
+			}
+		}
+		if actualTTL < 0 {
+			level.Debug(logger).Log("msg", "Parent returned no delegation NS records", "zone", key.ZoneName, "server", server, "rcode", dns.RcodeToString[resp.Rcode])
+			continue
+		}
+
+		if ttlWithinTolerance(ttl, actualTTL, opts.TTLTolerance) {
+			level.Info(logger).Log("msg", "Delegation TTL matches", "zone", key.ZoneName, "server", server, "ttl", actualTTL)
+			if opts.RecordSuccessful {
+				successfulValidations = append(successfulValidations, ValidationRecord{
+					FQDN:        key.FQDN,
+					RecordType:  "NS",
+					ZoneName:    key.ZoneName,
+					ExpectedTTL: ttl,
+					ActualTTL:   actualTTL,
+					Server:      server,
+					Message:     "Delegation TTL at parent matches",
+				})
+			}
+			continue
+		}
+
+		level.Warn(logger).Log("msg", "Delegation TTL differs", "zone", key.ZoneName, "parent", parent, "server", server, "expected", ttl, "actual", actualTTL)
+		discrepancies = append(discrepancies, Discrepancy{
+			FQDN:        key.FQDN,
+			RecordType:  "NS",
+			ZoneName:    key.ZoneName,
+			ExpectedTTL: ttl,
+			ActualTTL:   actualTTL,
+			Server:      server,
+			Message:     fmt.Sprintf("Parent zone %s delegates with an NS TTL of %d, expected %d", parent, actualTTL, ttl),
+			Category:    CategoryDelegationTTL,
+		})
+	}
+
+	return discrepancies, successfulValidations
+}
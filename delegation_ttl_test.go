@@ -0,0 +1,23 @@
+// delegation_ttl_test.go
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func TestDelegatingServersCancelled(t *testing.T) {
+	// A run stopped by --fail-fast doesn't wait on the system resolver
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts := ValidationOptions{Query: QueryOptions{Context: ctx}}
+
+	finishWithin(t, time.Second, func() {
+		if parent, servers := delegatingServers("sub.example.net", "default", nil, log.NewNopLogger(), opts); len(servers) != 0 {
+			t.Errorf("got parent %s servers %v for a cancelled run, want none", parent, servers)
+		}
+	})
+}
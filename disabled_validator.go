@@ -0,0 +1,137 @@
+// disabled_validator.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// isDisabled reports whether NetBox marks a record as not published, through
+// the active flag or an inactive status. Plugin versions exposing neither are
+// taken to publish every record.
+func isDisabled(record Record) bool {
+	if record.Active != nil && !*record.Active {
+		return true
+	}
+	return strings.EqualFold(record.Status, "inactive")
+}
+
+// validateDisabledRecords confirms that the records NetBox marks as disabled are
+// absent from DNS, reporting those their zone's servers still serve. Other
+// records of the same name and type may legitimately exist, so only the
+// disabled value itself counts.
+func validateDisabledRecords(records []Record, servers []string, logger log.Logger, zoneViewToNameservers map[string][]string, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	var wg sync.WaitGroup
+	var findings findingSet
+
+	for _, record := range records {
+		recordType := strings.ToUpper(record.Type)
+		if !isDisabled(record) || recordType == "SOA" || isAliasType(recordType) || !opts.FQDNFilter.matches(record.FQDN) {
+			continue
+		}
+		qtype, ok := dns.StringToType[recordType]
+		if !ok {
+			continue
+		}
+		recordServers := zoneViewToNameservers[zoneViewKey(record.ZoneName, record.ViewName)]
+		if len(recordServers) == 0 {
+			level.Debug(logger).Log("msg", "No nameservers for disabled record's zone, skipping", "fqdn", record.FQDN, "zone", record.ZoneName)
+			continue
+		}
+
+		wg.Add(1)
+		go func(record Record, qtype uint16, recordServers []string) {
+			defer wg.Done()
+
+			discrepancies, successfulValidations := validateRecordAbsent(record, qtype, recordServers, logger, opts)
+			findings.add(discrepancies, successfulValidations, nil)
+		}(record, qtype, recordServers)
+	}
+
+	wg.Wait()
+	return findings.discrepancies, findings.successful
+}
+
+// validateRecordAbsent queries each server for a disabled record and reports
+// the servers still serving its value.
+func validateRecordAbsent(record Record, qtype uint16, servers []string, logger log.Logger, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	var discrepancies []Discrepancy
+	var successfulValidations []ValidationRecord
+
+	recordType := strings.ToUpper(record.Type)
+	value := normalizeExpectedValue(recordType, record.Value, record.ZoneName)
+	for _, server := range servers {
+		level.Debug(logger).Log("msg", "Checking that disabled record is absent", "fqdn", record.FQDN, "type", recordType, "server", server)
+		opts.Throttle.acquire(server)
+		resp, err := queryDNSWithRetry(record.FQDN, qtype, server, opts.Query)
+		opts.Throttle.release(server)
+		if err != nil && (resp == nil || resp.Rcode != dns.RcodeNameError) {
+			level.Warn(logger).Log("msg", "DNS query error", "fqdn", record.FQDN, "server", server, "err", err)
+			discrepancy := Discrepancy{
+				FQDN:        record.FQDN,
+				RecordType:  recordType,
+				ZoneName:    record.ZoneName,
+				Expected:    []string{},
+				Server:      server,
+				Message:     fmt.Sprintf("DNS query error: %v", err),
+				Category:    CategoryQueryError,
+				Description: record.Description,
+			}
+			discrepancies = append(discrepancies, discrepancy)
+			continue
+		}
+
+		actualValues := []string{}
+		actualTTL := 0
+		if resp != nil {
+			for _, ans := range resp.Answer {
+				if !ownsRRset(ans, record.FQDN, qtype) {
+					continue
+				}
+				actualValues = append(actualValues, comparableRRValue(ans))
+				actualTTL = int(ans.Header().Ttl)
+			}
+		}
+
+		if valueSetContains(recordType, []string{value}, actualValues, opts.CaseSensitive) {
+			level.Warn(logger).Log("msg", "Disabled record still served", "fqdn", record.FQDN, "type", recordType, "value", value, "server", server)
+			discrepancy := Discrepancy{
+				FQDN:        record.FQDN,
+				RecordType:  recordType,
+				ZoneName:    record.ZoneName,
+				Expected:    []string{},
+				Actual:      []string{value},
+				ActualTTL:   actualTTL,
+				Server:      server,
+				Message:     "Record is disabled in NetBox but still served",
+				Category:    CategoryLingeringRecord,
+				Description: record.Description,
+			}
+			discrepancies = append(discrepancies, discrepancy)
+			continue
+		}
+
+		level.Info(logger).Log("msg", "Disabled record is absent as expected", "fqdn", record.FQDN, "type", recordType, "server", server)
+		if opts.RecordSuccessful {
+			validationRecord := ValidationRecord{
+				FQDN:       record.FQDN,
+				RecordType: recordType,
+				ZoneName:   record.ZoneName,
+				Expected:   []string{},
+				Actual:     actualValues,
+				Server:     server,
+				Message:    fmt.Sprintf("Disabled record %s is absent as expected", value),
+			}
+			successfulValidations = append(successfulValidations, validationRecord)
+		}
+	}
+
+	tagClientSubnet(discrepancies, opts.Query)
+	opts.FailFast.record(discrepancies)
+	return discrepancies, successfulValidations
+}
@@ -0,0 +1,32 @@
+// disabled_validator_test.go
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func TestValidateDisabledRecordsMoreZoneServersThanServers(t *testing.T) {
+	zoneServers := []string{"127.0.57.4", "127.0.57.5", "127.0.57.6"}
+	for _, addr := range zoneServers {
+		serveZone(t, addr, newTestZone(t, "example.com",
+			"old 3600 IN A 192.0.2.9",
+		))
+	}
+
+	// View servers give the zone more servers than the run-wide list, and each still serves the record
+	record := testRecord("old", "A", "192.0.2.9", 3600)
+	record.Status = "inactive"
+	zoneViewToNameservers := map[string][]string{zoneViewKey("example.com", "default"): zoneServers}
+	opts := ValidationOptions{Query: QueryOptions{Retries: 1}}
+
+	var discrepancies []Discrepancy
+	finishWithin(t, 10*time.Second, func() {
+		discrepancies, _ = validateDisabledRecords([]Record{record}, zoneServers[:1], log.NewNopLogger(), zoneViewToNameservers, opts)
+	})
+	if len(discrepancies) != len(zoneServers) {
+		t.Errorf("got findings %v, want one per zone server", categories(discrepancies))
+	}
+}
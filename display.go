@@ -0,0 +1,113 @@
+// display.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// displayCheck validates records against the value in NetBox's display field
+// rather than the raw value, and reports the records whose raw value and
+// display disagree, which points at NetBox normalizing one but not the other.
+// Records whose display carries no value keep their raw value.
+type displayCheck struct {
+	logger log.Logger
+
+	mu        sync.Mutex
+	records   int
+	displayed int
+	issues    []Discrepancy
+}
+
+// newDisplayCheck returns a displayCheck.
+func newDisplayCheck(logger log.Logger) *displayCheck {
+	return &displayCheck{logger: logger}
+}
+
+// displayValue returns the value part of a record's display, which NetBox
+// renders as "name [TYPE] value". It returns false if the display has no value
+// or NetBox cut it short.
+func displayValue(record Record) (string, bool) {
+	marker := fmt.Sprintf("[%s]", strings.ToUpper(record.Type))
+	i := strings.Index(strings.ToUpper(record.Display), marker)
+	if i < 0 {
+		return "", false
+	}
+	value := strings.TrimSpace(record.Display[i+len(marker):])
+	if value == "" || strings.HasSuffix(value, "...") {
+		return "", false
+	}
+	return value, true
+}
+
+// apply replaces each record's value with its display value where there is
+// one, noting the records whose raw value reads differently. It is safe to
+// call on a nil displayCheck, which leaves the records alone.
+func (c *displayCheck) apply(records []Record) []Record {
+	if c == nil {
+		return records
+	}
+
+	var issues []Discrepancy
+	displayed := 0
+	for i := range records {
+		record := &records[i]
+		value, ok := displayValue(*record)
+		if !ok {
+			continue
+		}
+		displayed++
+		raw := strings.TrimSpace(record.Value)
+		if raw != value {
+			issues = append(issues, displayMismatch(*record, raw, value))
+		}
+		record.Value = value
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records += len(records)
+	c.displayed += displayed
+	c.issues = append(c.issues, issues...)
+	return records
+}
+
+// displayMismatch describes a record whose raw value and display disagree,
+// saying whether the two only differ in how they are written.
+func displayMismatch(record Record, raw, display string) Discrepancy {
+	recordType := strings.ToUpper(record.Type)
+	message := fmt.Sprintf("NetBox value %q differs from its display %q", raw, display)
+	if normalizeExpectedValue(recordType, raw, record.ZoneName) == normalizeExpectedValue(recordType, display, record.ZoneName) {
+		message = fmt.Sprintf("NetBox value %q is normalized differently in its display %q", raw, display)
+	}
+	return Discrepancy{
+		FQDN:       record.FQDN,
+		RecordType: recordType,
+		ZoneName:   record.ZoneName,
+		Expected:   []string{display},
+		Actual:     []string{raw},
+		Message:    message,
+		Category:   CategoryDisplayMismatch,
+	}
+}
+
+// report logs how many records were validated against their display, and
+// returns the records whose raw value and display disagree.
+func (c *displayCheck) report() []Discrepancy {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.displayed == 0 {
+		level.Warn(c.logger).Log("msg", "No NetBox record display carries a value; validated the raw values", "records", c.records)
+		return nil
+	}
+	level.Info(c.logger).Log("msg", "Validated records against their NetBox display", "displayed", c.displayed, "records", c.records, "disagreeing", len(c.issues))
+	return append([]Discrepancy{}, c.issues...)
+}
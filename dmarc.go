@@ -0,0 +1,116 @@
+// dmarc.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dmarcTag is one tag=value pair of a DMARC record.
+type dmarcTag struct {
+	name  string
+	value string
+}
+
+// parseDMARC splits a DMARC record such as "v=DMARC1; p=reject; rua=..." into
+// its tags, keyed by lower-cased tag name. It reports false for TXT values
+// that aren't DMARC records.
+func parseDMARC(value string) (map[string]string, bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(strings.ToLower(strings.ReplaceAll(value, " ", "")), "v=dmarc1") {
+		return nil, false
+	}
+
+	tags := make(map[string]string)
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, tagValue, _ := strings.Cut(part, "=")
+		tags[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(tagValue)
+	}
+	return tags, true
+}
+
+// sortedDMARCTags returns tags ordered by name, with the version tag first as
+// DMARC requires.
+func sortedDMARCTags(tags map[string]string) []dmarcTag {
+	var sorted []dmarcTag
+	for name, value := range tags {
+		sorted = append(sorted, dmarcTag{name: name, value: value})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if (sorted[i].name == "v") != (sorted[j].name == "v") {
+			return sorted[i].name == "v"
+		}
+		return sorted[i].name < sorted[j].name
+	})
+	return sorted
+}
+
+// dmarcComparisonValue renders a DMARC record with its tags in a fixed order
+// and spacing, so records differing only in tag order or whitespace compare
+// equal. Other TXT values are returned unchanged.
+func dmarcComparisonValue(value string) string {
+	tags, ok := parseDMARC(value)
+	if !ok {
+		return value
+	}
+	var parts []string
+	for _, tag := range sortedDMARCTags(tags) {
+		parts = append(parts, tag.name+"="+tag.value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// dmarcDifference describes which tags differ between the DMARC record NetBox
+// expects and the one served, or returns "" unless each side holds exactly
+// one DMARC record.
+func dmarcDifference(expected, actual []string) string {
+	expectedTags, ok := singleDMARC(expected)
+	if !ok {
+		return ""
+	}
+	actualTags, ok := singleDMARC(actual)
+	if !ok {
+		return ""
+	}
+
+	var differences []string
+	for _, tag := range sortedDMARCTags(expectedTags) {
+		actualValue, found := actualTags[tag.name]
+		switch {
+		case !found:
+			differences = append(differences, fmt.Sprintf("%s missing (expected %q)", tag.name, tag.value))
+		case actualValue != tag.value:
+			differences = append(differences, fmt.Sprintf("%s is %q, expected %q", tag.name, actualValue, tag.value))
+		}
+	}
+	for _, tag := range sortedDMARCTags(actualTags) {
+		if _, found := expectedTags[tag.name]; !found {
+			differences = append(differences, fmt.Sprintf("unexpected %s=%q", tag.name, tag.value))
+		}
+	}
+	if len(differences) == 0 {
+		return ""
+	}
+	return "DMARC tags differ: " + strings.Join(differences, "; ")
+}
+
+// singleDMARC returns the tags of the only DMARC record among values.
+func singleDMARC(values []string) (map[string]string, bool) {
+	var found map[string]string
+	for _, value := range values {
+		tags, ok := parseDMARC(value)
+		if !ok {
+			continue
+		}
+		if found != nil {
+			return nil, false
+		}
+		found = tags
+	}
+	return found, found != nil
+}
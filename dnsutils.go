@@ -3,7 +3,17 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"hash"
+	"net"
 	"os"
 	"strings"
 	"time"
@@ -12,55 +22,287 @@ import (
 	"github.com/miekg/dns"
 )
 
-// queryDNSWithRetry performs a DNS query with a specified number of retries.
-// It returns the DNS message response or an error if all retries fail.
-func queryDNSWithRetry(fqdn string, qtype uint16, server string, retries int) (*dns.Msg, error) {
+// QueryOptions controls how individual DNS queries are issued.
+type QueryOptions struct {
+	// Retries is the number of attempts made before giving up on a query.
+	Retries int
+	// ClientSubnet, when set, is attached to queries as an EDNS0 client-subnet option.
+	ClientSubnet *dns.EDNS0_SUBNET
+	// ServfailRetries is the number of extra attempts made when a server answers
+	// SERVFAIL, e.g. while a zone is being reloaded.
+	ServfailRetries int
+	// ServfailBackoff is the delay before the first SERVFAIL retry; it doubles on
+	// every further attempt.
+	ServfailBackoff time.Duration
+	// Connections, when set, sends queries over pooled connections kept open per server.
+	Connections *connPool
+	// TLS sends queries over DNS-over-TLS on port 853 instead of plain DNS on port 53.
+	TLS bool
+	// TLSServerNames maps servers to the name their DNS-over-TLS certificate is
+	// verified against. Other servers are verified against their own name.
+	TLSServerNames map[string]string
+	// TCP sends queries over TCP instead of UDP.
+	TCP bool
+	// DoHURL, when set, sends queries over DNS-over-HTTPS to this URL template,
+	// in which "{server}" is replaced with the server name.
+	DoHURL string
+	// Context, when set, cancels queries that have not been sent yet once it is done.
+	Context context.Context
+	// Telemetry, when set, records the latency of every query and the spans of the run.
+	Telemetry *telemetry
+	// TSIGKey, when set, signs every query with TSIG, for servers that refuse
+	// unsigned queries.
+	TSIGKey *TSIGKey
+	// RecursionDesired sets the RD bit, for validating through forwarders or
+	// resolvers rather than directly against authoritative servers.
+	RecursionDesired bool
+	// RetryBudget, when set, caps the retries made across all queries of the run.
+	RetryBudget *retryBudget
+}
+
+// recursive returns a copy of the options with RecursionDesired set, for
+// queries that are always sent to resolvers.
+func (o QueryOptions) recursive() QueryOptions {
+	o.RecursionDesired = true
+	return o
+}
+
+// context returns the context queries are made under.
+func (o QueryOptions) context() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+// usesTCP reports whether queries are sent over a stream transport rather than UDP.
+func (o QueryOptions) usesTCP() bool {
+	return o.TLS || o.TCP || o.Connections != nil || o.DoHURL != ""
+}
+
+// serverAddress returns the host:port used to query server.
+func (o QueryOptions) serverAddress(server string) string {
+	if o.TLS {
+		return net.JoinHostPort(server, "853")
+	}
+	return net.JoinHostPort(server, "53")
+}
+
+// tlsConfig returns the TLS configuration for DNS-over-TLS queries to server.
+func (o QueryOptions) tlsConfig(server string) *tls.Config {
+	serverName, ok := o.TLSServerNames[server]
+	if !ok {
+		serverName = strings.TrimSuffix(server, ".")
+	}
+	return &tls.Config{ServerName: serverName}
+}
+
+// parseTLSServerNames parses "server=name" pairs into the names DNS-over-TLS
+// servers' certificates are verified against.
+func parseTLSServerNames(pairs []string) (map[string]string, error) {
+	names := make(map[string]string)
+	for _, pair := range pairs {
+		server, name, ok := strings.Cut(pair, "=")
+		server, name = strings.TrimSpace(server), strings.TrimSpace(name)
+		if !ok || server == "" || name == "" {
+			return nil, fmt.Errorf("invalid TLS server name %q (expected server=name)", pair)
+		}
+		names[server] = name
+	}
+	return names, nil
+}
+
+// defaultServfailBackoff is the initial delay between SERVFAIL retries.
+const defaultServfailBackoff = 500 * time.Millisecond
+
+// clientSubnetString renders the configured client subnet in CIDR notation.
+func (o QueryOptions) clientSubnetString() string {
+	if o.ClientSubnet == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%d", o.ClientSubnet.Address, o.ClientSubnet.SourceNetmask)
+}
+
+// parseClientSubnet parses a CIDR such as "192.0.2.0/24" into an EDNS0 client-subnet option.
+func parseClientSubnet(cidr string) (*dns.EDNS0_SUBNET, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client subnet %q: %v", cidr, err)
+	}
+
+	ones, _ := ipNet.Mask.Size()
+	subnet := &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		SourceNetmask: uint8(ones),
+		SourceScope:   0,
+		Address:       ipNet.IP,
+	}
+	if ipNet.IP.To4() != nil {
+		subnet.Family = 1
+		subnet.Address = ipNet.IP.To4()
+	} else {
+		subnet.Family = 2
+	}
+	return subnet, nil
+}
+
+// queryDNSWithRetry performs a DNS query with the configured number of retries.
+// Connection errors and SERVFAIL answers are retried independently; a SERVFAIL
+// that persists across all attempts is returned as an error alongside the response.
+func queryDNSWithRetry(fqdn string, qtype uint16, server string, opts QueryOptions) (*dns.Msg, error) {
 	client := new(dns.Client)
+	switch {
+	case opts.TLS:
+		client.Net = "tcp-tls"
+		client.TLSConfig = opts.tlsConfig(server)
+	case opts.Connections != nil, opts.TCP:
+		client.Net = "tcp"
+	}
+
+	msg := newQueryMsg(fqdn, qtype, opts)
+
+	// The TSIG record must come last, so sign after adding EDNS
+	if opts.TSIGKey != nil {
+		client.TsigProvider = newTSIGKeyring(opts.TSIGKey)
+		msg.SetTsig(dns.CanonicalName(opts.TSIGKey.Name), opts.TSIGKey.Algorithm, 300, time.Now().Unix())
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := exchangeWithRetry(client, msg, server, opts)
+		if err != nil {
+			return resp, err
+		}
+		if resp.Rcode != dns.RcodeServerFailure {
+			return resp, nil
+		}
+		if attempt >= opts.ServfailRetries || opts.context().Err() != nil {
+			if ede := extendedErrorText(resp); ede != "" {
+				return resp, fmt.Errorf("server returned SERVFAIL after %d attempts (%s)", attempt+1, ede)
+			}
+			return resp, fmt.Errorf("server returned SERVFAIL after %d attempts", attempt+1)
+		}
+		if !opts.RetryBudget.take() {
+			return resp, fmt.Errorf("server returned SERVFAIL after %d attempts (retry budget exhausted)", attempt+1)
+		}
+		time.Sleep(opts.ServfailBackoff << attempt)
+	}
+}
+
+// newQueryMsg builds the query for fqdn and qtype that every validator sends.
+// The RD bit comes only from opts.RecursionDesired, which is off by default
+// since servers are normally asked for their own authoritative data.
+func newQueryMsg(fqdn string, qtype uint16, opts QueryOptions) *dns.Msg {
+	msg := &dns.Msg{
+		MsgHdr: dns.MsgHdr{
+			Id:               dns.Id(),
+			RecursionDesired: opts.RecursionDesired,
+		},
+		Question: []dns.Question{
+			{
+				Name:   fqdn,
+				Qtype:  qtype,
+				Qclass: dns.ClassINET,
+			},
+		},
+	}
+
+	// Always signal EDNS so servers can return Extended DNS Errors
+	opt := &dns.OPT{
+		Hdr: dns.RR_Header{
+			Name:   ".",
+			Rrtype: dns.TypeOPT,
+		},
+	}
+	opt.SetUDPSize(dns.DefaultMsgSize)
+	if opts.ClientSubnet != nil {
+		opt.Option = append(opt.Option, opts.ClientSubnet)
+	}
+	msg.Extra = append(msg.Extra, opt)
+	return msg
+}
+
+// lookupFailure describes a query that got no usable answer: the error if
+// there was one, or else the rcode the server answered with.
+func lookupFailure(resp *dns.Msg, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("server answered %s", dns.RcodeToString[resp.Rcode])
+}
+
+// extendedErrorText renders the Extended DNS Errors (RFC 8914) in resp, such as
+// "EDE 7 (Signature Expired): ...", or returns "" if there are none.
+func extendedErrorText(resp *dns.Msg) string {
+	if resp == nil {
+		return ""
+	}
+	opt := resp.IsEdns0()
+	if opt == nil {
+		return ""
+	}
+
+	var errs []string
+	for _, option := range opt.Option {
+		ede, ok := option.(*dns.EDNS0_EDE)
+		if !ok {
+			continue
+		}
+		text := fmt.Sprintf("EDE %d", ede.InfoCode)
+		if name, ok := dns.ExtendedErrorCodeToString[ede.InfoCode]; ok {
+			text += fmt.Sprintf(" (%s)", name)
+		}
+		if ede.ExtraText != "" {
+			text += ": " + ede.ExtraText
+		}
+		errs = append(errs, text)
+	}
+	return strings.Join(errs, "; ")
+}
+
+// exchangeWithRetry sends msg to server, retrying on connection errors.
+func exchangeWithRetry(client *dns.Client, msg *dns.Msg, server string, opts QueryOptions) (*dns.Msg, error) {
 	var resp *dns.Msg
 	var err error
 
-	for i := 0; i < retries; i++ {
-		resp, _, err = client.Exchange(&dns.Msg{
-			MsgHdr: dns.MsgHdr{
-				RecursionDesired: true,
-			},
-			Question: []dns.Question{
-				{
-					Name:   fqdn,
-					Qtype:  qtype,
-					Qclass: dns.ClassINET,
-				},
-			},
-		}, server+":53")
+	ctx := opts.context()
+	address := opts.serverAddress(server)
+	for i := 0; i < opts.Retries; i++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if i > 0 && !opts.RetryBudget.take() {
+			return resp, fmt.Errorf("failed to query DNS after %d attempts (retry budget exhausted): %v", i, err)
+		}
+		start := time.Now()
+		if opts.DoHURL != "" {
+			resp, err = exchangeDoH(ctx, msg, server, opts.DoHURL, opts.TSIGKey)
+		} else if opts.Connections != nil {
+			resp, err = opts.Connections.exchange(client, msg, address)
+		} else {
+			resp, _, err = client.ExchangeContext(ctx, msg, address)
+		}
 
 		if err == nil {
+			opts.Telemetry.recordQuery(server, time.Since(start))
 			return resp, nil
 		}
 	}
 
-	return resp, fmt.Errorf("failed to query DNS after %d retries: %v", retries, err)
+	return resp, fmt.Errorf("failed to query DNS after %d retries: %v", opts.Retries, err)
 }
 
 // performAXFR performs a DNS zone transfer (AXFR) for the specified zone and server.
 // If tsigKey is provided, it uses TSIG authentication.
 func performAXFR(zoneName string, server string, tsigKey *TSIGKey, logger log.Logger) ([]dns.RR, error) {
-	client := new(dns.Client)
-	client.Net = "tcp"
-
-	if tsigKey != nil {
-		client.TsigSecret = map[string]string{dns.Fqdn(tsigKey.Name): tsigKey.Secret}
-	}
-
 	m := new(dns.Msg)
 	m.SetAxfr(dns.Fqdn(zoneName))
 
+	t := new(dns.Transfer)
 	if tsigKey != nil {
-		m.SetTsig(dns.Fqdn(tsigKey.Name), tsigKey.Algorithm, 300, time.Now().Unix())
+		t.TsigProvider = newTSIGKeyring(tsigKey)
+		m.SetTsig(dns.CanonicalName(tsigKey.Name), tsigKey.Algorithm, 300, time.Now().Unix())
 	}
 
-	t := new(dns.Transfer)
-	t.TsigSecret = client.TsigSecret
-
 	// Start the transfer
 	envChan, err := t.In(m, server+":53")
 	if err != nil {
@@ -85,7 +327,65 @@ type TSIGKey struct {
 	Algorithm string
 }
 
-// parseTSIGKeyFile parses a BIND-style TSIG keyfile and returns a TSIGKey.
+// tsigKeyring signs and verifies TSIG records with the secrets it holds by
+// canonical key name. Servers may spell the key name of their answers in any
+// case, which the dns package's own keyring, looked up by the exact name, would
+// reject.
+type tsigKeyring map[string]string
+
+// newTSIGKeyring returns a keyring holding key.
+func newTSIGKeyring(key *TSIGKey) tsigKeyring {
+	return tsigKeyring{dns.CanonicalName(key.Name): key.Secret}
+}
+
+// Generate returns the HMAC of msg with the secret of t's key.
+func (k tsigKeyring) Generate(msg []byte, t *dns.TSIG) ([]byte, error) {
+	secret, ok := k[dns.CanonicalName(t.Hdr.Name)]
+	if !ok {
+		return nil, dns.ErrSecret
+	}
+	rawSecret, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var h hash.Hash
+	switch dns.CanonicalName(t.Algorithm) {
+	case dns.HmacSHA1:
+		h = hmac.New(sha1.New, rawSecret)
+	case dns.HmacSHA224:
+		h = hmac.New(sha256.New224, rawSecret)
+	case dns.HmacSHA256:
+		h = hmac.New(sha256.New, rawSecret)
+	case dns.HmacSHA384:
+		h = hmac.New(sha512.New384, rawSecret)
+	case dns.HmacSHA512:
+		h = hmac.New(sha512.New, rawSecret)
+	default:
+		return nil, dns.ErrKeyAlg
+	}
+	h.Write(msg)
+	return h.Sum(nil), nil
+}
+
+// Verify checks t's MAC of msg against the secret of t's key.
+func (k tsigKeyring) Verify(msg []byte, t *dns.TSIG) error {
+	expected, err := k.Generate(msg, t)
+	if err != nil {
+		return err
+	}
+	mac, err := hex.DecodeString(t.MAC)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, mac) {
+		return dns.ErrSig
+	}
+	return nil
+}
+
+// parseTSIGKeyFile parses a BIND-style TSIG keyfile and returns a TSIGKey,
+// with the key name in canonical form: lowercase and fully qualified.
 func parseTSIGKeyFile(filePath string) (*TSIGKey, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -147,7 +447,7 @@ func parseTSIGKeyFile(filePath string) (*TSIGKey, error) {
 	}
 
 	return &TSIGKey{
-		Name:      name,
+		Name:      dns.CanonicalName(name),
 		Secret:    secret,
 		Algorithm: algorithm,
 	}, nil
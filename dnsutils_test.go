@@ -0,0 +1,123 @@
+// dnsutils_test.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestNewQueryMsgRecursionDesired(t *testing.T) {
+	tests := []struct {
+		name string
+		opts QueryOptions
+		want bool
+	}{
+		{"default", QueryOptions{}, false},
+		{"recursion desired", QueryOptions{RecursionDesired: true}, true},
+		{"resolver query", QueryOptions{}.recursive(), true},
+	}
+	for _, tt := range tests {
+		msg := newQueryMsg("www.example.com.", dns.TypeA, tt.opts)
+		if msg.RecursionDesired != tt.want {
+			t.Errorf("%s: RD = %v, want %v", tt.name, msg.RecursionDesired, tt.want)
+		}
+	}
+}
+
+func TestQueryDNSWithRetrySendsRecursionDesired(t *testing.T) {
+	const addr = "127.0.55.1"
+	seen := make(chan bool, 1)
+	serveZone(t, addr, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		seen <- req.RecursionDesired
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		w.WriteMsg(resp)
+	}))
+
+	for _, rd := range []bool{false, true} {
+		if _, err := queryDNSWithRetry("www.example.com.", dns.TypeA, addr, QueryOptions{Retries: 1, RecursionDesired: rd}); err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+		if got := <-seen; got != rd {
+			t.Errorf("with RecursionDesired %v the server saw RD %v", rd, got)
+		}
+	}
+}
+
+// testTSIGSecret is the base64 secret of the TSIG keys used in tests.
+const testTSIGSecret = "c2VjcmV0LWZvci10ZXN0aW5nLXRzaWctc2lnbmluZw=="
+
+func TestParseTSIGKeyFileCanonicalName(t *testing.T) {
+	keyfile := filepath.Join(t.TempDir(), "tsig.key")
+	content := "key \"Transfer-Key.Example\" {\n\talgorithm hmac-sha256;\n\tsecret \"" + testTSIGSecret + "\";\n};\n"
+	if err := os.WriteFile(keyfile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := parseTSIGKeyFile(keyfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Name != "transfer-key.example." || key.Algorithm != dns.HmacSHA256 {
+		t.Errorf("got key %q with algorithm %q, want transfer-key.example. with %s", key.Name, key.Algorithm, dns.HmacSHA256)
+	}
+}
+
+func TestQueryDNSWithRetryTSIGKeyNameCase(t *testing.T) {
+	// However the server spells the key name in its answers, they must verify
+	for i, answerName := range []string{"mykey.", "MyKey.", "MYKEY."} {
+		t.Run(answerName, func(t *testing.T) {
+			addr := fmt.Sprintf("127.0.55.%d", i+2)
+			secrets := map[string]string{"mykey.": testTSIGSecret, answerName: testTSIGSecret}
+			signed := make(chan error, 1)
+			serveZoneTSIG(t, addr, dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+				signed <- w.TsigStatus()
+				resp := new(dns.Msg)
+				resp.SetReply(req)
+				resp.SetTsig(answerName, dns.HmacSHA256, 300, time.Now().Unix())
+				w.WriteMsg(resp)
+			}), secrets)
+
+			key := &TSIGKey{Name: "MyKey", Secret: testTSIGSecret, Algorithm: dns.HmacSHA256}
+			if _, err := queryDNSWithRetry("www.example.com.", dns.TypeA, addr, QueryOptions{Retries: 1, TCP: true, TSIGKey: key}); err != nil {
+				t.Errorf("signed query failed: %v", err)
+			}
+			if err := <-signed; err != nil {
+				t.Errorf("server could not verify the query: %v", err)
+			}
+		})
+	}
+}
+
+func TestTLSConfigServerName(t *testing.T) {
+	names, err := parseTLSServerNames([]string{"192.0.2.53=dns.example.net", " ns2 = ns2.example.com "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := QueryOptions{TLS: true, TLSServerNames: names}
+	tests := []struct {
+		server, want string
+	}{
+		// Servers listed by address or short name verify against the mapped name
+		{"192.0.2.53", "dns.example.net"},
+		{"ns2", "ns2.example.com"},
+		// Others verify against their own name
+		{"ns1.example.com", "ns1.example.com"},
+		{"ns1.example.com.", "ns1.example.com"},
+	}
+	for _, tt := range tests {
+		if got := opts.tlsConfig(tt.server).ServerName; got != tt.want {
+			t.Errorf("tlsConfig(%q).ServerName = %q, want %q", tt.server, got, tt.want)
+		}
+	}
+
+	for _, pair := range []string{"192.0.2.53", "=dns.example.net", "192.0.2.53="} {
+		if _, err := parseTLSServerNames([]string{pair}); err == nil {
+			t.Errorf("parseTLSServerNames accepted %q", pair)
+		}
+	}
+}
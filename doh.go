@@ -0,0 +1,91 @@
+// doh.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// defaultDoHURLTemplate is the DNS-over-HTTPS endpoint queried for a server when
+// no template is configured. "{server}" is replaced with the server name.
+const defaultDoHURLTemplate = "https://{server}/dns-query"
+
+// dohTimeout bounds each DNS-over-HTTPS request.
+const dohTimeout = 5 * time.Second
+
+// dohContentType is the media type of DNS wire-format messages (RFC 8484).
+const dohContentType = "application/dns-message"
+
+// dohURL expands a DoH URL template for server.
+func dohURL(template, server string) string {
+	return strings.ReplaceAll(template, "{server}", server)
+}
+
+// exchangeDoH sends msg to server over DNS-over-HTTPS (RFC 8484) as a POST of the
+// wire-format message, and parses the wire-format answer. A msg carrying a TSIG
+// record is signed with tsigKey, and a signed answer is verified with it.
+func exchangeDoH(ctx context.Context, msg *dns.Msg, server, template string, tsigKey *TSIGKey) (*dns.Msg, error) {
+	// The message ID is zero on the wire so HTTP caches can share answers
+	query := msg.Copy()
+	query.Id = 0
+
+	var packed []byte
+	var requestMAC string
+	var keyring tsigKeyring
+	var err error
+	if tsig := query.IsTsig(); tsig != nil && tsigKey != nil {
+		// Packing alone would send the TSIG record without its MAC
+		keyring = newTSIGKeyring(tsigKey)
+		tsig.OrigId = query.Id
+		packed, requestMAC, err = dns.TsigGenerateWithProvider(query, keyring, "", false)
+	} else {
+		packed, err = query.Pack()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack DoH query: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", dohURL(template, server), bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohContentType)
+	req.Header.Set("Accept", dohContentType)
+
+	client := &http.Client{Timeout: dohTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH server returned status code %d (%s)", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, dohContentType) {
+		return nil, fmt.Errorf("DoH server returned unexpected content type %q", contentType)
+	}
+
+	answer := new(dns.Msg)
+	if err := answer.Unpack(body); err != nil {
+		return nil, fmt.Errorf("failed to parse DoH response: %v", err)
+	}
+	if keyring != nil && answer.IsTsig() != nil {
+		if err := dns.TsigVerifyWithProvider(body, keyring, requestMAC, false); err != nil {
+			return nil, fmt.Errorf("DoH response failed TSIG verification: %v", err)
+		}
+	}
+	answer.Id = msg.Id
+	return answer, nil
+}
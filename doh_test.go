@@ -0,0 +1,68 @@
+// doh_test.go
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// serveDoHTSIG serves DNS-over-HTTPS answers signed with serverKey, and fails
+// the test if a query doesn't verify with it.
+func serveDoHTSIG(t *testing.T, serverKey *TSIGKey) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := new(dns.Msg)
+		if err := req.Unpack(body); err != nil {
+			t.Errorf("unparsable DoH query: %v", err)
+			return
+		}
+		tsig := req.IsTsig()
+		if tsig == nil {
+			t.Errorf("DoH query is not signed")
+			return
+		}
+		if err := dns.TsigVerifyWithProvider(body, newTSIGKeyring(&TSIGKey{Name: serverKey.Name, Secret: testTSIGSecret}), "", false); err != nil {
+			t.Errorf("DoH query failed TSIG verification: %v", err)
+		}
+
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		resp.SetTsig(serverKey.Name, serverKey.Algorithm, 300, time.Now().Unix())
+		packed, _, err := dns.TsigGenerateWithProvider(resp, newTSIGKeyring(serverKey), tsig.MAC, false)
+		if err != nil {
+			t.Errorf("failed to sign DoH answer: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", dohContentType)
+		w.Write(packed)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/dns-query"
+}
+
+func TestExchangeDoHTSIG(t *testing.T) {
+	key := &TSIGKey{Name: "mykey.", Secret: testTSIGSecret, Algorithm: dns.HmacSHA256}
+	query := func(url string) error {
+		msg := newQueryMsg("www.example.com.", dns.TypeA, QueryOptions{})
+		msg.SetTsig(key.Name, key.Algorithm, 300, time.Now().Unix())
+		_, err := exchangeDoH(context.Background(), msg, "ns1.example.com", url, key)
+		return err
+	}
+
+	if err := query(serveDoHTSIG(t, key)); err != nil {
+		t.Errorf("signed DoH query failed: %v", err)
+	}
+
+	// An answer signed with another secret must not be accepted
+	forged := &TSIGKey{Name: key.Name, Secret: "Zm9yZ2VkLXNlY3JldC1mb3ItdGVzdGluZy10c2ln", Algorithm: key.Algorithm}
+	if err := query(serveDoHTSIG(t, forged)); err == nil {
+		t.Errorf("DoH answer with a bad signature was accepted")
+	}
+}
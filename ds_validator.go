@@ -0,0 +1,174 @@
+// ds_validator.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// validateDSRecords validates NetBox DS records against the DS set served by the
+// nameservers of the parent zone, where the delegation's chain of trust lives.
+// Like other records, DS records are only validated in the zone and view given
+// by zoneFilter and viewFilter, if set.
+func validateDSRecords(records []Record, servers []string, logger log.Logger, zoneViewToNameservers map[string][]string, zoneFilter, viewFilter string, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	var wg sync.WaitGroup
+	var findings findingSet
+
+	// Group DS records by child FQDN and view
+	expectedRecords := make(map[RecordKey][]Record)
+	for _, record := range records {
+		if strings.ToUpper(record.Type) != "DS" {
+			continue
+		}
+		if zoneFilter != "" && record.ZoneName != zoneFilter {
+			continue
+		}
+		if viewFilter != "" && record.ViewName != viewFilter {
+			continue
+		}
+		key := RecordKey{
+			FQDN:       record.FQDN,
+			RecordType: "DS",
+			ViewName:   record.ViewName,
+		}
+		expectedRecords[key] = append(expectedRecords[key], record)
+	}
+
+	for key, records := range expectedRecords {
+		wg.Add(1)
+		go func(key RecordKey, records []Record) {
+			defer wg.Done()
+
+			parentZone, parentServers := findParentZoneServers(key.FQDN, key.ViewName, zoneViewToNameservers)
+			if len(parentServers) == 0 {
+				level.Warn(logger).Log("msg", "No parent zone nameservers found for DS record, skipping validation", "fqdn", key.FQDN, "view", key.ViewName)
+				return
+			}
+			key.ZoneName = parentZone
+
+			discrepancies, successfulValidations := validateDSRecordSet(key, records, parentServers, logger, opts)
+			findings.add(discrepancies, successfulValidations, nil)
+		}(key, records)
+	}
+
+	wg.Wait()
+	return findings.discrepancies, findings.successful
+}
+
+// validateDSRecordSet compares the expected DS set for a child against each parent nameserver.
+func validateDSRecordSet(key RecordKey, records []Record, servers []string, logger log.Logger, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	expectedValues := []string{}
+	for _, record := range records {
+		expectedValues = append(expectedValues, normalizeDSValue(record.Value))
+	}
+
+	var discrepancies []Discrepancy
+	var successfulValidations []ValidationRecord
+
+	for _, server := range servers {
+		level.Debug(logger).Log("msg", "Validating DS records at parent", "fqdn", key.FQDN, "parent", key.ZoneName, "server", server)
+		opts.Throttle.acquire(server)
+		resp, err := queryDNSWithRetry(key.FQDN, dns.TypeDS, server, opts.Query)
+		opts.Throttle.release(server)
+		if err != nil {
+			level.Warn(logger).Log("msg", "DNS query error", "fqdn", key.FQDN, "server", server, "err", err)
+			discrepancy := Discrepancy{
+				FQDN:       key.FQDN,
+				RecordType: "DS",
+				ZoneName:   key.ZoneName,
+				Expected:   expectedValues,
+				Server:     server,
+				Message:    fmt.Sprintf("DNS query error: %v", err),
+				Category:   CategoryQueryError,
+			}
+			discrepancies = append(discrepancies, discrepancy)
+			continue
+		}
+
+		actualValues := []string{}
+		for _, ans := range resp.Answer {
+			if rr, ok := ans.(*dns.DS); ok {
+				actualValues = append(actualValues, extractRRValue(rr))
+			}
+		}
+
+		if len(actualValues) == 0 {
+			level.Warn(logger).Log("msg", "DS record missing at parent", "fqdn", key.FQDN, "server", server)
+			discrepancy := Discrepancy{
+				FQDN:       key.FQDN,
+				RecordType: "DS",
+				ZoneName:   key.ZoneName,
+				Expected:   expectedValues,
+				Actual:     actualValues,
+				Server:     server,
+				Message:    "DS record missing at parent (chain of trust broken)",
+				Category:   CategoryMissing,
+			}
+			discrepancies = append(discrepancies, discrepancy)
+			continue
+		}
+
+		if !opts.valuesMatch("DS", expectedValues, actualValues) {
+			level.Warn(logger).Log("msg", "DS record at parent does not match NetBox", "fqdn", key.FQDN, "server", server)
+			discrepancy := Discrepancy{
+				FQDN:       key.FQDN,
+				RecordType: "DS",
+				ZoneName:   key.ZoneName,
+				Expected:   expectedValues,
+				Actual:     actualValues,
+				Server:     server,
+				Message:    "DS record at parent is stale (chain of trust broken)",
+				Category:   CategoryMismatch,
+			}
+			discrepancies = append(discrepancies, discrepancy)
+			continue
+		}
+
+		if len(actualValues) > len(expectedValues) {
+			level.Info(logger).Log("msg", "Parent serves DS records not in NetBox, assuming a key rollover in progress", "fqdn", key.FQDN, "server", server, "expected", len(expectedValues), "actual", len(actualValues))
+		}
+		level.Info(logger).Log("msg", "DS records validated successfully at parent", "fqdn", key.FQDN, "server", server)
+		if opts.RecordSuccessful {
+			validationRecord := ValidationRecord{
+				FQDN:       key.FQDN,
+				RecordType: "DS",
+				ZoneName:   key.ZoneName,
+				Expected:   expectedValues,
+				Actual:     actualValues,
+				Server:     server,
+				Message:    "DS record validated successfully at parent",
+			}
+			successfulValidations = append(successfulValidations, validationRecord)
+		}
+	}
+
+	tagClientSubnet(discrepancies, opts.Query)
+	opts.FailFast.record(discrepancies)
+	return discrepancies, successfulValidations
+}
+
+// normalizeDSValue renders a DS value as "keytag algorithm digest-type DIGEST",
+// collapsing whitespace and upper-casing the hex digest as dns.DS does.
+func normalizeDSValue(value string) string {
+	parts := strings.Fields(value)
+	if len(parts) < 4 {
+		return strings.Join(parts, " ")
+	}
+	digest := strings.ToUpper(strings.Join(parts[3:], ""))
+	return fmt.Sprintf("%s %s %s %s", parts[0], parts[1], parts[2], digest)
+}
+
+// normalizeDNSKEYValue renders a DNSKEY value as "flags protocol algorithm key",
+// joining a public key that was split across several fields.
+func normalizeDNSKEYValue(value string) string {
+	parts := strings.Fields(value)
+	if len(parts) < 4 {
+		return strings.Join(parts, " ")
+	}
+	return fmt.Sprintf("%s %s %s %s", parts[0], parts[1], parts[2], strings.Join(parts[3:], ""))
+}
@@ -0,0 +1,44 @@
+// ds_validator_test.go
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func TestValidateDSRecordsFilters(t *testing.T) {
+	const addr = "127.0.53.10"
+	// The parent serves no DS records, so every DS record validated is a finding
+	serveZone(t, addr, newTestZone(t, "example.com",
+		"child 3600 IN NS ns1.child",
+	))
+
+	internal := testRecord("child", "DS", "12345 13 2 0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF", 3600)
+	internal.ViewName = "internal"
+	records := []Record{testRecord("child", "DS", "12345 13 2 0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF", 3600), internal}
+	zoneViewToNameservers := map[string][]string{
+		zoneViewKey("example.com", "default"):  {addr},
+		zoneViewKey("example.com", "internal"): {addr},
+	}
+
+	tests := []struct {
+		zoneFilter, viewFilter string
+		want                   int
+	}{
+		{"", "", 2},
+		{"", "default", 1},
+		{"example.com", "", 2},
+		{"example.org", "", 0},
+	}
+	for _, tt := range tests {
+		var got []Discrepancy
+		finishWithin(t, 10*time.Second, func() {
+			got, _ = validateDSRecords(records, []string{addr}, log.NewNopLogger(), zoneViewToNameservers, tt.zoneFilter, tt.viewFilter, ValidationOptions{Query: QueryOptions{Retries: 1}})
+		})
+		if len(got) != tt.want {
+			t.Errorf("zone %q, view %q: got findings %v, want %d", tt.zoneFilter, tt.viewFilter, categories(got), tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// exclude.go
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// serverExclusion leaves the nameservers matching --exclude-nameserver out of
+// every validation, so a server under maintenance doesn't flood the report. It
+// remembers how many excluded servers each zone and view lost, to count the
+// record validations that were skipped.
+type serverExclusion struct {
+	patterns []string
+	excluded map[string]bool
+	// removed is the number of excluded servers per zoneViewKey.
+	removed map[string]int
+	skipped int64
+}
+
+// newServerExclusion returns a serverExclusion for the given server names or
+// glob patterns, or nil if there are none.
+func newServerExclusion(patterns []string) *serverExclusion {
+	if len(patterns) == 0 {
+		return nil
+	}
+	return &serverExclusion{
+		patterns: patterns,
+		excluded: make(map[string]bool),
+		removed:  make(map[string]int),
+	}
+}
+
+// excludes reports whether server matches one of the patterns. A nil
+// serverExclusion excludes nothing.
+func (e *serverExclusion) excludes(server string) bool {
+	if e == nil {
+		return false
+	}
+	server = strings.TrimSuffix(server, ".")
+	for _, pattern := range e.patterns {
+		if matchIgnorePattern(pattern, server) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterServers returns the servers that are not excluded.
+func (e *serverExclusion) filterServers(servers []string) []string {
+	if e == nil {
+		return servers
+	}
+
+	var kept []string
+	for _, server := range servers {
+		if e.excludes(server) {
+			e.excluded[server] = true
+			continue
+		}
+		kept = append(kept, server)
+	}
+	return kept
+}
+
+// filterNameservers returns the nameservers that are not excluded, for the
+// zone transfers, which pick their servers from the NetBox nameservers.
+func (e *serverExclusion) filterNameservers(nameservers []Nameserver) []Nameserver {
+	if e == nil {
+		return nameservers
+	}
+
+	var kept []Nameserver
+	for _, ns := range nameservers {
+		if e.excludes(ns.Name) {
+			e.excluded[ns.Name] = true
+			continue
+		}
+		kept = append(kept, ns)
+	}
+	return kept
+}
+
+// filterZoneViews returns a copy of zoneViewToNameservers without the excluded
+// servers, counting how many each zone and view lost. The original map is left
+// alone, as the NetBox data checks still look at every assignment.
+func (e *serverExclusion) filterZoneViews(zoneViewToNameservers map[string][]string) map[string][]string {
+	if e == nil {
+		return zoneViewToNameservers
+	}
+
+	filtered := make(map[string][]string, len(zoneViewToNameservers))
+	for key, servers := range zoneViewToNameservers {
+		kept := e.filterServers(servers)
+		if removed := len(servers) - len(kept); removed > 0 {
+			e.removed[key] += removed
+		}
+		filtered[key] = kept
+	}
+	return filtered
+}
+
+// countSkipped adds one skipped validation per excluded server of each
+// record's zone and view.
+func (e *serverExclusion) countSkipped(records []Record) {
+	if e == nil {
+		return
+	}
+
+	var skipped int64
+	for _, record := range records {
+		skipped += int64(e.removed[zoneViewKey(record.ZoneName, record.ViewName)])
+	}
+	atomic.AddInt64(&e.skipped, skipped)
+}
+
+// servers returns the excluded servers, sorted.
+func (e *serverExclusion) servers() []string {
+	var servers []string
+	for server := range e.excluded {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+	return servers
+}
+
+// report logs which servers were excluded and how many record validations that
+// skipped, warning when the patterns matched no server, which usually means a
+// typo.
+func (e *serverExclusion) report(logger log.Logger) {
+	if e == nil {
+		return
+	}
+	if len(e.excluded) == 0 {
+		level.Warn(logger).Log("msg", "No nameservers match --exclude-nameserver", "patterns", strings.Join(e.patterns, ", "))
+		return
+	}
+	level.Info(logger).Log("msg", "Skipped validations on excluded nameservers", "servers", strings.Join(e.servers(), ", "), "zone_views", len(e.removed), "skipped", atomic.LoadInt64(&e.skipped))
+}
@@ -0,0 +1,77 @@
+// failfast.go
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// failFast cancels the run as soon as the first reportable discrepancy is found
+// and keeps that discrepancy for the report. Discrepancies that the ignore file
+// suppresses or that fall below the minimum severity don't stop the run.
+type failFast struct {
+	cancel        context.CancelFunc
+	ignoreRules   IgnoreRules
+	severityRules SeverityRules
+	minSeverity   string
+
+	mu    sync.Mutex
+	first *Discrepancy
+}
+
+// newFailFast returns a failFast that stops the run by calling cancel.
+func newFailFast(cancel context.CancelFunc, ignoreRules IgnoreRules, severityRules SeverityRules, minSeverity string) *failFast {
+	return &failFast{
+		cancel:        cancel,
+		ignoreRules:   ignoreRules,
+		severityRules: severityRules,
+		minSeverity:   minSeverity,
+	}
+}
+
+// reportable reports whether a discrepancy would make it into the report.
+func (f *failFast) reportable(d Discrepancy) bool {
+	now := time.Now()
+	for _, rule := range f.ignoreRules {
+		if !rule.expired(now) && rule.matches(d) {
+			return false
+		}
+	}
+	return severityRanks[f.severityRules.severityFor(d)] >= severityRanks[f.minSeverity]
+}
+
+// record notes newly found discrepancies, cancelling the run on the first
+// reportable one. It is safe to call on a nil failFast.
+func (f *failFast) record(discrepancies []Discrepancy) {
+	if f == nil || len(discrepancies) == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.first != nil {
+		return
+	}
+	for _, d := range discrepancies {
+		if f.reportable(d) {
+			f.first = &d
+			f.cancel()
+			return
+		}
+	}
+}
+
+// result returns the first reportable discrepancy found, if any.
+func (f *failFast) result() (Discrepancy, bool) {
+	if f == nil {
+		return Discrepancy{}, false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.first == nil {
+		return Discrepancy{}, false
+	}
+	return *f.first, true
+}
@@ -0,0 +1,110 @@
+// fielddiff.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// recordFieldNames names the fields of the multi-field record types whose
+// mismatches are described field by field. The target host comes last.
+var recordFieldNames = map[string][]string{
+	"MX":  {"preference", "exchange"},
+	"SRV": {"priority", "weight", "port", "target"},
+}
+
+// fieldDifference describes which fields differ between the SRV or MX values
+// NetBox expects and those served, pairing values by their target host, e.g.
+// "SRV fields differ: sip.example.com.: weight is 20, expected 10". It returns
+// "" for other types or when no value differs.
+func fieldDifference(recordType string, expected, actual []string) string {
+	names, ok := recordFieldNames[recordType]
+	if !ok {
+		return ""
+	}
+
+	expectedByTarget := valuesByTarget(expected, len(names))
+	actualByTarget := valuesByTarget(actual, len(names))
+
+	var differences []string
+	for _, target := range sortedTargets(expectedByTarget) {
+		expectedValues := unmatchedValues(expectedByTarget[target], actualByTarget[target])
+		actualValues := unmatchedValues(actualByTarget[target], expectedByTarget[target])
+
+		// A single differing value on each side is the same record with changed fields
+		if len(expectedValues) == 1 && len(actualValues) == 1 {
+			var changed []string
+			for i, name := range names {
+				if expectedValues[0][i] != actualValues[0][i] {
+					changed = append(changed, fmt.Sprintf("%s is %s, expected %s", name, actualValues[0][i], expectedValues[0][i]))
+				}
+			}
+			if len(changed) > 0 {
+				differences = append(differences, fmt.Sprintf("%s: %s", target, strings.Join(changed, ", ")))
+			}
+			continue
+		}
+		for _, fields := range expectedValues {
+			differences = append(differences, "missing "+strings.Join(fields, " "))
+		}
+		for _, fields := range actualValues {
+			differences = append(differences, "unexpected "+strings.Join(fields, " "))
+		}
+	}
+	for _, target := range sortedTargets(actualByTarget) {
+		if _, ok := expectedByTarget[target]; ok {
+			continue
+		}
+		for _, fields := range actualByTarget[target] {
+			differences = append(differences, "unexpected "+strings.Join(fields, " "))
+		}
+	}
+
+	if len(differences) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s fields differ: %s", recordType, strings.Join(differences, "; "))
+}
+
+// valuesByTarget splits values into their fields and groups them by target
+// host. Values without the expected number of fields are left out.
+func valuesByTarget(values []string, fieldCount int) map[string][][]string {
+	byTarget := make(map[string][][]string)
+	for _, value := range values {
+		fields := strings.Fields(value)
+		if len(fields) != fieldCount {
+			continue
+		}
+		fields[fieldCount-1] = strings.ToLower(fields[fieldCount-1])
+		target := fields[fieldCount-1]
+		byTarget[target] = append(byTarget[target], fields)
+	}
+	return byTarget
+}
+
+// unmatchedValues returns the values of a that are not also in b.
+func unmatchedValues(a, b [][]string) [][]string {
+	in := make(map[string]bool)
+	for _, fields := range b {
+		in[strings.Join(fields, " ")] = true
+	}
+
+	var unmatched [][]string
+	for _, fields := range a {
+		if !in[strings.Join(fields, " ")] {
+			unmatched = append(unmatched, fields)
+		}
+	}
+	return unmatched
+}
+
+// sortedTargets returns the target hosts of byTarget in order.
+func sortedTargets(byTarget map[string][][]string) []string {
+	targets := make([]string, 0, len(byTarget))
+	for target := range byTarget {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets
+}
@@ -0,0 +1,113 @@
+// fqdnfilter.go
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// fqdnFilter keeps only the records whose FQDN matches a regular expression,
+// for spot-checking a subset of names such as everything under api.example.com,
+// and lies within one of the given subtrees, for teams owning part of a larger
+// zone. It counts the records it sees across all batches, so a filter matching
+// nothing can be reported once at the end of the run.
+type fqdnFilter struct {
+	re       *regexp.Regexp
+	subtrees []string
+	total    int64
+	matched  int64
+}
+
+// newFQDNFilter compiles pattern and the subtrees into an fqdnFilter. Either may
+// be empty; it returns nil if both are.
+func newFQDNFilter(pattern string, subtrees []string) (*fqdnFilter, error) {
+	if pattern == "" && len(subtrees) == 0 {
+		return nil, nil
+	}
+
+	f := &fqdnFilter{}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FQDN pattern %q: %v", pattern, err)
+		}
+		f.re = re
+	}
+	for _, subtree := range subtrees {
+		subtree = strings.ToLower(dns.Fqdn(strings.TrimSpace(subtree)))
+		if _, ok := dns.IsDomainName(subtree); !ok || subtree == "." {
+			return nil, fmt.Errorf("invalid subtree %q", subtree)
+		}
+		f.subtrees = append(f.subtrees, subtree)
+	}
+	return f, nil
+}
+
+// matches reports whether fqdn matches the filter. A nil fqdnFilter matches everything.
+func (f *fqdnFilter) matches(fqdn string) bool {
+	if f == nil {
+		return true
+	}
+	if f.re != nil && !f.re.MatchString(fqdn) {
+		return false
+	}
+	if len(f.subtrees) == 0 {
+		return true
+	}
+	name := strings.ToLower(dns.Fqdn(fqdn))
+	for _, subtree := range f.subtrees {
+		if dns.IsSubDomain(subtree, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply returns the records whose FQDN matches the filter.
+func (f *fqdnFilter) apply(records []Record) []Record {
+	if f == nil {
+		return records
+	}
+
+	var kept []Record
+	for _, record := range records {
+		if f.matches(record.FQDN) {
+			kept = append(kept, record)
+		}
+	}
+	atomic.AddInt64(&f.total, int64(len(records)))
+	atomic.AddInt64(&f.matched, int64(len(kept)))
+	return kept
+}
+
+// String describes the filter for the log.
+func (f *fqdnFilter) String() string {
+	var parts []string
+	if f.re != nil {
+		parts = append(parts, fmt.Sprintf("pattern %s", f.re.String()))
+	}
+	if len(f.subtrees) > 0 {
+		parts = append(parts, fmt.Sprintf("subtrees %s", strings.Join(f.subtrees, ", ")))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// report logs how many records matched, warning when none did, which usually
+// means the pattern or subtree has a typo.
+func (f *fqdnFilter) report(logger log.Logger) {
+	if f == nil {
+		return
+	}
+	total, matched := atomic.LoadInt64(&f.total), atomic.LoadInt64(&f.matched)
+	if matched == 0 {
+		level.Warn(logger).Log("msg", "No records match the FQDN filter", "filter", f.String(), "records", total)
+		return
+	}
+	level.Info(logger).Log("msg", "Records matching the FQDN filter", "filter", f.String(), "matched", matched, "records", total)
+}
@@ -0,0 +1,125 @@
+// ignore.go
+package main
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/spf13/viper"
+)
+
+// ignoreDateLayout is the layout of suppression expiry dates.
+const ignoreDateLayout = "2006-01-02"
+
+// IgnoreRule suppresses the discrepancies matching its FQDN, type and server
+// glob patterns. An empty pattern matches anything. A rule with an expiry date
+// stops applying once that day has passed.
+type IgnoreRule struct {
+	FQDN    string `mapstructure:"fqdn"`
+	Type    string `mapstructure:"type"`
+	Server  string `mapstructure:"server"`
+	Expires string `mapstructure:"expires"`
+	Reason  string `mapstructure:"reason"`
+
+	expiry time.Time
+}
+
+// IgnoreRules are the suppressions loaded from an ignore file.
+type IgnoreRules []IgnoreRule
+
+// loadIgnoreFile reads the suppressions listed under "ignore" in a YAML file.
+func loadIgnoreFile(file string) (IgnoreRules, error) {
+	ignoreConfig := viper.New()
+	ignoreConfig.SetConfigType("yaml")
+	ignoreConfig.SetConfigFile(file)
+	if err := ignoreConfig.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read ignore file: %v", err)
+	}
+
+	var rules IgnoreRules
+	if err := ignoreConfig.UnmarshalKey("ignore", &rules, viper.DecodeHook(dateToStringHook)); err != nil {
+		return nil, fmt.Errorf("failed to parse ignore file: %v", err)
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		for _, pattern := range []string{rule.FQDN, rule.Type, rule.Server} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("ignore rule %d: invalid pattern %q", i+1, pattern)
+			}
+		}
+		if rule.Expires != "" {
+			expiry, err := time.ParseInLocation(ignoreDateLayout, rule.Expires, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("ignore rule %d: invalid expiry date %q (expected YYYY-MM-DD)", i+1, rule.Expires)
+			}
+			rule.expiry = expiry
+		}
+	}
+
+	return rules, nil
+}
+
+// dateToStringHook formats the timestamps YAML makes of unquoted dates, so expiry
+// dates may be written with or without quotes.
+func dateToStringHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if t, ok := data.(time.Time); ok && to.Kind() == reflect.String {
+		return t.Format(ignoreDateLayout), nil
+	}
+	return data, nil
+}
+
+// expired reports whether the rule's expiry day is over at now.
+func (r IgnoreRule) expired(now time.Time) bool {
+	return !r.expiry.IsZero() && !now.Before(r.expiry.AddDate(0, 0, 1))
+}
+
+// matches reports whether the rule suppresses a discrepancy.
+func (r IgnoreRule) matches(d Discrepancy) bool {
+	return matchIgnorePattern(r.FQDN, strings.TrimSuffix(d.FQDN, ".")) &&
+		matchIgnorePattern(r.Type, d.RecordType) &&
+		matchIgnorePattern(r.Server, d.Server)
+}
+
+// matchIgnorePattern matches a value against a case-insensitive glob pattern.
+func matchIgnorePattern(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	matched, _ := path.Match(pattern, strings.ToLower(value))
+	return matched
+}
+
+// filterIgnored drops the discrepancies suppressed by a rule that has not expired.
+func filterIgnored(discrepancies []Discrepancy, rules IgnoreRules, now time.Time, logger log.Logger) []Discrepancy {
+	var active IgnoreRules
+	for _, rule := range rules {
+		if rule.expired(now) {
+			level.Info(logger).Log("msg", "Ignore rule has expired", "fqdn", rule.FQDN, "type", rule.Type, "server", rule.Server, "expires", rule.Expires)
+			continue
+		}
+		active = append(active, rule)
+	}
+
+	var kept []Discrepancy
+	for _, d := range discrepancies {
+		ignored := false
+		for _, rule := range active {
+			if rule.matches(d) {
+				level.Debug(logger).Log("msg", "Ignoring discrepancy", "fqdn", d.FQDN, "type", d.RecordType, "server", d.Server, "reason", rule.Reason)
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,202 @@
+// lame_validator.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// validateDelegations checks that every nameserver NetBox lists for the zones of
+// records answers authoritatively for the zone apex. A server that doesn't is a
+// lame delegation.
+func validateDelegations(records []Record, servers []string, logger log.Logger, zoneViewToNameservers map[string][]string, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	var wg sync.WaitGroup
+	var findings findingSet
+
+	// Each zone and view is checked once, however many records it holds
+	zones := make(map[RecordKey]bool)
+	for _, record := range records {
+		if record.ZoneName == "" || record.ViewName == "" {
+			continue
+		}
+		zones[RecordKey{FQDN: dns.Fqdn(record.ZoneName), RecordType: "SOA", ZoneName: record.ZoneName, ViewName: record.ViewName}] = true
+	}
+
+	for key := range zones {
+		zoneServers := zoneViewToNameservers[zoneViewKey(key.ZoneName, key.ViewName)]
+		if len(zoneServers) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func(key RecordKey, zoneServers []string) {
+			defer wg.Done()
+
+			discrepancies, successfulValidations := validateDelegation(key, zoneServers, logger, opts)
+			findings.add(discrepancies, successfulValidations, nil)
+		}(key, zoneServers)
+	}
+
+	wg.Wait()
+	return findings.discrepancies, findings.successful
+}
+
+// validateDelegation queries the apex SOA of a zone on each of its servers and
+// reports the servers that don't answer authoritatively.
+func validateDelegation(key RecordKey, servers []string, logger log.Logger, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	var discrepancies []Discrepancy
+	var successfulValidations []ValidationRecord
+
+	for _, server := range servers {
+		level.Debug(logger).Log("msg", "Checking delegation", "zone", key.ZoneName, "server", server)
+		opts.Throttle.acquire(server)
+		resp, err := queryDNSWithRetry(key.FQDN, dns.TypeSOA, server, opts.Query)
+		opts.Throttle.release(server)
+		if err != nil && resp == nil {
+			// No answer at all is a connectivity problem, not a lame server
+			level.Warn(logger).Log("msg", "DNS query error", "zone", key.ZoneName, "server", server, "err", err)
+			discrepancy := Discrepancy{
+				FQDN:       key.FQDN,
+				RecordType: "SOA",
+				ZoneName:   key.ZoneName,
+				Server:     server,
+				Message:    fmt.Sprintf("DNS query error: %v", err),
+				Category:   CategoryQueryError,
+			}
+			discrepancies = append(discrepancies, discrepancy)
+			continue
+		}
+
+		if reason := lameReason(key.FQDN, resp); reason != "" {
+			level.Warn(logger).Log("msg", "Lame delegation", "zone", key.ZoneName, "server", server, "reason", reason)
+			discrepancy := Discrepancy{
+				FQDN:       key.FQDN,
+				RecordType: "SOA",
+				ZoneName:   key.ZoneName,
+				Server:     server,
+				Message:    fmt.Sprintf("Lame delegation: %s", reason),
+				Category:   CategoryLameDelegation,
+			}
+			discrepancies = append(discrepancies, discrepancy)
+			continue
+		}
+
+		level.Info(logger).Log("msg", "Server is authoritative for zone", "zone", key.ZoneName, "server", server)
+		if opts.RecordSuccessful {
+			validationRecord := ValidationRecord{
+				FQDN:       key.FQDN,
+				RecordType: "SOA",
+				ZoneName:   key.ZoneName,
+				Server:     server,
+				Message:    "Server answers authoritatively for zone",
+			}
+			successfulValidations = append(successfulValidations, validationRecord)
+		}
+	}
+
+	tagClientSubnet(discrepancies, opts.Query)
+	opts.FailFast.record(discrepancies)
+	return discrepancies, successfulValidations
+}
+
+// lameReason explains why resp is not an authoritative answer for the apex SOA
+// of zone, or returns "" if it is one.
+func lameReason(zone string, resp *dns.Msg) string {
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Sprintf("server answered %s", dns.RcodeToString[resp.Rcode])
+	}
+	if !resp.Authoritative {
+		return "answer is not authoritative (AA bit not set)"
+	}
+	for _, ans := range resp.Answer {
+		if soa, ok := ans.(*dns.SOA); ok && strings.EqualFold(dns.Fqdn(soa.Hdr.Name), zone) {
+			return ""
+		}
+	}
+	return "no SOA record for the zone apex in the answer"
+}
+
+// restrictToAuthoritative asks each nameserver NetBox lists for a zone whether
+// it is authoritative for it, and narrows the zone's servers to those that
+// confirm, so stale nameserver assignments don't produce a flood of record
+// discrepancies. Servers that deny authority are reported as lame; servers that
+// can't be reached are kept, so the query errors surface during validation.
+// Only zones matching zoneFilter and viewFilter, when set, are checked.
+func restrictToAuthoritative(zoneViewToNameservers map[string][]string, zonesMap map[int]Zone, zoneFilter, viewFilter string, logger log.Logger, opts ValidationOptions) (map[string][]string, []Discrepancy) {
+	restricted := make(map[string][]string, len(zoneViewToNameservers))
+	for key, servers := range zoneViewToNameservers {
+		restricted[key] = servers
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var discrepancies []Discrepancy
+	for _, zone := range zonesMap {
+		viewName := zoneViewName(zone)
+		if (zoneFilter != "" && zone.Name != zoneFilter) || (viewFilter != "" && viewName != viewFilter) {
+			continue
+		}
+		key := zoneViewKey(zone.Name, viewName)
+		servers := zoneViewToNameservers[key]
+		if len(servers) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func(zoneName, viewName, key string, servers []string) {
+			defer wg.Done()
+
+			authoritative, denied := authoritativeServers(zoneName, servers, logger, opts)
+			mu.Lock()
+			defer mu.Unlock()
+			restricted[key] = authoritative
+			discrepancies = append(discrepancies, denied...)
+			if len(denied) > 0 {
+				level.Info(logger).Log("msg", "Validating zone against the servers that confirm authority", "zone", zoneName, "view", viewName, "servers", strings.Join(authoritative, ", "), "denied", len(denied))
+			}
+		}(zone.Name, viewName, key, servers)
+	}
+	wg.Wait()
+
+	tagClientSubnet(discrepancies, opts.Query)
+	return restricted, discrepancies
+}
+
+// authoritativeServers returns the servers that answer the apex SOA of zoneName
+// authoritatively, or can't be asked, along with a lame delegation discrepancy
+// for each server that denies authority.
+func authoritativeServers(zoneName string, servers []string, logger log.Logger, opts ValidationOptions) ([]string, []Discrepancy) {
+	fqdn := dns.Fqdn(zoneName)
+	var authoritative []string
+	var discrepancies []Discrepancy
+	for _, server := range servers {
+		opts.Throttle.acquire(server)
+		resp, err := queryDNSWithRetry(fqdn, dns.TypeSOA, server, opts.Query)
+		opts.Throttle.release(server)
+		if err != nil && resp == nil {
+			level.Warn(logger).Log("msg", "Could not ask server whether it is authoritative, keeping it", "zone", zoneName, "server", server, "err", err)
+			authoritative = append(authoritative, server)
+			continue
+		}
+
+		if reason := lameReason(fqdn, resp); reason != "" {
+			level.Warn(logger).Log("msg", "Server denies authority for zone, not validating against it", "zone", zoneName, "server", server, "reason", reason)
+			discrepancies = append(discrepancies, Discrepancy{
+				FQDN:       fqdn,
+				RecordType: "SOA",
+				ZoneName:   zoneName,
+				Server:     server,
+				Message:    fmt.Sprintf("NetBox lists server for the zone, but it denies authority: %s", reason),
+				Category:   CategoryLameDelegation,
+			})
+			continue
+		}
+		authoritative = append(authoritative, server)
+	}
+	return authoritative, discrepancies
+}
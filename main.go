@@ -2,11 +2,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"os"
 	"path"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -15,8 +18,15 @@ import (
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run validates NetBox against DNS and returns the exit code. Keeping the work
+// out of main lets deferred cleanup, such as closing pooled connections, run
+// on every exit path.
+func run() int {
 	var (
-		configFile           string
+		configFiles          []string
 		apiURL               string
 		apiToken             string
 		apiTokenFile         string
@@ -30,20 +40,104 @@ func main() {
 		zoneFilter           string
 		viewFilter           string
 		nameserverFilter     string
+		tenantFilter         string
 		recordSuccessful     bool
 		successfulReportFile string
 		missingReportFile    string
 		useAXFR              bool
 		tsigKeyFile          string
+		maxQueriesPerServer  int
+		validateDS           bool
+		clientSubnet         string
+		servfailRetries      int
+		minTTL               int
+		maxTTL               int
+		checkDisabledPTR     bool
+		includeInactive      bool
+		nsApexTTLFromSOA     bool
+		resolvers            []string
+		quorum               int
+		compareTTLOnly       bool
+		reuseConnections     bool
+		connectionPoolSize   int
+		dnsOverTLS           bool
+		tlsServerNamePairs   []string
+		ttlTolerance         int
+		reportNearMisses     bool
+		reportTemplate       string
+		streamRecords        bool
+		maxConcurrency       int
+		minSeverity          string
+		serialLagTolerance   int
+		cacheDump            string
+		apexOnly             bool
+		caseSensitive        bool
+		checkLame            bool
+		checkDelegationTTL   bool
+		delegationTTL        int
+		primaryOnly          bool
+		baselineFile         string
+		resolvedReportFile   string
+		ignoreFile           string
+		checkSOAMName        bool
+		confirmOverTCP       bool
+		checkCNAMETargets    bool
+		checkMXTargets       bool
+		failFastEnabled      bool
+		exactKeySets         bool
+		checkReverseZones    bool
+		otlpEndpoint         string
+		hiddenPrimary        string
+		hiddenPrimaryLag     int
+		checkRecordCounts    bool
+		recordCountTolerance int
+		tsigQueries          bool
+		noNSUpdate           bool
+		exitZero             bool
+		warnOnly             bool
+		dnsProtocol          string
+		dohURLTemplate       string
+		skipManaged          bool
+		onlyManaged          bool
+		checkRRsetTTLs       bool
+		recursionDesired     bool
+		dumpComparisons      string
+		autoTransport        bool
+		summaryFile          string
+		netboxDNSPath        string
+		fqdnRegex            string
+		syslogEnabled        bool
+		syslogNetwork        string
+		syslogAddress        string
+		maxTotalRetries      int
+		defaultViewOnly      bool
+		checkNetBox          bool
+		dmarcAware           bool
+		successfulTypes      []string
+		authoritativeOnly    bool
+		checkNSEC            bool
+		serialReportFile     string
+		axfrCrossCheck       bool
+		crossCheckSample     int
+		samplePercent        float64
+		sampleSeed           int64
+		netboxHeaderPairs    []string
+		remediationFile      string
+		selfCheckOnly        bool
+		excludeNameservers   []string
+		subtrees             []string
+		serverMapFile        string
+		compareDisplay       bool
 		showHelp             bool
 	)
 
 	// Define command-line flags with short versions
-	pflag.StringVarP(&configFile, "config", "c", "", "Path to the configuration file (default: ./config.yaml)")
+	pflag.StringSliceVarP(&configFiles, "config", "c", nil, "Path to a configuration file; repeat or comma-separate to merge several, later files overriding earlier ones (default: ./config.yaml)")
 	pflag.StringVarP(&apiURL, "api-url", "u", "", "NetBox API root URL (e.g., https://netbox.example.com/)")
 	pflag.StringVarP(&apiToken, "api-token", "t", "", "NetBox API token")
-	pflag.StringVarP(&apiTokenFile, "api-token-file", "T", "", "Path to the NetBox API token file")
-	pflag.StringVarP(&reportFile, "report-file", "r", "bad.report", "File to write the discrepancy report")
+	pflag.StringArrayVar(&netboxHeaderPairs, "netbox-header", nil, "Extra header sent with every NetBox request as name=value, e.g. for an authenticating proxy; repeatable")
+	pflag.StringVarP(&apiTokenFile, "api-token-file", "T", "", "Path to the NetBox API token file ('-' to read the first line of standard input)")
+	pflag.StringVarP(&reportFile, "report-file", "r", "bad.report", "File to write the discrepancy report ('-' for stdout)")
 	pflag.StringVarP(&reportFormat, "report-format", "f", "table", "Format of the report (table, csv, json)")
 	pflag.StringVarP(&nsupdatePath, "nsupdate-path", "p", "out", "Directory to write nsupdate commands")
 	pflag.BoolVarP(&ignoreSerialNumbers, "ignore-serial-numbers", "i", true, "Ignore serial numbers when comparing SOA records")
@@ -53,11 +147,95 @@ func main() {
 	pflag.StringVarP(&zoneFilter, "zone", "z", "", "Filter by zone name")
 	pflag.StringVarP(&viewFilter, "view", "v", "", "Filter by view name")
 	pflag.StringVarP(&nameserverFilter, "nameserver", "N", "", "Filter by nameserver")
+	pflag.StringSliceVar(&excludeNameservers, "exclude-nameserver", nil, "Comma-separated nameservers or glob patterns to leave out of every validation, e.g. for a server under maintenance")
+	pflag.StringVar(&tenantFilter, "tenant", "", "Filter records, zones and nameservers by NetBox tenant slug")
 	pflag.BoolVarP(&recordSuccessful, "record-successful", "R", false, "Record successful validations")
-	pflag.StringVarP(&successfulReportFile, "successful-report-file", "S", "good.report", "File to write successful validations report")
-	pflag.StringVarP(&missingReportFile, "missing-report-file", "M", "missing.report", "File to write records found in DNS but missing from NetBox")
+	pflag.StringSliceVar(&successfulTypes, "record-successful-types", nil, "Comma-separated record types whose successful validations are recorded, e.g. SOA,NS; implies --record-successful")
+	pflag.StringVarP(&successfulReportFile, "successful-report-file", "S", "good.report", "File to write successful validations report ('-' for stdout)")
+	pflag.StringVarP(&missingReportFile, "missing-report-file", "M", "missing.report", "File to write records found in DNS but missing from NetBox ('-' for stdout)")
 	pflag.BoolVarP(&useAXFR, "use-axfr", "a", false, "Use AXFR zone transfer for validation")
 	pflag.StringVarP(&tsigKeyFile, "tsig-keyfile", "k", "", "Path to the TSIG keyfile for AXFR")
+	pflag.IntVar(&maxQueriesPerServer, "max-queries-per-server", 0, "Maximum concurrent in-flight queries to any single DNS server (0 for unlimited)")
+	pflag.BoolVar(&validateDS, "validate-ds", false, "Validate DS records against the parent zone's nameservers")
+	pflag.StringVar(&clientSubnet, "ecs", "", "EDNS client subnet to attach to queries (e.g., 192.0.2.0/24)")
+	pflag.IntVar(&servfailRetries, "servfail-retries", 2, "Number of retries with backoff when a server answers SERVFAIL")
+	pflag.IntVar(&minTTL, "min-ttl", 0, "Flag NetBox records whose TTL is below this value (0 to disable)")
+	pflag.IntVar(&maxTTL, "max-ttl", 0, "Flag NetBox records whose TTL is above this value (0 to disable)")
+	pflag.BoolVar(&checkDisabledPTR, "check-disabled-ptr", false, "Verify that A/AAAA records with PTR disabled in NetBox have no PTR record")
+	pflag.BoolVar(&includeInactive, "include-disabled", false, "Validate records NetBox marks as disabled like any other, instead of checking that they are absent from DNS")
+	pflag.BoolVar(&includeInactive, "include-inactive", false, "Alias of --include-disabled")
+	pflag.CommandLine.MarkDeprecated("include-inactive", "use --include-disabled instead")
+	pflag.BoolVar(&nsApexTTLFromSOA, "ns-apex-ttl-from-soa", true, "Expect apex NS records without their own TTL to use the zone's SOA TTL")
+	pflag.StringSliceVar(&resolvers, "resolvers", nil, "Comma-separated list of recursive resolvers to check for propagation consensus")
+	pflag.IntVar(&quorum, "quorum", 0, "Number of resolvers that must disagree with NetBox to report a discrepancy (0 for a majority)")
+	pflag.BoolVar(&compareTTLOnly, "compare-ttl-only", false, "Only report TTL drift, where values match but TTLs differ")
+	pflag.BoolVar(&reuseConnections, "reuse-connections", false, "Query over TCP, keeping pooled connections open per DNS server")
+	pflag.IntVar(&connectionPoolSize, "connection-pool-size", defaultConnPoolSize, "Maximum idle connections kept per DNS server with --reuse-connections")
+	pflag.BoolVar(&dnsOverTLS, "dns-over-tls", false, "Query DNS servers over DNS-over-TLS (port 853)")
+	pflag.StringArrayVar(&tlsServerNamePairs, "tls-server-name", nil, "Name a DNS-over-TLS server's certificate is verified against, as server=name, e.g. for servers listed by address; repeatable")
+	pflag.IntVar(&ttlTolerance, "ttl-tolerance", 0, "TTL drift in seconds still accepted as a match")
+	pflag.BoolVar(&reportNearMisses, "report-near-misses", false, "Write validations that passed only within --ttl-tolerance to the successful validations report")
+	pflag.StringVar(&reportTemplate, "report-template", "", "Go text/template file used to render the discrepancy report instead of --report-format")
+	pflag.BoolVar(&streamRecords, "stream", false, "Validate each zone as soon as its records are fetched instead of fetching all records first")
+	pflag.IntVar(&maxConcurrency, "max-concurrency", defaultMaxConcurrency, "Maximum number of zones validated at once with --stream, and of SOA records validated at once")
+	pflag.StringVar(&minSeverity, "min-severity", SeverityInfo, "Only report discrepancies at or above this severity (critical, warning, info)")
+	pflag.IntVar(&serialLagTolerance, "serial-lag-tolerance", 0, "Maximum SOA serial difference allowed between the servers of a zone")
+	pflag.StringVar(&cacheDump, "cache-dump", "", "Validate against an Unbound cache dump (unbound-control dump_cache) instead of querying DNS servers")
+	pflag.BoolVar(&apexOnly, "apex-only", false, "Only validate records at each zone apex (SOA, NS, apex A/MX/TXT, ...)")
+	pflag.BoolVar(&caseSensitive, "compare-case-sensitive", false, "Compare all record values case-sensitively, including host names")
+	pflag.BoolVar(&checkLame, "check-lame-delegations", false, "Report nameservers that don't answer authoritatively for the zones NetBox assigns them")
+	pflag.BoolVar(&checkDelegationTTL, "check-delegation-ttl", false, "Report parent zones delegating with an NS TTL other than --delegation-ttl or the apex NS TTL in NetBox")
+	pflag.IntVar(&delegationTTL, "delegation-ttl", 0, "NS TTL expected on delegations at the parent zone with --check-delegation-ttl (default: the apex NS TTL in NetBox)")
+	pflag.BoolVar(&primaryOnly, "primary-only", false, "Validate each zone only against the primary nameserver named in its SOA MName")
+	pflag.BoolVar(&authoritativeOnly, "authoritative-only", false, "Validate each zone only against the NetBox nameservers that answer its SOA authoritatively, reporting those that deny authority")
+	pflag.StringVar(&baselineFile, "baseline", "", "Previous JSON discrepancy report; only report discrepancies not in it and list resolved ones")
+	pflag.StringVar(&resolvedReportFile, "resolved-report-file", "resolved.report", "File to write baseline discrepancies that are resolved ('-' for stdout)")
+	pflag.StringVar(&ignoreFile, "ignore-file", "", "YAML file of discrepancies to suppress, optionally until an expiry date")
+	pflag.BoolVar(&checkSOAMName, "check-soa-mname", false, "During SOA validation, check that the SOA MName host resolves and answers authoritatively")
+	pflag.BoolVar(&confirmOverTCP, "confirm-over-tcp", false, "Repeat queries whose UDP answer differs from NetBox over TCP and only report discrepancies that persist")
+	pflag.BoolVar(&checkCNAMETargets, "check-cname-targets", false, "Report CNAME records whose target does not resolve (dangling CNAMEs)")
+	pflag.BoolVar(&checkMXTargets, "check-mx-targets", false, "Report MX records whose exchange host has no A or AAAA record")
+	pflag.BoolVar(&failFastEnabled, "fail-fast", false, "Stop the run at the first discrepancy and report only that one")
+	pflag.BoolVar(&exactKeySets, "exact-key-sets", false, "Require DS and DNSKEY sets to match NetBox exactly instead of allowing the extra keys of a rollover")
+	pflag.BoolVar(&checkReverseZones, "check-reverse-zones", false, "Report A/AAAA records whose address has no reverse zone, in NetBox or DNS, to hold its PTR")
+	pflag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP collector to export run spans and DNS query metrics to (e.g., http://localhost:4318)")
+	pflag.StringVar(&hiddenPrimary, "hidden-primary", "", "Primary server not listed in NetBox to compare the zones' SOA serials with during SOA validation")
+	pflag.IntVar(&hiddenPrimaryLag, "hidden-primary-tolerance", 0, "Maximum SOA serial difference by which servers may trail the hidden primary")
+	pflag.BoolVar(&checkRecordCounts, "check-record-counts", false, "With --use-axfr, flag zones whose record count differs wildly from NetBox's")
+	pflag.StringVar(&remediationFile, "remediation-file", "", "File to write a summary of the changes the discrepancies call for, grouped by action and zone ('-' for stdout)")
+	pflag.StringVar(&serialReportFile, "serial-report", "", "File to write each zone's NetBox SOA serial next to every server's, flagging outliers ('-' for stdout)")
+	pflag.StringVar(&serverMapFile, "dump-server-map", "", "File to write the nameservers each zone and view will be validated against, before validating ('-' for stdout)")
+	pflag.BoolVar(&axfrCrossCheck, "axfr-cross-check", false, "With --use-axfr, also query each server directly for the records it transferred and report those it answers differently")
+	pflag.IntVar(&crossCheckSample, "axfr-cross-check-sample", 100, "Percentage of transferred RRsets queried with --axfr-cross-check")
+	pflag.BoolVar(&checkNSEC, "check-nsec", false, "With --use-axfr, verify that the NSEC or NSEC3 chain of signed zones is complete and correctly linked")
+	pflag.IntVar(&recordCountTolerance, "record-count-tolerance", defaultRecordCountTolerance, "Percentage by which a zone's AXFR record count may differ from NetBox's with --check-record-counts")
+	pflag.BoolVar(&tsigQueries, "tsig-queries", false, "Sign every DNS query, not just zone transfers, with the --tsig-keyfile key")
+	pflag.BoolVar(&noNSUpdate, "no-nsupdate", false, "Don't write nsupdate scripts")
+	pflag.BoolVar(&exitZero, "exit-zero", false, "Exit with status 0 even when --baseline or --fail-fast find discrepancies")
+	pflag.BoolVar(&warnOnly, "warn-only", false, "Only report: implies --no-nsupdate and --exit-zero and logs every finding as a warning")
+	pflag.StringVar(&dnsProtocol, "dns-protocol", "udp", "Protocol used to query DNS servers (udp, tcp, tls, https)")
+	pflag.StringVar(&dohURLTemplate, "doh-url", defaultDoHURLTemplate, "DNS-over-HTTPS URL template for --dns-protocol https; {server} is replaced with the server name")
+	pflag.BoolVar(&skipManaged, "skip-managed", false, "Don't validate the PTR records NetBox generates for address records")
+	pflag.BoolVar(&onlyManaged, "only-managed", false, "Only validate records NetBox manages itself, such as generated PTRs")
+	pflag.BoolVar(&checkRRsetTTLs, "check-rrset-ttls", false, "Report RRsets whose records have different TTLs in NetBox")
+	pflag.BoolVar(&recursionDesired, "recursion-desired", false, "Set the RD bit on queries, for validating through forwarders or resolvers instead of authoritative servers")
+	pflag.StringVar(&dumpComparisons, "dump-comparisons", "", "Write every comparison made, with expected and actual values, timing and outcome, to this JSON Lines file ('-' for stdout)")
+	pflag.BoolVar(&autoTransport, "auto-transport", false, "Pick UDP or TCP per query by record type and expected answer size; see transport_by_type in the config file")
+	pflag.StringVar(&summaryFile, "summary-file", "", "Write a JSON rollup of the results (counts by category, severity, zone and type, pass/fail) to this file ('-' for stdout)")
+	pflag.StringVar(&netboxDNSPath, "netbox-dns-path", defaultNetBoxDNSPath, "Path of the netbox-dns plugin API, relative to --api-url")
+	pflag.StringVar(&fqdnRegex, "fqdn-regex", "", "Only validate records whose FQDN matches this regular expression")
+	pflag.StringSliceVar(&subtrees, "subtree", nil, "Only validate records at or below these names, e.g. svc.prod.example.com, whichever NetBox zone holds them")
+	pflag.Float64Var(&samplePercent, "sample-percent", 0, "Only validate this percentage of the selected RRsets, for quick smoke tests")
+	pflag.Int64Var(&sampleSeed, "sample-seed", 0, "Seed picking the --sample-percent sample, to reproduce a run (default: random)")
+	pflag.BoolVar(&syslogEnabled, "syslog", false, "Send each discrepancy to syslog as a structured message, leveled by severity")
+	pflag.StringVar(&syslogNetwork, "syslog-network", "", "Network for --syslog: udp, tcp or unix (default: the local syslog daemon)")
+	pflag.StringVar(&syslogAddress, "syslog-address", "", "Address of the syslog server for --syslog, e.g. loghost:514")
+	pflag.IntVar(&maxTotalRetries, "max-total-retries", 0, "Maximum number of query retries across the whole run; once spent, failures are reported without retrying (0 for no limit)")
+	pflag.BoolVar(&defaultViewOnly, "default-view-only", false, "Only validate zones and records in the view NetBox marks as the default view")
+	pflag.BoolVar(&checkNetBox, "check-netbox", false, "Check NetBox data for internal consistency before querying DNS and report the issues found")
+	pflag.BoolVar(&compareDisplay, "compare-display", false, "Validate records against the value in NetBox's display field where it has one, and report records whose raw value and display disagree")
+	pflag.BoolVar(&dmarcAware, "dmarc-aware", false, "Compare DMARC TXT records tag by tag, ignoring tag order and spacing, and report which tags differ")
+	pflag.BoolVar(&selfCheckOnly, "check", false, "Check NetBox connectivity, the API token, the netbox-dns plugin and DNS reachability of one nameserver, then exit without validating")
 	pflag.BoolVarP(&showHelp, "help", "h", false, "Display help message")
 	pflag.Parse()
 
@@ -65,7 +243,7 @@ func main() {
 	if showHelp {
 		fmt.Println("Usage of netbox-dnsverify:")
 		pflag.PrintDefaults()
-		os.Exit(0)
+		return 0
 	}
 
 	// Initialize Viper
@@ -74,16 +252,25 @@ func main() {
 	viper.AddConfigPath(".")      // Search in current directory
 	viper.AddConfigPath("/etc/netbox-dnsverify/")
 
-	// If a config file is specified, use it
-	if configFile != "" {
-		viper.SetConfigFile(configFile)
+	// If config files are specified, merge them in order; otherwise search for one
+	if len(configFiles) == 0 {
+		if err := viper.ReadInConfig(); err != nil {
+			level.Warn(log.NewNopLogger()).Log("msg", "No config file found, using defaults and other sources", "err", err)
+		} else {
+			loadedConfigFile(viper.ConfigFileUsed())
+		}
 	}
-
-	// Read the config file if available
-	if err := viper.ReadInConfig(); err != nil {
-		level.Warn(log.NewNopLogger()).Log("msg", "No config file found, using defaults and other sources", "err", err)
-	} else {
-		level.Info(log.NewNopLogger()).Log("msg", "Using config file", "file", viper.ConfigFileUsed())
+	for i, file := range configFiles {
+		viper.SetConfigFile(file)
+		read := viper.MergeInConfig
+		if i == 0 {
+			read = viper.ReadInConfig
+		}
+		if err := read(); err != nil {
+			level.Warn(log.NewNopLogger()).Log("msg", "Failed to read config file, skipping", "file", file, "err", err)
+			continue
+		}
+		loadedConfigFile(file)
 	}
 
 	// Bind environment variables (standardized names)
@@ -95,6 +282,7 @@ func main() {
 	viper.BindEnv("api_url")
 	viper.BindEnv("api_token")
 	viper.BindEnv("api_token_file")
+	viper.BindEnv("netbox_header")
 	viper.BindEnv("dns_servers")
 	viper.BindEnv("report_file")
 	viper.BindEnv("report_format")
@@ -106,17 +294,100 @@ func main() {
 	viper.BindEnv("zone")
 	viper.BindEnv("view")
 	viper.BindEnv("nameserver")
+	viper.BindEnv("exclude_nameserver")
+	viper.BindEnv("tenant")
 	viper.BindEnv("record_successful")
 	viper.BindEnv("successful_report_file")
 	viper.BindEnv("missing_report_file")
 	viper.BindEnv("use_axfr")
 	viper.BindEnv("tsig_keyfile")
+	viper.BindEnv("max_queries_per_server")
+	viper.BindEnv("validate_ds")
+	viper.BindEnv("ecs")
+	viper.BindEnv("servfail_retries")
+	viper.BindEnv("min_ttl")
+	viper.BindEnv("max_ttl")
+	viper.BindEnv("check_disabled_ptr")
+	viper.BindEnv("include_disabled")
+	viper.BindEnv("include_inactive")
+	viper.BindEnv("ns_apex_ttl_from_soa")
+	viper.BindEnv("resolvers")
+	viper.BindEnv("quorum")
+	viper.BindEnv("compare_ttl_only")
+	viper.BindEnv("reuse_connections")
+	viper.BindEnv("connection_pool_size")
+	viper.BindEnv("dns_over_tls")
+	viper.BindEnv("tls_server_name")
+	viper.BindEnv("ttl_tolerance")
+	viper.BindEnv("report_near_misses")
+	viper.BindEnv("report_template")
+	viper.BindEnv("stream")
+	viper.BindEnv("max_concurrency")
+	viper.BindEnv("min_severity")
+	viper.BindEnv("serial_lag_tolerance")
+	viper.BindEnv("cache_dump")
+	viper.BindEnv("apex_only")
+	viper.BindEnv("compare_case_sensitive")
+	viper.BindEnv("check_lame_delegations")
+	viper.BindEnv("check_delegation_ttl")
+	viper.BindEnv("delegation_ttl")
+	viper.BindEnv("primary_only")
+	viper.BindEnv("baseline")
+	viper.BindEnv("resolved_report_file")
+	viper.BindEnv("ignore_file")
+	viper.BindEnv("check_soa_mname")
+	viper.BindEnv("confirm_over_tcp")
+	viper.BindEnv("check_cname_targets")
+	viper.BindEnv("check_mx_targets")
+	viper.BindEnv("fail_fast")
+	viper.BindEnv("exact_key_sets")
+	viper.BindEnv("check_reverse_zones")
+	viper.BindEnv("otlp_endpoint")
+	viper.BindEnv("hidden_primary")
+	viper.BindEnv("hidden_primary_tolerance")
+	viper.BindEnv("check_record_counts")
+	viper.BindEnv("record_count_tolerance")
+	viper.BindEnv("tsig_queries")
+	viper.BindEnv("no_nsupdate")
+	viper.BindEnv("exit_zero")
+	viper.BindEnv("warn_only")
+	viper.BindEnv("dns_protocol")
+	viper.BindEnv("doh_url")
+	viper.BindEnv("skip_managed")
+	viper.BindEnv("only_managed")
+	viper.BindEnv("check_rrset_ttls")
+	viper.BindEnv("recursion_desired")
+	viper.BindEnv("dump_comparisons")
+	viper.BindEnv("auto_transport")
+	viper.BindEnv("summary_file")
+	viper.BindEnv("netbox_dns_path")
+	viper.BindEnv("fqdn_regex")
+	viper.BindEnv("subtree")
+	viper.BindEnv("sample_percent")
+	viper.BindEnv("sample_seed")
+	viper.BindEnv("syslog")
+	viper.BindEnv("syslog_network")
+	viper.BindEnv("syslog_address")
+	viper.BindEnv("max_total_retries")
+	viper.BindEnv("default_view_only")
+	viper.BindEnv("check_netbox")
+	viper.BindEnv("compare_display")
+	viper.BindEnv("dmarc_aware")
+	viper.BindEnv("record_successful_types")
+	viper.BindEnv("authoritative_only")
+	viper.BindEnv("check_nsec")
+	viper.BindEnv("serial_report")
+	viper.BindEnv("dump_server_map")
+	viper.BindEnv("remediation_file")
+	viper.BindEnv("axfr_cross_check")
+	viper.BindEnv("axfr_cross_check_sample")
 
 	// Set default values from flags (lowest precedence)
-	viper.SetDefault("config", configFile)
+	viper.SetDefault("config", configFiles)
 	viper.SetDefault("api_url", apiURL)
 	viper.SetDefault("api_token", apiToken)
 	viper.SetDefault("api_token_file", apiTokenFile)
+	viper.SetDefault("netbox_header", netboxHeaderPairs)
 	viper.SetDefault("report_file", reportFile)
 	viper.SetDefault("report_format", reportFormat)
 	viper.SetDefault("nsupdate_path", nsupdatePath)
@@ -127,20 +398,102 @@ func main() {
 	viper.SetDefault("zone", zoneFilter)
 	viper.SetDefault("view", viewFilter)
 	viper.SetDefault("nameserver", nameserverFilter)
+	viper.SetDefault("exclude_nameserver", excludeNameservers)
+	viper.SetDefault("tenant", tenantFilter)
 	viper.SetDefault("record_successful", recordSuccessful)
 	viper.SetDefault("successful_report_file", successfulReportFile)
 	viper.SetDefault("missing_report_file", missingReportFile)
 	viper.SetDefault("use_axfr", useAXFR)
 	viper.SetDefault("tsig_keyfile", tsigKeyFile)
+	viper.SetDefault("max_queries_per_server", maxQueriesPerServer)
+	viper.SetDefault("validate_ds", validateDS)
+	viper.SetDefault("ecs", clientSubnet)
+	viper.SetDefault("servfail_retries", servfailRetries)
+	viper.SetDefault("min_ttl", minTTL)
+	viper.SetDefault("max_ttl", maxTTL)
+	viper.SetDefault("check_disabled_ptr", checkDisabledPTR)
+	viper.SetDefault("include_disabled", includeInactive)
+	viper.SetDefault("ns_apex_ttl_from_soa", nsApexTTLFromSOA)
+	viper.SetDefault("resolvers", resolvers)
+	viper.SetDefault("quorum", quorum)
+	viper.SetDefault("compare_ttl_only", compareTTLOnly)
+	viper.SetDefault("reuse_connections", reuseConnections)
+	viper.SetDefault("connection_pool_size", connectionPoolSize)
+	viper.SetDefault("dns_over_tls", dnsOverTLS)
+	viper.SetDefault("tls_server_name", tlsServerNamePairs)
+	viper.SetDefault("ttl_tolerance", ttlTolerance)
+	viper.SetDefault("report_near_misses", reportNearMisses)
+	viper.SetDefault("report_template", reportTemplate)
+	viper.SetDefault("stream", streamRecords)
+	viper.SetDefault("max_concurrency", maxConcurrency)
+	viper.SetDefault("min_severity", minSeverity)
+	viper.SetDefault("serial_lag_tolerance", serialLagTolerance)
+	viper.SetDefault("cache_dump", cacheDump)
+	viper.SetDefault("apex_only", apexOnly)
+	viper.SetDefault("compare_case_sensitive", caseSensitive)
+	viper.SetDefault("check_lame_delegations", checkLame)
+	viper.SetDefault("check_delegation_ttl", checkDelegationTTL)
+	viper.SetDefault("delegation_ttl", delegationTTL)
+	viper.SetDefault("primary_only", primaryOnly)
+	viper.SetDefault("baseline", baselineFile)
+	viper.SetDefault("resolved_report_file", resolvedReportFile)
+	viper.SetDefault("ignore_file", ignoreFile)
+	viper.SetDefault("check_soa_mname", checkSOAMName)
+	viper.SetDefault("confirm_over_tcp", confirmOverTCP)
+	viper.SetDefault("check_cname_targets", checkCNAMETargets)
+	viper.SetDefault("check_mx_targets", checkMXTargets)
+	viper.SetDefault("fail_fast", failFastEnabled)
+	viper.SetDefault("exact_key_sets", exactKeySets)
+	viper.SetDefault("check_reverse_zones", checkReverseZones)
+	viper.SetDefault("otlp_endpoint", otlpEndpoint)
+	viper.SetDefault("hidden_primary", hiddenPrimary)
+	viper.SetDefault("hidden_primary_tolerance", hiddenPrimaryLag)
+	viper.SetDefault("check_record_counts", checkRecordCounts)
+	viper.SetDefault("record_count_tolerance", recordCountTolerance)
+	viper.SetDefault("tsig_queries", tsigQueries)
+	viper.SetDefault("no_nsupdate", noNSUpdate)
+	viper.SetDefault("exit_zero", exitZero)
+	viper.SetDefault("warn_only", warnOnly)
+	viper.SetDefault("dns_protocol", dnsProtocol)
+	viper.SetDefault("doh_url", dohURLTemplate)
+	viper.SetDefault("skip_managed", skipManaged)
+	viper.SetDefault("only_managed", onlyManaged)
+	viper.SetDefault("check_rrset_ttls", checkRRsetTTLs)
+	viper.SetDefault("recursion_desired", recursionDesired)
+	viper.SetDefault("dump_comparisons", dumpComparisons)
+	viper.SetDefault("auto_transport", autoTransport)
+	viper.SetDefault("summary_file", summaryFile)
+	viper.SetDefault("netbox_dns_path", netboxDNSPath)
+	viper.SetDefault("fqdn_regex", fqdnRegex)
+	viper.SetDefault("subtree", subtrees)
+	viper.SetDefault("sample_percent", samplePercent)
+	viper.SetDefault("sample_seed", sampleSeed)
+	viper.SetDefault("syslog", syslogEnabled)
+	viper.SetDefault("syslog_network", syslogNetwork)
+	viper.SetDefault("syslog_address", syslogAddress)
+	viper.SetDefault("max_total_retries", maxTotalRetries)
+	viper.SetDefault("default_view_only", defaultViewOnly)
+	viper.SetDefault("check_netbox", checkNetBox)
+	viper.SetDefault("compare_display", compareDisplay)
+	viper.SetDefault("dmarc_aware", dmarcAware)
+	viper.SetDefault("record_successful_types", successfulTypes)
+	viper.SetDefault("authoritative_only", authoritativeOnly)
+	viper.SetDefault("check_nsec", checkNSEC)
+	viper.SetDefault("serial_report", serialReportFile)
+	viper.SetDefault("dump_server_map", serverMapFile)
+	viper.SetDefault("remediation_file", remediationFile)
+	viper.SetDefault("axfr_cross_check", axfrCrossCheck)
+	viper.SetDefault("axfr_cross_check_sample", crossCheckSample)
 
 	// Override environment variables with command-line flags (highest precedence)
 	viper.BindPFlags(pflag.CommandLine)
 
 	// Extract final configuration values
-	configFile = viper.GetString("config")
+	configFiles = viper.GetStringSlice("config")
 	apiURL = viper.GetString("api_url")
 	apiToken = viper.GetString("api_token")
 	apiTokenFile = viper.GetString("api_token_file")
+	netboxHeaderPairs = viper.GetStringSlice("netbox_header")
 	reportFile = viper.GetString("report_file")
 	reportFormat = viper.GetString("report_format")
 	nsupdatePath = viper.GetString("nsupdate_path")
@@ -148,29 +501,131 @@ func main() {
 	validateSOA = viper.GetString("validate_soa")
 	logLevel = viper.GetString("log_level")
 	logFormat = viper.GetString("log_format")
-	zoneFilter = viper.GetString("zone")
+	zoneFilter = canonicalZoneName(viper.GetString("zone"))
 	viewFilter = viper.GetString("view")
 	nameserverFilter = viper.GetString("nameserver")
+	excludeNameservers = viper.GetStringSlice("exclude_nameserver")
+	tenantFilter = viper.GetString("tenant")
 	recordSuccessful = viper.GetBool("record_successful")
 	successfulReportFile = viper.GetString("successful_report_file")
 	missingReportFile = viper.GetString("missing_report_file")
 	useAXFR = viper.GetBool("use_axfr")
 	tsigKeyFile = viper.GetString("tsig_keyfile")
+	maxQueriesPerServer = viper.GetInt("max_queries_per_server")
+	validateDS = viper.GetBool("validate_ds")
+	clientSubnet = viper.GetString("ecs")
+	servfailRetries = viper.GetInt("servfail_retries")
+	minTTL = viper.GetInt("min_ttl")
+	maxTTL = viper.GetInt("max_ttl")
+	checkDisabledPTR = viper.GetBool("check_disabled_ptr")
+	includeInactive = viper.GetBool("include_disabled") || viper.GetBool("include_inactive")
+	nsApexTTLFromSOA = viper.GetBool("ns_apex_ttl_from_soa")
+	resolvers = viper.GetStringSlice("resolvers")
+	quorum = viper.GetInt("quorum")
+	compareTTLOnly = viper.GetBool("compare_ttl_only")
+	reuseConnections = viper.GetBool("reuse_connections")
+	connectionPoolSize = viper.GetInt("connection_pool_size")
+	dnsOverTLS = viper.GetBool("dns_over_tls")
+	tlsServerNamePairs = viper.GetStringSlice("tls_server_name")
+	ttlTolerance = viper.GetInt("ttl_tolerance")
+	reportNearMisses = viper.GetBool("report_near_misses")
+	reportTemplate = viper.GetString("report_template")
+	streamRecords = viper.GetBool("stream")
+	maxConcurrency = viper.GetInt("max_concurrency")
+	minSeverity = viper.GetString("min_severity")
+	serialLagTolerance = viper.GetInt("serial_lag_tolerance")
+	cacheDump = viper.GetString("cache_dump")
+	apexOnly = viper.GetBool("apex_only")
+	caseSensitive = viper.GetBool("compare_case_sensitive")
+	checkLame = viper.GetBool("check_lame_delegations")
+	checkDelegationTTL = viper.GetBool("check_delegation_ttl")
+	delegationTTL = viper.GetInt("delegation_ttl")
+	primaryOnly = viper.GetBool("primary_only")
+	baselineFile = viper.GetString("baseline")
+	resolvedReportFile = viper.GetString("resolved_report_file")
+	ignoreFile = viper.GetString("ignore_file")
+	checkSOAMName = viper.GetBool("check_soa_mname")
+	confirmOverTCP = viper.GetBool("confirm_over_tcp")
+	checkCNAMETargets = viper.GetBool("check_cname_targets")
+	checkMXTargets = viper.GetBool("check_mx_targets")
+	failFastEnabled = viper.GetBool("fail_fast")
+	exactKeySets = viper.GetBool("exact_key_sets")
+	checkReverseZones = viper.GetBool("check_reverse_zones")
+	otlpEndpoint = viper.GetString("otlp_endpoint")
+	hiddenPrimary = viper.GetString("hidden_primary")
+	hiddenPrimaryLag = viper.GetInt("hidden_primary_tolerance")
+	checkRecordCounts = viper.GetBool("check_record_counts")
+	recordCountTolerance = viper.GetInt("record_count_tolerance")
+	tsigQueries = viper.GetBool("tsig_queries")
+	noNSUpdate = viper.GetBool("no_nsupdate")
+	exitZero = viper.GetBool("exit_zero")
+	warnOnly = viper.GetBool("warn_only")
+	dnsProtocol = viper.GetString("dns_protocol")
+	dohURLTemplate = viper.GetString("doh_url")
+	skipManaged = viper.GetBool("skip_managed")
+	onlyManaged = viper.GetBool("only_managed")
+	checkRRsetTTLs = viper.GetBool("check_rrset_ttls")
+	recursionDesired = viper.GetBool("recursion_desired")
+	dumpComparisons = viper.GetString("dump_comparisons")
+	autoTransport = viper.GetBool("auto_transport")
+	summaryFile = viper.GetString("summary_file")
+	netboxDNSPath = viper.GetString("netbox_dns_path")
+	fqdnRegex = viper.GetString("fqdn_regex")
+	subtrees = viper.GetStringSlice("subtree")
+	samplePercent = viper.GetFloat64("sample_percent")
+	sampleSeed = viper.GetInt64("sample_seed")
+	syslogEnabled = viper.GetBool("syslog")
+	syslogNetwork = viper.GetString("syslog_network")
+	syslogAddress = viper.GetString("syslog_address")
+	maxTotalRetries = viper.GetInt("max_total_retries")
+	defaultViewOnly = viper.GetBool("default_view_only")
+	checkNetBox = viper.GetBool("check_netbox")
+	compareDisplay = viper.GetBool("compare_display")
+	dmarcAware = viper.GetBool("dmarc_aware")
+	successfulTypes = viper.GetStringSlice("record_successful_types")
+	authoritativeOnly = viper.GetBool("authoritative_only")
+	checkNSEC = viper.GetBool("check_nsec")
+	serialReportFile = viper.GetString("serial_report")
+	serverMapFile = viper.GetString("dump_server_map")
+	remediationFile = viper.GetString("remediation_file")
+	axfrCrossCheck = viper.GetBool("axfr_cross_check")
+	crossCheckSample = viper.GetInt("axfr_cross_check_sample")
 
-	// Load NetBox API token from file if specified
+	// Warn-only runs report findings without remediation artifacts or failing
+	if warnOnly {
+		noNSUpdate = true
+		exitZero = true
+	}
+
+	if len(successfulTypes) > 0 {
+		recordSuccessful = true
+	}
+
+	// Load NetBox API token from file, or standard input, if specified
 	if apiTokenFile != "" && apiToken == "" {
-		tokenBytes, err := os.ReadFile(apiTokenFile)
+		token, err := readAPIToken(apiTokenFile)
 		if err != nil {
-			fmt.Printf("Failed to read API token file: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "Failed to read API token file: %v\n", err)
+			return 1
 		}
-		apiToken = strings.TrimSpace(string(tokenBytes))
+		apiToken = token
 	}
 
 	if apiURL == "" || apiToken == "" {
-		fmt.Println("Error: --api-url and --api-token are required.")
+		fmt.Fprintln(os.Stderr, "Error: --api-url and --api-token are required.")
 		pflag.Usage()
-		os.Exit(1)
+		return 1
+	}
+
+	netboxHeaders, err := parseNetBoxHeaders(netboxHeaderPairs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	tlsServerNames, err := parseTLSServerNames(tlsServerNamePairs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
 	}
 
 	// Ensure apiURL ends with a slash for proper URL parsing
@@ -180,8 +635,8 @@ func main() {
 
 	parsedBaseURL, err := url.Parse(apiURL)
 	if err != nil {
-		fmt.Printf("Invalid api-url: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Invalid api-url: %v\n", err)
+		return 1
 	}
 
 	// Set up logger with configurable format
@@ -196,6 +651,55 @@ func main() {
 	logger = log.With(logger, "ts", log.DefaultTimestampUTC, "caller", log.DefaultCaller)
 
 	level.Info(logger).Log("msg", "Starting DNS validation")
+	started := time.Now()
+
+	// Construct the plugin API endpoints
+	endpoints := make(map[string]string)
+	for _, endpoint := range []string{"nameservers", "zones", "records"} {
+		endpoints[endpoint], err = netboxDNSEndpoint(parsedBaseURL, netboxDNSPath, endpoint)
+		if err != nil {
+			level.Error(logger).Log("msg", "Invalid NetBox DNS API path", "path", netboxDNSPath, "err", err)
+			return 1
+		}
+		level.Debug(logger).Log("msg", "NetBox DNS API endpoint", "endpoint", endpoint, "url", endpoints[endpoint])
+	}
+
+	// A pre-flight check stops before any validation
+	if selfCheckOnly {
+		queryOpts := QueryOptions{Retries: 1, TLS: dnsOverTLS, TLSServerNames: tlsServerNames, RecursionDesired: recursionDesired}
+		switch strings.ToLower(dnsProtocol) {
+		case "tcp":
+			queryOpts.TCP = true
+		case "tls":
+			queryOpts.TLS = true
+		case "https":
+			queryOpts.DoHURL = dohURLTemplate
+		}
+		if !runSelfCheck(os.Stdout, parsedBaseURL, endpoints["nameservers"], apiToken, netboxHeaders, nameserverFilter, queryOpts, logger) {
+			level.Error(logger).Log("msg", "Pre-flight check failed")
+			return 1
+		}
+		level.Info(logger).Log("msg", "Pre-flight check passed")
+		return 0
+	}
+
+	// Records are decoded the same way from every plugin version, so the version
+	// is only logged to help diagnose schema problems
+	pluginVersion, err := getPluginVersion(resolveURL(parsedBaseURL, "/api/status")+"/", apiToken, netboxHeaders, logger)
+	if err != nil {
+		level.Warn(logger).Log("msg", "Could not detect the netbox-dns plugin version", "err", err)
+	} else if pluginVersion == "" {
+		level.Warn(logger).Log("msg", "NetBox doesn't list the netbox-dns plugin as installed")
+	} else {
+		level.Info(logger).Log("msg", "Using netbox-dns plugin API", "version", pluginVersion)
+	}
+
+	// Trace the run's phases only when a collector is configured
+	var tel *telemetry
+	if otlpEndpoint != "" {
+		tel = newTelemetry(otlpEndpoint)
+		level.Info(logger).Log("msg", "Exporting OTLP telemetry", "endpoint", otlpEndpoint)
+	}
 
 	var servers []string
 	var nameserversList []Nameserver
@@ -203,17 +707,19 @@ func main() {
 	{
 		// Fetch nameservers from NetBox API
 		level.Info(logger).Log("msg", "Fetching nameservers from NetBox Nameservers API")
-		nameserversEndpoint := resolveURL(parsedBaseURL, "/api/plugins/netbox-dns/nameservers/")
+		nameserversEndpoint := endpoints["nameservers"]
 
-		fetchedNameservers, err := getAllNameservers(nameserversEndpoint, apiToken, logger, nameserverFilter)
+		span := tel.startSpan("netbox.fetch_nameservers")
+		fetchedNameservers, err := getAllNameservers(nameserversEndpoint, apiToken, netboxHeaders, logger, nameserverFilter, tenantFilter)
+		span.end("nameservers", fmt.Sprint(len(fetchedNameservers)))
 		if err != nil {
 			level.Error(logger).Log("msg", "Failed to fetch nameservers from NetBox", "err", err)
-			os.Exit(1)
+			return 1
 		}
 
 		if len(fetchedNameservers) == 0 {
 			level.Error(logger).Log("msg", "No nameservers found from NetBox API")
-			os.Exit(1)
+			return 1
 		}
 
 		nameserversList = fetchedNameservers
@@ -231,8 +737,9 @@ func main() {
 		level.Info(logger).Log("msg", "Authoritative DNS servers extracted", "servers", strings.Join(servers, ", "))
 	}
 
-	// Determine zones to validate based on nameservers if nameserverFilter is used
+	// With --nameserver, validate only the zones and views it serves, and only on it
 	var zonesToValidate []string
+	var nameserverScope map[string]bool
 	if nameserverFilter != "" {
 		zonesSet := make(map[string]bool)
 		for _, ns := range nameserversList {
@@ -243,98 +750,598 @@ func main() {
 		for zone := range zonesSet {
 			zonesToValidate = append(zonesToValidate, zone)
 		}
-		level.Info(logger).Log("msg", "Zones to validate derived from nameservers", "zones", strings.Join(zonesToValidate, ", "))
-	}
-
-	// Construct the Records API endpoint
-	recordsEndpoint := resolveURL(parsedBaseURL, "/api/plugins/netbox-dns/records/")
-
-	// Fetch DNS Records
-	records, err := getAllDNSRecords(recordsEndpoint, apiToken, logger, zoneFilter, viewFilter, zonesToValidate)
-	if err != nil {
-		level.Error(logger).Log("msg", "Failed to get DNS records from NetBox", "err", err)
-		os.Exit(1)
+		nameserverScope = nameserverZoneScope(nameserversList)
+		level.Info(logger).Log("msg", "Zones to validate derived from nameservers", "zones", strings.Join(zonesToValidate, ", "), "zone_views", len(nameserverScope))
 	}
 
-	level.Info(logger).Log("msg", "Fetched DNS records from NetBox", "count", len(records))
-
 	// Fetch Zones
-	zonesEndpoint := resolveURL(parsedBaseURL, "/api/plugins/netbox-dns/zones/")
-	zonesMap, err := getAllZones(zonesEndpoint, apiToken, logger)
+	zonesEndpoint := endpoints["zones"]
+	span := tel.startSpan("netbox.fetch_zones")
+	zonesMap, err := getAllZones(zonesEndpoint, apiToken, netboxHeaders, logger, tenantFilter)
+	span.end("zones", fmt.Sprint(len(zonesMap)))
 	if err != nil {
 		level.Error(logger).Log("msg", "Failed to get DNS zones from NetBox", "err", err)
-		os.Exit(1)
+		return 1
 	}
 	level.Info(logger).Log("msg", "Fetched DNS zones from NetBox", "count", len(zonesMap))
 
-	// Build a map from zone name to Zone
-	zonesByName := make(map[string]Zone)
-	for _, zone := range zonesMap {
-		zonesByName[zone.Name] = zone
-	}
-
-	// Assign ZoneDefaultTTL and SoaTTL to each record
-	for i := range records {
-		record := &records[i]
-		if record.Zone != nil {
-			if zone, ok := zonesMap[record.Zone.ID]; ok {
-				record.ZoneDefaultTTL = zone.DefaultTTL
-				// Update the Zone struct in the record to include SoaTTL
-				record.Zone.SoaTTL = zone.SoaTTL
-			} else {
-				level.Warn(logger).Log("msg", "Zone not found in zones map", "zone_id", record.Zone.ID)
-			}
+	// The default view is validated through the same filter as --view
+	if defaultViewOnly {
+		defaultView, err := defaultViewName(zonesMap)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to determine the default view", "err", err)
+			return 1
+		}
+		if viewFilter != "" && viewFilter != defaultView {
+			level.Error(logger).Log("msg", "--view names a view other than the default view", "view", viewFilter, "default_view", defaultView)
+			return 1
 		}
+		viewFilter = defaultView
+		level.Info(logger).Log("msg", "Validating the default view only", "view", defaultView)
 	}
 
+	// Build a map from zone name and view to Zone
+	zonesByName := buildZonesByName(zonesMap)
+	zoneDiscrepancies := findDuplicateZones(zonesMap, logger)
+
 	// Determine SOA validation mode
 	soaValidationMode := parseSOAValidationMode(validateSOA)
+	if apexOnly && soaValidationMode == "false" {
+		// The SOA is part of the apex health check
+		soaValidationMode = "true"
+	}
+	if hiddenPrimary != "" && soaValidationMode == "false" {
+		// Serials are compared with the hidden primary during SOA validation
+		soaValidationMode = "true"
+	}
 
 	// Parse TSIG keyfile if provided
 	if tsigKeyFile != "" && useAXFR {
 		// Ensure the TSIG keyfile exists and is readable
 		if _, err := os.Stat(tsigKeyFile); os.IsNotExist(err) {
 			level.Error(logger).Log("msg", "TSIG keyfile does not exist", "file", tsigKeyFile)
-			os.Exit(1)
+			return 1
+		}
+	}
+
+	// Parse the report template up front so a broken template fails before validation
+	var reportTmpl *template.Template
+	if reportTemplate != "" {
+		reportTmpl, err = loadReportTemplate(reportTemplate)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to load report template", "file", reportTemplate, "err", err)
+			return 1
+		}
+	}
+
+	// Load the baseline up front so a bad file fails before validation
+	var baseline []JSONFinding
+	if baselineFile != "" {
+		baseline, err = loadBaseline(baselineFile)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to load baseline", "file", baselineFile, "err", err)
+			return 1
+		}
+	}
+
+	var ignoreRules IgnoreRules
+	if ignoreFile != "" {
+		ignoreRules, err = loadIgnoreFile(ignoreFile)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to load ignore file", "file", ignoreFile, "err", err)
+			return 1
+		}
+		level.Info(logger).Log("msg", "Loaded ignore rules", "file", ignoreFile, "rules", len(ignoreRules))
+	}
+
+	// Settings shared by all validators
+	validationOpts := ValidationOptions{
+		IgnoreSerialNumbers:    ignoreSerialNumbers,
+		RecordSuccessful:       recordSuccessful,
+		NSApexTTLFromSOA:       nsApexTTLFromSOA,
+		TTLTolerance:           ttlTolerance,
+		RecordNearMisses:       reportNearMisses,
+		SerialLagTolerance:     serialLagTolerance,
+		CaseSensitive:          caseSensitive,
+		CheckSOAMName:          checkSOAMName,
+		ConfirmOverTCP:         confirmOverTCP,
+		ExactKeySets:           exactKeySets,
+		HiddenPrimary:          hiddenPrimary,
+		HiddenPrimaryTolerance: hiddenPrimaryLag,
+		CheckRRsetTTLs:         checkRRsetTTLs,
+		DMARCAware:             dmarcAware,
+		WarnOnly:               warnOnly,
+		// Limit concurrent queries per DNS server across all validators
+		Throttle:       newServerThrottle(maxQueriesPerServer),
+		MaxConcurrency: maxConcurrency,
+		Query: QueryOptions{
+			Retries:          3,
+			ServfailRetries:  servfailRetries,
+			ServfailBackoff:  defaultServfailBackoff,
+			Telemetry:        tel,
+			RecursionDesired: recursionDesired,
+		},
+	}
+
+	if recursionDesired {
+		level.Info(logger).Log("msg", "Setting the RD bit on DNS queries")
+	}
+
+	if maxTotalRetries < 0 {
+		level.Error(logger).Log("msg", "Invalid --max-total-retries", "value", maxTotalRetries)
+		return 1
+	}
+	if maxTotalRetries > 0 {
+		validationOpts.Query.RetryBudget = newRetryBudget(maxTotalRetries)
+	}
+
+	validationOpts.Query.TLS = dnsOverTLS
+	validationOpts.Query.TLSServerNames = tlsServerNames
+	switch strings.ToLower(dnsProtocol) {
+	case "udp":
+	case "tcp":
+		validationOpts.Query.TCP = true
+	case "tls":
+		validationOpts.Query.TLS = true
+	case "https":
+		if reuseConnections || dnsOverTLS {
+			level.Warn(logger).Log("msg", "--reuse-connections and --dns-over-tls are ignored with --dns-protocol https")
+		}
+		validationOpts.Query.DoHURL = dohURLTemplate
+		level.Info(logger).Log("msg", "Querying DNS servers over DNS-over-HTTPS", "url", dohURLTemplate)
+	default:
+		level.Error(logger).Log("msg", "Invalid DNS protocol (expected udp, tcp, tls or https)", "protocol", dnsProtocol)
+		return 1
+	}
+	if reuseConnections {
+		validationOpts.Query.Connections = newConnPool(connectionPoolSize)
+		defer validationOpts.Query.Connections.shutdown()
+	}
+
+	validationOpts.FQDNFilter, err = newFQDNFilter(fqdnRegex, subtrees)
+	if err != nil {
+		level.Error(logger).Log("msg", "Invalid --fqdn-regex or --subtree", "err", err)
+		return 1
+	}
+
+	// Sampling whole zones' transfers or cache dumps would report the rest as unknown
+	var sampler *recordSampler
+	if samplePercent != 0 && samplePercent != 100 {
+		if useAXFR || cacheDump != "" {
+			level.Warn(logger).Log("msg", "--sample-percent does not apply with --use-axfr or --cache-dump, validating all records")
+		} else {
+			sampler, err = newRecordSampler(samplePercent, sampleSeed)
+			if err != nil {
+				level.Error(logger).Log("msg", "Invalid --sample-percent", "err", err)
+				return 1
+			}
+			level.Info(logger).Log("msg", "Sampling records", "percent", samplePercent, "seed", sampler.seed)
+		}
+	}
+
+	var syslogLogger log.Logger
+	if syslogEnabled {
+		var closeSyslog func() error
+		syslogLogger, closeSyslog, err = newSyslogLogger(syslogNetwork, syslogAddress)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to connect to syslog", "network", syslogNetwork, "address", syslogAddress, "err", err)
+			return 1
+		}
+		defer closeSyslog()
+	}
+
+	if autoTransport {
+		if validationOpts.Query.usesTCP() {
+			level.Warn(logger).Log("msg", "--auto-transport has no effect when every query already uses a stream transport")
+		}
+		validationOpts.Transport, err = newTransportSelector(viper.GetStringMapString("transport_by_type"))
+		if err != nil {
+			level.Error(logger).Log("msg", "Invalid transport configuration", "err", err)
+			return 1
+		}
+	}
+
+	if err := viper.UnmarshalKey("denylist", &validationOpts.Denylist); err != nil {
+		level.Error(logger).Log("msg", "Invalid denylist configuration", "err", err)
+		return 1
+	}
+	if len(validationOpts.Denylist) > 0 {
+		level.Info(logger).Log("msg", "Loaded value denylist", "entries", len(validationOpts.Denylist))
+	}
+
+	if err := viper.UnmarshalKey("answer_policies", &validationOpts.AnswerPolicies); err != nil {
+		level.Error(logger).Log("msg", "Invalid answer policies configuration", "err", err)
+		return 1
+	}
+	if err := validationOpts.AnswerPolicies.validate(); err != nil {
+		level.Error(logger).Log("msg", "Invalid answer policies configuration", "err", err)
+		return 1
+	}
+	if len(validationOpts.AnswerPolicies) > 0 {
+		level.Info(logger).Log("msg", "Loaded answer policies", "policies", len(validationOpts.AnswerPolicies))
+	}
+
+	var severityRules SeverityRules
+	if err := viper.UnmarshalKey("severity_rules", &severityRules); err != nil {
+		level.Error(logger).Log("msg", "Invalid severity rules configuration", "err", err)
+		return 1
+	}
+	if err := severityRules.validate(); err != nil {
+		level.Error(logger).Log("msg", "Invalid severity rules configuration", "err", err)
+		return 1
+	}
+	minSeverity, err = parseSeverity(minSeverity)
+	if err != nil {
+		level.Error(logger).Log("msg", "Invalid minimum severity", "err", err)
+		return 1
+	}
+
+	if clientSubnet != "" {
+		subnet, err := parseClientSubnet(clientSubnet)
+		if err != nil {
+			level.Error(logger).Log("msg", "Invalid EDNS client subnet", "err", err)
+			return 1
+		}
+		validationOpts.Query.ClientSubnet = subnet
+		level.Info(logger).Log("msg", "Using EDNS client subnet", "subnet", validationOpts.Query.clientSubnetString())
+	}
+
+	if failFastEnabled {
+		// Cancel outstanding queries once the first reportable discrepancy is found
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		validationOpts.Query.Context = ctx
+		validationOpts.FailFast = newFailFast(cancel, ignoreRules, severityRules, minSeverity)
+		level.Info(logger).Log("msg", "Stopping at the first discrepancy")
+	}
+
+	if tsigQueries {
+		if tsigKeyFile == "" {
+			level.Error(logger).Log("msg", "--tsig-queries requires --tsig-keyfile")
+			return 1
+		}
+		tsigKey, err := parseTSIGKeyFile(tsigKeyFile)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to parse TSIG keyfile", "err", err)
+			return 1
+		}
+		validationOpts.Query.TSIGKey = tsigKey
+		level.Info(logger).Log("msg", "Signing DNS queries with TSIG", "key", tsigKey.Name)
+	}
+
+	if checkRecordCounts {
+		if !useAXFR {
+			level.Warn(logger).Log("msg", "--check-record-counts only applies with --use-axfr")
+		}
+		validationOpts.RecordCounts = newRecordCounts(recordCountTolerance)
+	}
+	if useAXFR {
+		validationOpts.AXFRSummaries = newAXFRSummaries()
+	}
+	if serialReportFile != "" {
+		validationOpts.SerialTable = newSerialTable()
+	}
+	if axfrCrossCheck {
+		if !useAXFR {
+			level.Warn(logger).Log("msg", "--axfr-cross-check only applies with --use-axfr")
+		}
+		if crossCheckSample < 1 || crossCheckSample > 100 {
+			level.Error(logger).Log("msg", "--axfr-cross-check-sample must be between 1 and 100", "sample", crossCheckSample)
+			return 1
+		}
+		validationOpts.CrossCheckSample = crossCheckSample
+	}
+	if checkNSEC {
+		if !useAXFR {
+			level.Warn(logger).Log("msg", "--check-nsec only applies with --use-axfr")
+		}
+		validationOpts.CheckNSEC = true
+	}
+
+	if dumpComparisons != "" {
+		validationOpts.Comparisons, err = newComparisonDump(dumpComparisons)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to open comparison dump", "err", err)
+			return 1
+		}
+	}
+
+	if len(resolvers) > 0 {
+		level.Info(logger).Log("msg", "Checking resolver consensus", "resolvers", strings.Join(resolvers, ", "), "quorum", quorum)
+	}
+
+	// Map each (zone, view) to the nameservers serving it
+	zoneViewToNameservers := buildZoneViewNameservers(nameserversList, logger)
+
+	// Fall back to configured server groups for views NetBox doesn't map
+	var viewServers ViewServers
+	if err := viper.UnmarshalKey("view_servers", &viewServers); err != nil {
+		level.Error(logger).Log("msg", "Invalid view servers configuration", "err", err)
+		return 1
+	}
+	if err := viewServers.validate(); err != nil {
+		level.Error(logger).Log("msg", "Invalid view servers configuration", "err", err)
+		return 1
+	}
+	if nameserverFilter != "" {
+		// Configured servers would validate zones on servers other than the one selected
+		level.Debug(logger).Log("msg", "Not applying view servers with --nameserver")
+	} else if filled := applyViewServers(zoneViewToNameservers, zonesMap, viewServers, logger); filled > 0 {
+		level.Info(logger).Log("msg", "Mapped zones to configured view servers", "zones", filled)
+	}
+
+	// Check the NetBox data itself before any DNS query; records are checked as they are fetched
+	var netboxChecks *netboxCheck
+	if checkNetBox {
+		netboxChecks = newNetBoxCheck(zonesMap, nameserversList, zoneViewToNameservers, logger)
+	}
+
+	// Leave servers under maintenance out of every validation, after the NetBox checks saw them
+	exclusion := newServerExclusion(excludeNameservers)
+	if exclusion != nil {
+		zoneViewToNameservers = exclusion.filterZoneViews(zoneViewToNameservers)
+		nameserversList = exclusion.filterNameservers(nameserversList)
+		servers = exclusion.filterServers(servers)
+		if len(servers) == 0 {
+			level.Error(logger).Log("msg", "All nameservers are excluded", "patterns", strings.Join(excludeNameservers, ", "))
+			return 1
 		}
+		level.Info(logger).Log("msg", "Excluding nameservers from validation", "servers", strings.Join(exclusion.servers(), ", "))
+	}
+
+	// Only query each zone's SOA MName when validating master data
+	var primaryDiscrepancies []Discrepancy
+	if primaryOnly {
+		zoneViewToNameservers, primaryDiscrepancies = restrictToPrimary(zoneViewToNameservers, zonesMap, logger)
+		level.Info(logger).Log("msg", "Validating zones against their primary nameserver only", "unknown_primaries", len(primaryDiscrepancies))
+		validationOpts.FailFast.record(primaryDiscrepancies)
 	}
 
+	// Trust the servers' own view of their authority over NetBox's assignments
+	var authorityDiscrepancies []Discrepancy
+	if authoritativeOnly {
+		zoneViewToNameservers, authorityDiscrepancies = restrictToAuthoritative(zoneViewToNameservers, zonesMap, zoneFilter, viewFilter, logger, validationOpts)
+		level.Info(logger).Log("msg", "Validating zones against the servers that confirm authority", "denied", len(authorityDiscrepancies))
+		validationOpts.FailFast.record(authorityDiscrepancies)
+	}
+
+	// Show which servers each zone will be checked against before any query
+	if serverMapFile != "" {
+		serverMap := buildServerMap(zonesByName, zoneViewToNameservers, zoneFilter, viewFilter, useAXFR)
+		if err := writeServerMap(serverMap, serverMapFile, reportFormat, logger); err != nil {
+			level.Error(logger).Log("msg", "Failed to write server map", "err", err)
+			return 1
+		}
+	}
+
+	plan := validationPlan{
+		servers:               servers,
+		logger:                logger,
+		zoneViewToNameservers: zoneViewToNameservers,
+		zonesByName:           zonesByName,
+		zoneFilter:            zoneFilter,
+		viewFilter:            viewFilter,
+		soaValidationMode:     soaValidationMode,
+		validateDS:            validateDS,
+		checkDisabledPTR:      checkDisabledPTR,
+		checkLameDelegations:  checkLame,
+		checkDelegationTTL:    checkDelegationTTL,
+		delegationTTL:         delegationTTL,
+		checkCNAMETargets:     checkCNAMETargets,
+		checkMXTargets:        checkMXTargets,
+		checkReverseZones:     checkReverseZones,
+		checkDisabledRecords:  !includeInactive,
+		resolvers:             resolvers,
+		quorum:                quorum,
+		compareTTLOnly:        compareTTLOnly,
+		minTTL:                minTTL,
+		maxTTL:                maxTTL,
+		opts:                  validationOpts,
+	}
+
+	recordsEndpoint := endpoints["records"]
+
 	// Validate Records
 	var discrepancies []Discrepancy
 	var successfulValidations []ValidationRecord
 	var missingRecords []MissingRecord
+	var recordCount int
+	var netboxDiscrepancies []Discrepancy
 
-	if useAXFR {
-		// Perform validation using AXFR
-		discrepancies, successfulValidations, missingRecords = validateAllRecordsAXFR(records, servers, ignoreSerialNumbers, logger, nameserversList, zoneFilter, viewFilter, recordSuccessful, zonesByName, tsigKeyFile)
+	// Compare against what NetBox shows rather than what it stores
+	var displayChecks *displayCheck
+	if compareDisplay {
+		displayChecks = newDisplayCheck(logger)
+	}
+
+	if useAXFR && cacheDump != "" {
+		level.Error(logger).Log("msg", "--use-axfr and --cache-dump cannot be combined")
+		return 1
+	}
+
+	if skipManaged && onlyManaged {
+		level.Error(logger).Log("msg", "--skip-managed and --only-managed cannot be combined")
+		return 1
+	}
+
+	if streamRecords && (useAXFR || cacheDump != "") {
+		level.Warn(logger).Log("msg", "Streaming is not supported with AXFR or cache dumps, fetching all records first")
+	}
+
+	if streamRecords && !useAXFR && cacheDump == "" {
+		// Validate each zone as soon as its records have been fetched
+		batches := make(chan []Record)
+		fetchErr := make(chan error, 1)
+		go func() {
+			fetchErr <- streamRecordBatches(recordsEndpoint, apiToken, netboxHeaders, logger, zoneFilter, viewFilter, tenantFilter, zonesToValidate, batches)
+		}()
+
+		discrepancies, successfulValidations, recordCount = validateStream(batches, plan, maxConcurrency, func(batch []Record) ([]Record, []Record) {
+			netboxChecks.checkRecords(batch)
+			batch = restrictToScope(batch, nameserverScope)
+			batch, disabled := prepareRecords(batch, zonesMap, includeInactive, apexOnly, skipManaged, onlyManaged, logger)
+			batch = sampler.apply(validationOpts.FQDNFilter.apply(batch))
+			batch = displayChecks.apply(batch)
+			exclusion.countSkipped(batch)
+			return batch, sampler.filter(disabled)
+		})
+		if err := <-fetchErr; err != nil {
+			level.Error(logger).Log("msg", "Failed to get DNS records from NetBox", "err", err)
+			return 1
+		}
+
+		level.Info(logger).Log("msg", "Streamed and validated DNS records from NetBox", "count", recordCount)
+		netboxDiscrepancies = netboxChecks.report()
 	} else {
-		// Validate Records using individual queries
-		if soaValidationMode != "only" {
-			// Validate all records except SOA
-			discrepancies, successfulValidations = validateAllRecords(records, servers, ignoreSerialNumbers, logger, nameserversList, zoneFilter, viewFilter, recordSuccessful, zonesByName)
+		// Fetch DNS Records
+		span := tel.startSpan("netbox.fetch_records")
+		records, err := getAllDNSRecords(recordsEndpoint, apiToken, netboxHeaders, logger, zoneFilter, viewFilter, tenantFilter, zonesToValidate)
+		span.end("records", fmt.Sprint(len(records)))
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to get DNS records from NetBox", "err", err)
+			return 1
+		}
+
+		level.Info(logger).Log("msg", "Fetched DNS records from NetBox", "count", len(records))
+
+		// Surface data issues before the DNS checks start
+		netboxChecks.checkRecords(records)
+		netboxDiscrepancies = netboxChecks.report()
+
+		records = restrictToScope(records, nameserverScope)
+		records, disabledRecords := prepareRecords(records, zonesMap, includeInactive, apexOnly, skipManaged, onlyManaged, logger)
+		disabledRecords = sampler.filter(disabledRecords)
+		records = validationOpts.FQDNFilter.apply(records)
+		records = sampler.apply(records)
+		// Only the records validated are checked against their display
+		records = displayChecks.apply(records)
+		exclusion.countSkipped(records)
+		recordCount = len(records)
+
+		if useAXFR {
+			// Perform validation using AXFR
+			discrepancies, successfulValidations, missingRecords = validateAllRecordsAXFR(records, servers, logger, zoneViewToNameservers, zoneFilter, viewFilter, zonesByName, tsigKeyFile, validationOpts)
+		} else if cacheDump != "" {
+			// Compare against what a resolver currently has cached
+			discrepancies, successfulValidations, missingRecords, err = validateAllRecordsCache(records, cacheDump, logger, zoneFilter, zonesByName, validationOpts)
+			if err != nil {
+				level.Error(logger).Log("msg", "Failed to validate against cache dump", "err", err)
+				return 1
+			}
+		} else {
+			// Validate Records using individual queries
+			span := tel.startSpan("validate.records")
+			discrepancies, successfulValidations = plan.validate(records)
+			span.end("records", fmt.Sprint(len(records)), "discrepancies", fmt.Sprint(len(discrepancies)))
 		}
 
-		if soaValidationMode != "false" {
-			// Validate SOA records separately
-			soaDiscrepancies, soaSuccessfulValidations := validateSOARecords(records, servers, ignoreSerialNumbers, logger, nameserversList, recordSuccessful)
-			discrepancies = append(discrepancies, soaDiscrepancies...)
-			successfulValidations = append(successfulValidations, soaSuccessfulValidations...)
+		// Disabled records are confirmed absent with direct queries, zone transfers or not
+		if cacheDump == "" {
+			disabledDiscrepancies, disabledValidations := plan.validateDisabled(disabledRecords)
+			discrepancies = append(discrepancies, disabledDiscrepancies...)
+			successfulValidations = append(successfulValidations, disabledValidations...)
+		}
+
+		discrepancies = plan.finish(records, discrepancies)
+	}
+
+	validationOpts.FQDNFilter.report(logger)
+	sampler.report(logger)
+	exclusion.report(logger)
+	discrepancies = append(discrepancies, primaryDiscrepancies...)
+	discrepancies = append(discrepancies, authorityDiscrepancies...)
+	discrepancies = append(discrepancies, zoneDiscrepancies...)
+	discrepancies = append(discrepancies, netboxDiscrepancies...)
+	discrepancies = append(discrepancies, displayChecks.report()...)
+
+	// Validators finish in any order; keep reports stable between runs
+	sortDiscrepancies(discrepancies)
+	sortValidations(successfulValidations)
+	// --record-successful-types narrows the listing only; summaries count every success
+	listedValidations := successfulValidations
+	if len(successfulTypes) > 0 {
+		var dropped int
+		listedValidations, dropped = filterValidationsByType(successfulValidations, successfulTypes)
+		level.Info(logger).Log("msg", "Recording successful validations of selected types only", "types", strings.Join(successfulTypes, ","), "kept", len(listedValidations), "dropped", dropped)
+	}
+	sortMissingRecords(missingRecords)
+
+	// With --fail-fast, report only the discrepancy that stopped the run
+	failedFast := false
+	if failFastEnabled {
+		validationOpts.FailFast.record(discrepancies)
+		if first, ok := validationOpts.FailFast.result(); ok {
+			level.Warn(logger).Log("msg", "Stopped at first discrepancy", "fqdn", first.FQDN, "type", first.RecordType, "server", first.Server)
+			discrepancies = []Discrepancy{first}
+			failedFast = true
+		}
+	}
+
+	// Drop accepted discrepancies
+	if len(ignoreRules) > 0 {
+		total := len(discrepancies)
+		discrepancies = filterIgnored(discrepancies, ignoreRules, time.Now(), logger)
+		level.Info(logger).Log("msg", "Applied ignore rules", "ignored", total-len(discrepancies), "total", total)
+	}
+
+	// Rate each finding and drop those below the requested severity
+	assignSeverities(discrepancies, severityRules)
+	if minSeverity != SeverityInfo {
+		total := len(discrepancies)
+		discrepancies = filterBySeverity(discrepancies, minSeverity)
+		level.Info(logger).Log("msg", "Filtered discrepancies by severity", "min_severity", minSeverity, "kept", len(discrepancies), "total", total)
+	}
+
+	// Against a baseline, report only new discrepancies and list the resolved ones
+	reportedDiscrepancies := discrepancies
+	if baselineFile != "" {
+		var resolved []Discrepancy
+		reportedDiscrepancies, resolved = diffBaseline(discrepancies, baseline)
+		level.Info(logger).Log("msg", "Compared discrepancies with baseline", "baseline", baselineFile, "new", len(reportedDiscrepancies), "resolved", len(resolved), "total", len(discrepancies))
+
+		err = generateReport(resolved, resolvedReportFile, reportFormat, logger)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to generate resolved discrepancies report", "err", err)
+			return 1
 		}
 	}
 
 	// Generate Discrepancy Report
-	err = generateReport(discrepancies, reportFile, reportFormat, logger)
+	if reportTmpl != nil {
+		summary := RunSummary{
+			GeneratedAt:  time.Now().UTC(),
+			Records:      recordCount,
+			Servers:      servers,
+			Successful:   len(successfulValidations),
+			RecordCounts: validationOpts.RecordCounts.all(),
+			AXFRZones:    validationOpts.AXFRSummaries.all(),
+		}
+		err = generateTemplateReport(reportedDiscrepancies, summary, reportTmpl, reportFile, logger)
+	} else if useAXFR {
+		err = generateAXFRReport(reportedDiscrepancies, validationOpts.AXFRSummaries.all(), reportFile, reportFormat, logger)
+	} else {
+		err = generateReport(reportedDiscrepancies, reportFile, reportFormat, logger)
+	}
 	if err != nil {
 		level.Error(logger).Log("msg", "Failed to generate discrepancy report", "err", err)
-		os.Exit(1)
+		return 1
 	}
 
-	// Generate Successful Validations Report if enabled
-	if recordSuccessful {
-		err = generateSuccessfulReport(successfulValidations, successfulReportFile, reportFormat, logger)
+	// Generate Successful Validations Report if enabled; near-misses go to the same report
+	if recordSuccessful || reportNearMisses {
+		err = generateSuccessfulReport(listedValidations, successfulReportFile, reportFormat, logger)
 		if err != nil {
 			level.Error(logger).Log("msg", "Failed to generate successful validations report", "err", err)
-			os.Exit(1)
+			return 1
+		}
+	}
+
+	// Generate SOA Serial Report if enabled
+	if serialReportFile != "" {
+		err = generateSerialReport(validationOpts.SerialTable.all(), serialReportFile, reportFormat, logger)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to generate SOA serial report", "err", err)
+			return 1
 		}
 	}
 
@@ -343,18 +1350,93 @@ func main() {
 		err = generateMissingRecordsReport(missingRecords, missingReportFile, reportFormat, logger)
 		if err != nil {
 			level.Error(logger).Log("msg", "Failed to generate missing records report", "err", err)
-			os.Exit(1)
+			return 1
+		}
+	}
+
+	// Summarize the changes before anyone runs the scripts
+	remediation := newRemediationPlan(discrepancies, missingRecords)
+	if len(remediation.Zones) > 0 {
+		level.Info(logger).Log("msg", "Remediation summary", "summary", remediation.String(), "create", remediation.Create, "update", remediation.Update, "fix_ttl", remediation.FixTTL, "delete", remediation.Delete, "investigate", remediation.Investigate, "zones", len(remediation.Zones))
+	}
+	if remediationFile != "" {
+		if err := writeRemediationFile(remediation, remediationFile, reportFormat, logger); err != nil {
+			level.Error(logger).Log("msg", "Failed to write remediation summary", "err", err)
+			return 1
 		}
 	}
 
 	// Generate NSUpdate Scripts per server and zone
-	err = generateNSUpdateScripts(discrepancies, nsupdatePath, zonesByName, logger)
-	if err != nil {
-		level.Error(logger).Log("msg", "Failed to generate nsupdate scripts", "err", err)
-		os.Exit(1)
+	if noNSUpdate {
+		level.Debug(logger).Log("msg", "Not generating nsupdate scripts")
+	} else {
+		err = generateNSUpdateScripts(discrepancies, nsupdatePath, zonesByName, compareTTLOnly, logger)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to generate nsupdate scripts", "err", err)
+			return 1
+		}
+	}
+
+	if syslogLogger != nil {
+		if err := logFindings(syslogLogger, reportedDiscrepancies); err != nil {
+			level.Error(logger).Log("msg", "Failed to send discrepancies to syslog", "err", err)
+			return 1
+		}
+		level.Info(logger).Log("msg", "Sent discrepancies to syslog", "discrepancies", len(reportedDiscrepancies))
+	}
+
+	if usage := validationOpts.Query.RetryBudget.usage(); usage != nil {
+		if usage.Refused > 0 {
+			level.Warn(logger).Log("msg", "Retry budget exhausted, later failures were not retried", "limit", usage.Limit, "used", usage.Used, "refused", usage.Refused)
+		} else {
+			level.Info(logger).Log("msg", "Retry budget consumed", "limit", usage.Limit, "used", usage.Used)
+		}
+	}
+
+	if summaryFile != "" {
+		summary := newResultSummary(reportedDiscrepancies, len(successfulValidations), recordCount, servers, started)
+		summary.RetryBudget = validationOpts.Query.RetryBudget.usage()
+		summary.Sample = sampler.info()
+		summary.RecordCounts = validationOpts.RecordCounts.all()
+		if err := writeSummaryFile(summary, summaryFile); err != nil {
+			level.Error(logger).Log("msg", "Failed to write summary file", "err", err)
+			return 1
+		}
+		level.Info(logger).Log("msg", "Wrote results summary", "file", summaryFile, "passed", summary.Passed)
+	}
+
+	if dumpComparisons != "" {
+		count, err := validationOpts.Comparisons.close()
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to write comparison dump", "file", dumpComparisons, "err", err)
+			return 1
+		}
+		level.Info(logger).Log("msg", "Wrote comparison dump", "file", dumpComparisons, "comparisons", count)
 	}
 
 	level.Info(logger).Log("msg", "DNS validation completed")
+
+	tel.count("dnsverify.records", recordCount)
+	tel.count("dnsverify.discrepancies", len(reportedDiscrepancies))
+	tel.export(logger)
+
+	// New discrepancies fail the run when gating on a baseline or failing fast
+	if (baselineFile != "" || failedFast) && len(reportedDiscrepancies) > 0 && !exitZero {
+		return 2
+	}
+
+	return 0
+}
+
+// loadedConfigFile finishes loading a config file that was just read or merged.
+func loadedConfigFile(file string) {
+	level.Info(log.NewNopLogger()).Log("msg", "Using config file", "file", file)
+
+	// Expand ${VAR} references so secrets can be templated from the environment
+	if err := expandConfigEnv(file); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to expand environment references in config file %s: %v\n", file, err)
+		os.Exit(1)
+	}
 }
 
 func parseLogLevel(levelStr string) level.Option {
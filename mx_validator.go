@@ -0,0 +1,154 @@
+// mx_validator.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// mxLookupTimeout bounds the address lookup of an MX exchange outside NetBox.
+const mxLookupTimeout = 5 * time.Second
+
+// validateMXTargets reports MX records whose exchange host has no A or AAAA
+// record, which silently breaks mail delivery. Like CNAME targets, exchanges
+// inside a NetBox zone are queried on that zone's nameservers and others are
+// looked up through the system resolver. Null MX records (RFC 7505) are skipped.
+func validateMXTargets(records []Record, servers []string, logger log.Logger, zoneViewToNameservers map[string][]string, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	// Each exchange is resolved once, however many records point at it
+	owners := make(map[cnameTarget][]Record)
+	for _, record := range records {
+		if strings.ToUpper(record.Type) != "MX" {
+			continue
+		}
+		fields := strings.Fields(normalizeExpectedValue("MX", record.Value, record.ZoneName))
+		if len(fields) != 2 || fields[1] == "." {
+			continue
+		}
+		target := cnameTarget{
+			Target:   strings.ToLower(fields[1]),
+			ViewName: record.ViewName,
+		}
+		owners[target] = append(owners[target], record)
+	}
+
+	var wg sync.WaitGroup
+	var findings findingSet
+
+	for target, targetOwners := range owners {
+		wg.Add(1)
+		go func(target cnameTarget, targetOwners []Record) {
+			defer wg.Done()
+
+			var discrepancies []Discrepancy
+			var successful []ValidationRecord
+			reason, server, err := addresslessReason(target, zoneViewToNameservers, logger, opts)
+			for _, record := range targetOwners {
+				if err != nil {
+					level.Warn(logger).Log("msg", "Could not resolve MX exchange", "fqdn", record.FQDN, "exchange", target.Target, "err", err)
+					discrepancies = append(discrepancies, Discrepancy{
+						FQDN:       record.FQDN,
+						RecordType: "MX",
+						ZoneName:   record.ZoneName,
+						Expected:   []string{target.Target},
+						Server:     server,
+						Message:    fmt.Sprintf("Could not resolve MX exchange %s: %v", target.Target, err),
+						Category:   CategoryQueryError,
+					})
+					continue
+				}
+				if reason == "" {
+					level.Debug(logger).Log("msg", "MX exchange has an address", "fqdn", record.FQDN, "exchange", target.Target)
+					if opts.RecordSuccessful {
+						successful = append(successful, ValidationRecord{
+							FQDN:       record.FQDN,
+							RecordType: "MX",
+							ZoneName:   record.ZoneName,
+							Expected:   []string{target.Target},
+							Server:     server,
+							Message:    "MX exchange has an address",
+						})
+					}
+					continue
+				}
+
+				level.Warn(logger).Log("msg", "Dangling MX", "fqdn", record.FQDN, "exchange", target.Target, "reason", reason)
+				discrepancies = append(discrepancies, Discrepancy{
+					FQDN:       record.FQDN,
+					RecordType: "MX",
+					ZoneName:   record.ZoneName,
+					Expected:   []string{target.Target},
+					Server:     server,
+					Message:    fmt.Sprintf("Dangling MX: exchange %s %s", target.Target, reason),
+					Category:   CategoryDanglingMX,
+				})
+			}
+			findings.add(discrepancies, successful, nil)
+		}(target, targetOwners)
+	}
+
+	wg.Wait()
+
+	tagClientSubnet(findings.discrepancies, opts.Query)
+	opts.FailFast.record(findings.discrepancies)
+	return findings.discrepancies, findings.successful
+}
+
+// addresslessReason explains why target has no address, or returns "" if it
+// has an A or AAAA record. It also returns the server that was asked, if any.
+// The exchange only counts as addressless once both the A and the AAAA query
+// were answered with NOERROR or NXDOMAIN; if no server answers both, the last
+// failure is returned instead.
+func addresslessReason(target cnameTarget, zoneViewToNameservers map[string][]string, logger log.Logger, opts ValidationOptions) (string, string, error) {
+	zoneServers := zoneServersFor(target.Target, target.ViewName, zoneViewToNameservers)
+	if len(zoneServers) > 0 {
+		// Ask the target zone's first server that answers both queries
+		var lastErr error
+		var lastServer string
+		for _, server := range zoneServers {
+			answered := true
+			for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+				opts.Throttle.acquire(server)
+				resp, err := queryDNSWithRetry(target.Target, qtype, server, opts.Query)
+				opts.Throttle.release(server)
+				if resp == nil || (resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError) {
+					lastErr, lastServer = lookupFailure(resp, err), server
+					answered = false
+					break
+				}
+				if resp.Rcode == dns.RcodeNameError {
+					return "does not exist (NXDOMAIN)", server, nil
+				}
+				for _, rr := range resp.Answer {
+					if rr.Header().Rrtype == qtype {
+						return "", server, nil
+					}
+				}
+			}
+			if answered {
+				return "has no A or AAAA record", server, nil
+			}
+		}
+		return "", lastServer, lastErr
+	}
+
+	// Exchanges outside NetBox are resolved like any mail server would
+	ctx, cancel := context.WithTimeout(opts.Query.context(), mxLookupTimeout)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, strings.TrimSuffix(target.Target, ".")); err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return "has no address", "", nil
+		}
+		return "", "", err
+	}
+	return "", "", nil
+}
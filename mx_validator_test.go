@@ -0,0 +1,59 @@
+// mx_validator_test.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/miekg/dns"
+)
+
+func TestValidateMXTargets(t *testing.T) {
+	tests := []struct {
+		name   string
+		rcodes map[uint16]int
+		want   string
+	}{
+		// NODATA for both types: the exchange really has no address
+		{"no address", nil, CategoryDanglingMX},
+		// NODATA for A but no answer for AAAA proves nothing
+		{"AAAA SERVFAIL", map[uint16]int{dns.TypeAAAA: dns.RcodeServerFailure}, CategoryQueryError},
+		{"A REFUSED", map[uint16]int{dns.TypeA: dns.RcodeRefused}, CategoryQueryError},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := fmt.Sprintf("127.0.54.%d", i+1)
+			zone := newTestZone(t, "example.com", "mail 3600 IN TXT exists")
+			zone.rcodes = tt.rcodes
+			serveZone(t, addr, zone)
+
+			opts := ValidationOptions{Query: QueryOptions{Retries: 1}}
+			zoneViewToNameservers := map[string][]string{zoneViewKey("example.com", "default"): {addr}}
+			var got []Discrepancy
+			finishWithin(t, 10*time.Second, func() {
+				got, _ = validateMXTargets([]Record{testRecord("@", "MX", "10 mail", 3600)}, []string{addr}, log.NewNopLogger(), zoneViewToNameservers, opts)
+			})
+			if len(got) != 1 || got[0].Category != tt.want {
+				t.Errorf("got findings %v, want one %s", categories(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestAddresslessReasonCancelled(t *testing.T) {
+	// A run stopped by --fail-fast doesn't wait on the system resolver
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts := ValidationOptions{Query: QueryOptions{Context: ctx}}
+	target := cnameTarget{Target: "host.example.net.", ViewName: "default"}
+
+	finishWithin(t, time.Second, func() {
+		if reason, _, err := addresslessReason(target, nil, log.NewNopLogger(), opts); err == nil {
+			t.Errorf("got reason %q and no error for a cancelled lookup of an MX exchange", reason)
+		}
+	})
+}
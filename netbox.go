@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"path"
+	"sort"
 	"strings"
 
 	"github.com/go-kit/log"
@@ -14,15 +16,28 @@ import (
 )
 
 // Fetch DNS Records from NetBox with filters
-func getAllDNSRecords(baseURL, token string, logger log.Logger, zoneFilter, viewFilter string, zonesToValidate []string) ([]Record, error) {
+func getAllDNSRecords(baseURL, token string, headers http.Header, logger log.Logger, zoneFilter, viewFilter, tenantFilter string, zonesToValidate []string) ([]Record, error) {
 	var allRecords []Record
+	err := fetchDNSRecordPages(baseURL, token, headers, logger, zoneFilter, viewFilter, tenantFilter, zonesToValidate, "", func(records []Record) {
+		allRecords = append(allRecords, records...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allRecords, nil
+}
+
+// fetchDNSRecordPages fetches DNS records from NetBox page by page, handing each
+// page to handle as soon as it arrives. If ordering is set it is passed to NetBox
+// to fix the order in which records are returned.
+func fetchDNSRecordPages(baseURL, token string, headers http.Header, logger log.Logger, zoneFilter, viewFilter, tenantFilter string, zonesToValidate []string, ordering string, handle func([]Record)) error {
 	offset := 0
 	limit := 50
 
 	// Parse the base URL
 	parsedBaseURL, err := url.Parse(strings.TrimRight(baseURL, "/"))
 	if err != nil {
-		return nil, fmt.Errorf("invalid base URL: %v", err)
+		return fmt.Errorf("invalid base URL: %v", err)
 	}
 
 	for {
@@ -33,6 +48,9 @@ func getAllDNSRecords(baseURL, token string, logger log.Logger, zoneFilter, view
 		query := parsedURL.Query()
 		query.Set("limit", fmt.Sprintf("%d", limit))
 		query.Set("offset", fmt.Sprintf("%d", offset))
+		if ordering != "" {
+			query.Set("ordering", ordering)
+		}
 		// Apply filters
 		if zoneFilter != "" {
 			query.Set("zone__name", zoneFilter)
@@ -40,6 +58,9 @@ func getAllDNSRecords(baseURL, token string, logger log.Logger, zoneFilter, view
 		if viewFilter != "" {
 			query.Set("zone__view__name", viewFilter)
 		}
+		if tenantFilter != "" {
+			query.Set("tenant", tenantFilter)
+		}
 		if len(zonesToValidate) > 0 {
 			// Filter by zones from nameserver's zones
 			query.Set("zone__name__in", strings.Join(zonesToValidate, ","))
@@ -51,21 +72,21 @@ func getAllDNSRecords(baseURL, token string, logger log.Logger, zoneFilter, view
 		// Add debug log for the outgoing request URL
 		level.Debug(logger).Log("msg", "Requesting NetBox API", "url", apiURL)
 
-		records, err := getDNSRecords(apiURL, token, logger)
+		records, err := getDNSRecords(apiURL, token, headers, logger)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		allRecords = append(allRecords, records...)
+		handle(records)
 		if len(records) < limit {
 			break
 		}
 		offset += limit
 	}
-	return allRecords, nil
+	return nil
 }
 
-// Fetch Nameservers and their Zones from NetBox with filter
-func getAllNameservers(baseURL, token string, logger log.Logger, nameserverFilter string) ([]Nameserver, error) {
+// Fetch Nameservers and their Zones from NetBox with filters
+func getAllNameservers(baseURL, token string, headers http.Header, logger log.Logger, nameserverFilter, tenantFilter string) ([]Nameserver, error) {
 	var allNameservers []Nameserver
 	offset := 0
 	limit := 50
@@ -88,6 +109,9 @@ func getAllNameservers(baseURL, token string, logger log.Logger, nameserverFilte
 		if nameserverFilter != "" {
 			query.Set("name", nameserverFilter)
 		}
+		if tenantFilter != "" {
+			query.Set("tenant", tenantFilter)
+		}
 		parsedURL.RawQuery = query.Encode()
 
 		apiURL := parsedURL.String()
@@ -95,7 +119,7 @@ func getAllNameservers(baseURL, token string, logger log.Logger, nameserverFilte
 		// Add debug log for the outgoing request URL
 		level.Debug(logger).Log("msg", "Requesting NetBox Nameservers API", "url", apiURL)
 
-		nameservers, err := getNameservers(apiURL, token, logger)
+		nameservers, err := getNameservers(apiURL, token, headers, logger)
 		if err != nil {
 			return nil, err
 		}
@@ -109,15 +133,13 @@ func getAllNameservers(baseURL, token string, logger log.Logger, nameserverFilte
 }
 
 // Fetch DNS Records from NetBox
-func getDNSRecords(apiURL, token string, logger log.Logger) ([]Record, error) {
+func getDNSRecords(apiURL, token string, headers http.Header, logger log.Logger) ([]Record, error) {
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := newNetBoxRequest(apiURL, token, headers)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Token "+token)
-
 	// Log the outgoing request
 	level.Debug(logger).Log("msg", "Sending request to NetBox", "method", req.Method, "url", req.URL.String())
 
@@ -135,14 +157,21 @@ func getDNSRecords(apiURL, token string, logger log.Logger) ([]Record, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		level.Error(logger).Log("msg", "Non-OK HTTP response from NetBox", "status_code", resp.StatusCode, "body", string(bodyBytes))
-		return nil, fmt.Errorf("NetBox API returned status code %d", resp.StatusCode)
+		return nil, newNetBoxAPIError("NetBox API", resp.StatusCode, bodyBytes)
 	}
 
 	// Log the response body at debug level
 	level.Debug(logger).Log("msg", "Received response from NetBox")
 
+	return decodeRecords(bodyBytes, logger)
+}
+
+// decodeRecords parses a page of the NetBox records API. Plugin versions lay
+// records out differently, so the value is taken from rdata where value is
+// empty, and active is left nil where a version doesn't report it.
+func decodeRecords(body []byte, logger log.Logger) ([]Record, error) {
 	var apiResponse ApiResponse
-	err = json.Unmarshal(bodyBytes, &apiResponse)
+	err := json.Unmarshal(body, &apiResponse)
 	if err != nil {
 		// Log the error and the response body for debugging
 		level.Error(logger).Log("msg", "Failed to parse JSON response from NetBox", "err", err)
@@ -152,7 +181,12 @@ func getDNSRecords(apiURL, token string, logger log.Logger) ([]Record, error) {
 	// Populate ZoneName and ViewName for each record
 	for i := range apiResponse.Results {
 		record := &apiResponse.Results[i]
+		record.FQDN = canonicalFQDN(record.FQDN)
+		if record.Value == "" {
+			record.Value = record.RData
+		}
 		if record.Zone != nil {
+			record.Zone.Name = canonicalZoneName(record.Zone.Name)
 			record.ZoneName = record.Zone.Name
 			record.ZoneDefaultTTL = record.Zone.DefaultTTL
 			if record.Zone.View != nil {
@@ -168,15 +202,13 @@ func getDNSRecords(apiURL, token string, logger log.Logger) ([]Record, error) {
 }
 
 // Fetch Nameservers from NetBox
-func getNameservers(apiURL, token string, logger log.Logger) ([]Nameserver, error) {
+func getNameservers(apiURL, token string, headers http.Header, logger log.Logger) ([]Nameserver, error) {
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := newNetBoxRequest(apiURL, token, headers)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Token "+token)
-
 	// Log the outgoing request
 	level.Debug(logger).Log("msg", "Sending request to NetBox for Nameservers", "method", req.Method, "url", req.URL.String())
 
@@ -194,7 +226,7 @@ func getNameservers(apiURL, token string, logger log.Logger) ([]Nameserver, erro
 
 	if resp.StatusCode != http.StatusOK {
 		level.Error(logger).Log("msg", "Non-OK HTTP response from NetBox Nameservers API", "status_code", resp.StatusCode, "body", string(bodyBytes))
-		return nil, fmt.Errorf("NetBox Nameservers API returned status code %d", resp.StatusCode)
+		return nil, newNetBoxAPIError("NetBox Nameservers API", resp.StatusCode, bodyBytes)
 	}
 
 	// Log the response body at debug level
@@ -207,11 +239,14 @@ func getNameservers(apiURL, token string, logger log.Logger) ([]Nameserver, erro
 		level.Error(logger).Log("msg", "Failed to parse JSON Nameservers response from NetBox", "err", err)
 		return nil, err
 	}
+	for i := range nsResponse.Results {
+		canonicalizeZones(nsResponse.Results[i].Zones)
+	}
 
 	return nsResponse.Results, nil
 }
 
-func getAllZones(baseURL, token string, logger log.Logger) (map[int]Zone, error) {
+func getAllZones(baseURL, token string, headers http.Header, logger log.Logger, tenantFilter string) (map[int]Zone, error) {
 	zonesMap := make(map[int]Zone)
 	offset := 0
 	limit := 50
@@ -227,13 +262,16 @@ func getAllZones(baseURL, token string, logger log.Logger) (map[int]Zone, error)
 		query := parsedURL.Query()
 		query.Set("limit", fmt.Sprintf("%d", limit))
 		query.Set("offset", fmt.Sprintf("%d", offset))
+		if tenantFilter != "" {
+			query.Set("tenant", tenantFilter)
+		}
 		parsedURL.RawQuery = query.Encode()
 
 		apiURL := parsedURL.String()
 
 		level.Debug(logger).Log("msg", "Requesting NetBox Zones API", "url", apiURL)
 
-		zones, err := getZones(apiURL, token, logger)
+		zones, err := getZones(apiURL, token, headers, logger)
 		if err != nil {
 			return nil, err
 		}
@@ -251,15 +289,13 @@ func getAllZones(baseURL, token string, logger log.Logger) (map[int]Zone, error)
 }
 
 // Fetch Zones from NetBox
-func getZones(apiURL, token string, logger log.Logger) ([]Zone, error) {
+func getZones(apiURL, token string, headers http.Header, logger log.Logger) ([]Zone, error) {
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", apiURL, nil)
+	req, err := newNetBoxRequest(apiURL, token, headers)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Token "+token)
-
 	level.Debug(logger).Log("msg", "Sending request to NetBox for Zones", "method", req.Method, "url", req.URL.String())
 
 	resp, err := client.Do(req)
@@ -275,7 +311,7 @@ func getZones(apiURL, token string, logger log.Logger) ([]Zone, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		level.Error(logger).Log("msg", "Non-OK HTTP response from NetBox Zones API", "status_code", resp.StatusCode, "body", string(bodyBytes))
-		return nil, fmt.Errorf("NetBox Zones API returned status code %d", resp.StatusCode)
+		return nil, newNetBoxAPIError("NetBox Zones API", resp.StatusCode, bodyBytes)
 	}
 
 	level.Debug(logger).Log("msg", "Received Zones response from NetBox")
@@ -286,6 +322,93 @@ func getZones(apiURL, token string, logger log.Logger) ([]Zone, error) {
 		level.Error(logger).Log("msg", "Failed to parse JSON Zones response from NetBox", "err", err)
 		return nil, err
 	}
+	canonicalizeZones(zonesResponse.Results)
 
 	return zonesResponse.Results, nil
 }
+
+// newNetBoxAPIError builds the error for a non-OK NetBox response. NetBox's JSON
+// error bodies carry a human-readable "detail" message (e.g. "Invalid token"), or
+// per-field messages for rejected filters; these are included when present.
+// Bodies that aren't JSON only yield the status code.
+func newNetBoxAPIError(api string, statusCode int, body []byte) error {
+	var errorBody map[string]interface{}
+	if err := json.Unmarshal(body, &errorBody); err != nil || len(errorBody) == 0 {
+		return fmt.Errorf("%s returned status code %d (%s)", api, statusCode, http.StatusText(statusCode))
+	}
+
+	if detail, ok := errorBody["detail"].(string); ok && detail != "" {
+		return fmt.Errorf("%s returned status code %d: %s", api, statusCode, detail)
+	}
+
+	// Field errors, e.g. {"zone__name": ["Select a valid choice."]}
+	var messages []string
+	for field, value := range errorBody {
+		switch v := value.(type) {
+		case string:
+			messages = append(messages, fmt.Sprintf("%s: %s", field, v))
+		case []interface{}:
+			for _, item := range v {
+				messages = append(messages, fmt.Sprintf("%s: %v", field, item))
+			}
+		}
+	}
+	if len(messages) == 0 {
+		return fmt.Errorf("%s returned status code %d (%s)", api, statusCode, http.StatusText(statusCode))
+	}
+	sort.Strings(messages)
+	return fmt.Errorf("%s returned status code %d: %s", api, statusCode, strings.Join(messages, "; "))
+}
+
+// defaultNetBoxDNSPath is where the netbox-dns plugin API is mounted by default,
+// relative to the NetBox URL.
+const defaultNetBoxDNSPath = "/api/plugins/netbox-dns"
+
+// netboxDNSEndpoint returns the URL of a netbox-dns plugin API endpoint such as
+// "records", with the plugin API mounted at apiPath under the NetBox URL.
+func netboxDNSEndpoint(base *url.URL, apiPath, endpoint string) (string, error) {
+	endpointURL := resolveURL(base, path.Join("/", apiPath, endpoint))
+	u, err := url.Parse(endpointURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid NetBox DNS API URL %q: %v", endpointURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid NetBox DNS API URL %q: expected an http or https URL with a host", endpointURL)
+	}
+	return endpointURL, nil
+}
+
+// newNetBoxRequest builds a GET request to the NetBox API carrying the API token
+// and any extra headers, such as those an authenticating proxy in front of
+// NetBox requires.
+func newNetBoxRequest(apiURL, token string, headers http.Header) (*http.Request, error) {
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req.Header.Set("Authorization", "Token "+token)
+	return req, nil
+}
+
+// parseNetBoxHeaders parses "Name=value" pairs into the extra headers sent with
+// every NetBox request.
+func parseNetBoxHeaders(pairs []string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid NetBox header %q (expected name=value)", pair)
+		}
+		if strings.EqualFold(name, "Authorization") {
+			return nil, fmt.Errorf("invalid NetBox header %q: the Authorization header carries the API token", pair)
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+	return headers, nil
+}
@@ -0,0 +1,80 @@
+// netbox_test.go
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+func TestDecodeRecordsSchemaVariants(t *testing.T) {
+	tests := []struct {
+		fixture    string
+		wantActive bool
+	}{
+		// Newer plugin versions name the value "value" and report "active"
+		{"testdata/records_value.json", true},
+		// Older ones name it "rdata" and only have a status
+		{"testdata/records_rdata.json", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			body, err := os.ReadFile(tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			records, err := decodeRecords(body, log.NewNopLogger())
+			if err != nil {
+				t.Fatalf("decodeRecords: %v", err)
+			}
+			if len(records) != 2 {
+				t.Fatalf("got %d records, want 2", len(records))
+			}
+
+			www := records[0]
+			if www.Value != "192.0.2.1" || www.FQDN != "www.example.com." || www.ZoneName != "example.com" || www.ViewName != "default" || www.ZoneDefaultTTL != 3600 {
+				t.Errorf("got value %q, fqdn %q, zone %q, view %q, default TTL %d", www.Value, www.FQDN, www.ZoneName, www.ViewName, www.ZoneDefaultTTL)
+			}
+			if (www.Active != nil) != tt.wantActive {
+				t.Errorf("got active %v, want it reported: %v", www.Active, tt.wantActive)
+			}
+
+			// Either way, the second record is the one not published
+			active, inactive := splitInactiveRecords(records)
+			if len(inactive) != 1 || len(active) != 1 || active[0].Name != "www" {
+				t.Errorf("got %d active records and %d inactive, want only www active", len(active), len(inactive))
+			}
+		})
+	}
+}
+
+func TestDecodeRecordsTrailingDots(t *testing.T) {
+	body, err := os.ReadFile("testdata/records_trailing_dots.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := decodeRecords(body, log.NewNopLogger())
+	if err != nil {
+		t.Fatalf("decodeRecords: %v", err)
+	}
+
+	// However NetBox writes them, names come out in one form
+	for _, record := range records {
+		if record.ZoneName != "example.com" || record.Zone.Name != "example.com" || record.FQDN != record.Name+".example.com." {
+			t.Errorf("record %s: got zone %q (%q), fqdn %q", record.Name, record.ZoneName, record.Zone.Name, record.FQDN)
+		}
+	}
+
+	// and match the zone they are in
+	const addr = "127.0.53.6"
+	serveZone(t, addr, newTestZone(t, "example.com.",
+		"@ 3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 3600",
+		"www 3600 IN A 192.0.2.1",
+		"mail 3600 IN A 192.0.2.2",
+	))
+	if got := transferFrom(t, addr, records, ValidationOptions{}); len(got) != 0 {
+		t.Errorf("got findings %v, want none", got)
+	}
+}
@@ -0,0 +1,122 @@
+// netboxcheck.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// netboxCheck reports inconsistencies in the NetBox data itself before any DNS
+// query is made: records without a zone or whose zone NetBox didn't return,
+// nameservers serving zones that don't exist or have no view, and zones whose
+// records no nameserver serves. Each of these would otherwise only surface as a
+// scattered warning while validating, and the records involved would be
+// skipped silently.
+type netboxCheck struct {
+	zonesMap              map[int]Zone
+	zoneViewToNameservers map[string][]string
+	logger                log.Logger
+
+	mu     sync.Mutex
+	seen   map[string]bool
+	issues []Discrepancy
+}
+
+// newNetBoxCheck checks the nameservers against zonesMap and returns a
+// netboxCheck for checking records as they are fetched.
+func newNetBoxCheck(zonesMap map[int]Zone, nameservers []Nameserver, zoneViewToNameservers map[string][]string, logger log.Logger) *netboxCheck {
+	c := &netboxCheck{
+		zonesMap:              zonesMap,
+		zoneViewToNameservers: zoneViewToNameservers,
+		logger:                logger,
+		seen:                  make(map[string]bool),
+	}
+
+	for _, ns := range nameservers {
+		for _, zone := range ns.Zones {
+			if _, ok := zonesMap[zone.ID]; !ok {
+				c.add(zone.Name, fmt.Sprintf("Nameserver %s serves zone %s (ID %d), which NetBox didn't return", ns.Name, zone.Name, zone.ID))
+			}
+			if zone.View == nil {
+				c.add(zone.Name, fmt.Sprintf("Nameserver %s serves zone %s, which has no view", ns.Name, zone.Name))
+			}
+		}
+	}
+	return c
+}
+
+// add records an issue, once however many records run into it.
+func (c *netboxCheck) add(zoneName, message string) {
+	c.addRecordIssue("", zoneName, message)
+}
+
+// addRecordIssue records an issue with the record at fqdn, once however many
+// records run into it.
+func (c *netboxCheck) addRecordIssue(fqdn, zoneName, message string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen[message] {
+		return
+	}
+	c.seen[message] = true
+
+	if fqdn == "" && zoneName != "" {
+		fqdn = dns.Fqdn(zoneName)
+	}
+	level.Warn(c.logger).Log("msg", "NetBox data issue", "issue", message)
+	c.issues = append(c.issues, Discrepancy{
+		FQDN:     fqdn,
+		ZoneName: zoneName,
+		Message:  message,
+		Category: CategoryNetBoxData,
+	})
+}
+
+// checkRecords checks records as NetBox returned them, before they are
+// prepared for validation. It is safe to call on a nil netboxCheck.
+func (c *netboxCheck) checkRecords(records []Record) {
+	if c == nil {
+		return
+	}
+
+	for _, record := range records {
+		recordType := strings.ToUpper(record.Type)
+		if record.Zone == nil {
+			c.addRecordIssue(record.FQDN, "", fmt.Sprintf("%s record %s (ID %d) has no zone", recordType, record.FQDN, record.ID))
+			continue
+		}
+		if _, ok := c.zonesMap[record.Zone.ID]; !ok {
+			c.addRecordIssue("", record.ZoneName, fmt.Sprintf("Records reference zone %s (ID %d), which NetBox didn't return", record.ZoneName, record.Zone.ID))
+			continue
+		}
+		if record.ViewName == "" {
+			c.add(record.ZoneName, fmt.Sprintf("Zone %s has records but no view", record.ZoneName))
+			continue
+		}
+		if len(c.zoneViewToNameservers[zoneViewKey(record.ZoneName, record.ViewName)]) == 0 {
+			c.add(record.ZoneName, fmt.Sprintf("Zone %s in view %s has records but no nameservers", record.ZoneName, record.ViewName))
+		}
+	}
+}
+
+// report logs how many issues were found and returns them. It is safe to call
+// on a nil netboxCheck.
+func (c *netboxCheck) report() []Discrepancy {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.issues) == 0 {
+		level.Info(c.logger).Log("msg", "NetBox data is consistent")
+		return nil
+	}
+	level.Warn(c.logger).Log("msg", "NetBox data has consistency issues", "issues", len(c.issues))
+	return append([]Discrepancy{}, c.issues...)
+}
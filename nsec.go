@@ -0,0 +1,259 @@
+// nsec.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// checkNSECChain verifies the NSEC or NSEC3 chain of a transferred zone: every
+// name in the zone must have its record, and each record's next name must be
+// the following one in the chain, the last linking back to the first. Zones
+// signed with neither are skipped.
+func checkNSECChain(zoneName, server string, rrs []dns.RR, logger log.Logger) []Discrepancy {
+	apex := dns.Fqdn(strings.ToLower(zoneName))
+
+	var nsecs []*dns.NSEC
+	var nsec3s []*dns.NSEC3
+	var param *dns.NSEC3PARAM
+	for _, rr := range rrs {
+		switch v := rr.(type) {
+		case *dns.NSEC:
+			nsecs = append(nsecs, v)
+		case *dns.NSEC3:
+			nsec3s = append(nsec3s, v)
+		case *dns.NSEC3PARAM:
+			if strings.EqualFold(v.Hdr.Name, apex) {
+				param = v
+			}
+		}
+	}
+
+	names, insecure := authoritativeNames(apex, rrs)
+	switch {
+	case len(nsec3s) > 0:
+		return checkNSEC3Records(zoneName, server, apex, names, insecure, nsec3s, param, logger)
+	case len(nsecs) > 0:
+		return checkNSECRecords(zoneName, server, names, nsecs, logger)
+	default:
+		level.Debug(logger).Log("msg", "Zone has no NSEC or NSEC3 records, skipping chain check", "zone", zoneName)
+		return nil
+	}
+}
+
+// authoritativeNames returns the names a zone's NSEC or NSEC3 chain must cover:
+// every owner name except those below a delegation, whose records are glue.
+// It also returns the delegations without a DS record, which an NSEC3 chain
+// with opt-out may leave out.
+func authoritativeNames(apex string, rrs []dns.RR) (map[string]bool, map[string]bool) {
+	owners := make(map[string]bool)
+	delegations := make(map[string]bool)
+	signed := make(map[string]bool)
+	for _, rr := range rrs {
+		name := strings.ToLower(rr.Header().Name)
+		switch v := rr.(type) {
+		case *dns.NSEC3:
+			continue
+		case *dns.RRSIG:
+			if v.TypeCovered == dns.TypeNSEC3 {
+				continue
+			}
+		case *dns.NS:
+			if name != apex {
+				delegations[name] = true
+			}
+		case *dns.DS:
+			signed[name] = true
+		}
+		if dns.IsSubDomain(apex, name) {
+			owners[name] = true
+		}
+	}
+
+	names := make(map[string]bool)
+	for name := range owners {
+		if !belowDelegation(name, apex, delegations) {
+			names[name] = true
+		}
+	}
+	insecure := make(map[string]bool)
+	for name := range delegations {
+		if !signed[name] {
+			insecure[name] = true
+		}
+	}
+	return names, insecure
+}
+
+// belowDelegation reports whether name lies strictly below one of the
+// delegations of the zone at apex.
+func belowDelegation(name, apex string, delegations map[string]bool) bool {
+	labels := dns.SplitDomainName(name)
+	for i := 1; i < len(labels); i++ {
+		parent := dns.Fqdn(strings.Join(labels[i:], "."))
+		if parent == apex || !dns.IsSubDomain(apex, parent) {
+			return false
+		}
+		if delegations[parent] {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalLess orders domain names canonically (RFC 4034, section 6.1): by
+// their labels compared from the right, case-insensitively.
+func canonicalLess(a, b string) bool {
+	la := dns.SplitDomainName(strings.ToLower(a))
+	lb := dns.SplitDomainName(strings.ToLower(b))
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		x, y := la[len(la)-i], lb[len(lb)-i]
+		if x != y {
+			return x < y
+		}
+	}
+	return len(la) < len(lb)
+}
+
+// checkNSECRecords checks that each name has an NSEC record and that the NSEC
+// records, in canonical order, each point to the next.
+func checkNSECRecords(zoneName, server string, names map[string]bool, nsecs []*dns.NSEC, logger log.Logger) []Discrepancy {
+	var discrepancies []Discrepancy
+
+	byOwner := make(map[string]*dns.NSEC)
+	var owners []string
+	for _, nsec := range nsecs {
+		owner := strings.ToLower(nsec.Hdr.Name)
+		if _, ok := byOwner[owner]; !ok {
+			owners = append(owners, owner)
+		}
+		byOwner[owner] = nsec
+	}
+	sort.Slice(owners, func(i, j int) bool { return canonicalLess(owners[i], owners[j]) })
+
+	for _, name := range sortedNames(names) {
+		if _, ok := byOwner[name]; !ok {
+			discrepancies = append(discrepancies, nsecFinding(name, "NSEC", zoneName, server, nil, nil, fmt.Sprintf("No NSEC record for %s", name)))
+		}
+	}
+	for i, owner := range owners {
+		if !names[owner] {
+			discrepancies = append(discrepancies, nsecFinding(owner, "NSEC", zoneName, server, nil, nil, fmt.Sprintf("NSEC record for %s, which has no other records", owner)))
+		}
+		expected := owners[(i+1)%len(owners)]
+		if actual := strings.ToLower(byOwner[owner].NextDomain); actual != expected {
+			discrepancies = append(discrepancies, nsecFinding(owner, "NSEC", zoneName, server, []string{expected}, []string{actual},
+				fmt.Sprintf("Broken NSEC chain: %s links to %s instead of %s", owner, actual, expected)))
+		}
+	}
+
+	logNSECResult(zoneName, server, "NSEC", len(owners), discrepancies, logger)
+	return discrepancies
+}
+
+// checkNSEC3Records checks that each name, including empty non-terminals, has
+// an NSEC3 record for its hash and that the NSEC3 records, in hash order, each
+// point to the next. With opt-out, insecure delegations may have none.
+func checkNSEC3Records(zoneName, server, apex string, names, insecure map[string]bool, nsec3s []*dns.NSEC3, param *dns.NSEC3PARAM, logger log.Logger) []Discrepancy {
+	var discrepancies []Discrepancy
+
+	hashAlgorithm, iterations, salt := nsec3s[0].Hash, nsec3s[0].Iterations, nsec3s[0].Salt
+	if param != nil {
+		hashAlgorithm, iterations, salt = param.Hash, param.Iterations, param.Salt
+	}
+
+	byHash := make(map[string]*dns.NSEC3)
+	var hashes []string
+	optOut := false
+	for _, nsec3 := range nsec3s {
+		hash := strings.ToUpper(dns.SplitDomainName(nsec3.Hdr.Name)[0])
+		if _, ok := byHash[hash]; !ok {
+			hashes = append(hashes, hash)
+		}
+		byHash[hash] = nsec3
+		optOut = optOut || nsec3.Flags&1 == 1
+	}
+	sort.Strings(hashes)
+
+	// Empty non-terminals have an NSEC3 record too
+	expected := make(map[string]bool)
+	for name := range names {
+		for n := name; dns.IsSubDomain(apex, n); n = parentName(n) {
+			expected[n] = true
+			if n == apex {
+				break
+			}
+		}
+	}
+
+	known := make(map[string]bool)
+	for _, name := range sortedNames(expected) {
+		hash := dns.HashName(name, hashAlgorithm, iterations, salt)
+		known[hash] = true
+		if _, ok := byHash[hash]; ok || (optOut && insecure[name]) {
+			continue
+		}
+		discrepancies = append(discrepancies, nsecFinding(name, "NSEC3", zoneName, server, []string{hash}, nil, fmt.Sprintf("No NSEC3 record for %s (hash %s)", name, hash)))
+	}
+	for i, hash := range hashes {
+		owner := strings.ToLower(byHash[hash].Hdr.Name)
+		if !known[hash] {
+			discrepancies = append(discrepancies, nsecFinding(owner, "NSEC3", zoneName, server, nil, nil, fmt.Sprintf("NSEC3 record %s matches no name in the zone", hash)))
+		}
+		next := hashes[(i+1)%len(hashes)]
+		if actual := strings.ToUpper(byHash[hash].NextDomain); actual != next {
+			discrepancies = append(discrepancies, nsecFinding(owner, "NSEC3", zoneName, server, []string{next}, []string{actual},
+				fmt.Sprintf("Broken NSEC3 chain: %s links to %s instead of %s", hash, actual, next)))
+		}
+	}
+
+	logNSECResult(zoneName, server, "NSEC3", len(hashes), discrepancies, logger)
+	return discrepancies
+}
+
+// parentName returns the name one label above name, or "." for a top-level name.
+func parentName(name string) string {
+	labels := dns.SplitDomainName(name)
+	if len(labels) <= 1 {
+		return "."
+	}
+	return dns.Fqdn(strings.Join(labels[1:], "."))
+}
+
+// sortedNames returns the names of a set in canonical order, for stable output.
+func sortedNames(names map[string]bool) []string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return canonicalLess(sorted[i], sorted[j]) })
+	return sorted
+}
+
+// nsecFinding builds a discrepancy for a problem in a zone's NSEC or NSEC3 chain.
+func nsecFinding(fqdn, recordType, zoneName, server string, expected, actual []string, message string) Discrepancy {
+	return Discrepancy{
+		FQDN:       fqdn,
+		RecordType: recordType,
+		ZoneName:   zoneName,
+		Expected:   expected,
+		Actual:     actual,
+		Server:     server,
+		Message:    message,
+		Category:   CategoryNSECChain,
+	}
+}
+
+// logNSECResult logs the outcome of a zone's chain check.
+func logNSECResult(zoneName, server, recordType string, records int, discrepancies []Discrepancy, logger log.Logger) {
+	if len(discrepancies) > 0 {
+		level.Warn(logger).Log("msg", "Problems in zone's denial-of-existence chain", "zone", zoneName, "server", server, "type", recordType, "records", records, "problems", len(discrepancies))
+		return
+	}
+	level.Info(logger).Log("msg", "Zone's denial-of-existence chain is complete", "zone", zoneName, "server", server, "type", recordType, "records", records)
+}
@@ -10,7 +10,10 @@ import (
 	"github.com/go-kit/log/level"
 )
 
-func generateNSUpdateScripts(discrepancies []Discrepancy, nsupdatePath string, zonesByName map[string]Zone, logger log.Logger) error {
+// generateNSUpdateScripts writes one nsupdate script per server correcting the
+// given discrepancies. With ttlOnly set, the scripts are named nsupdate_ttls_*
+// to keep TTL audit fixes apart from regular remediation.
+func generateNSUpdateScripts(discrepancies []Discrepancy, nsupdatePath string, zonesByName map[string]Zone, ttlOnly bool, logger log.Logger) error {
 	if len(discrepancies) == 0 {
 		level.Info(logger).Log("msg", "No discrepancies found; nsupdate scripts not generated")
 		return nil
@@ -26,14 +29,24 @@ func generateNSUpdateScripts(discrepancies []Discrepancy, nsupdatePath string, z
 	serverZoneMap := make(map[string]map[string][]Discrepancy)
 
 	for _, d := range discrepancies {
+		// Findings not tied to an authoritative server (e.g. policy, resolver
+		// consensus or cache dump checks) have nothing to update
+		if d.Server == "" || d.Server == consensusServer || d.Server == cacheServer {
+			continue
+		}
 		if _, exists := serverZoneMap[d.Server]; !exists {
 			serverZoneMap[d.Server] = make(map[string][]Discrepancy)
 		}
 		serverZoneMap[d.Server][d.ZoneName] = append(serverZoneMap[d.Server][d.ZoneName], d)
 	}
 
+	filePrefix := "nsupdate"
+	if ttlOnly {
+		filePrefix = "nsupdate_ttls"
+	}
+
 	for server, zones := range serverZoneMap {
-		filename := filepath.Join(nsupdatePath, fmt.Sprintf("nsupdate_%s", server))
+		filename := filepath.Join(nsupdatePath, fmt.Sprintf("%s_%s", filePrefix, server))
 		file, err := os.Create(filename)
 		if err != nil {
 			level.Error(logger).Log("msg", "Failed to create nsupdate file", "file", filename, "err", err)
@@ -49,7 +62,16 @@ func generateNSUpdateScripts(discrepancies []Discrepancy, nsupdatePath string, z
 
 			for _, d := range zoneDiscrepancies {
 				switch d.RecordType {
-				case "A", "AAAA", "CNAME", "PTR", "NS":
+				case "A", "AAAA", "CNAME", "PTR", "NS", "MX", "SRV":
+					// A record set absent from DNS is only added; there is nothing to delete
+					if needsCreation(d) {
+						expectedValues, _ := reportValues(d.Expected)
+						for _, val := range expectedValues {
+							fmt.Fprintf(file, "update add %s %d %s %s\n", d.FQDN, d.ExpectedTTL, d.RecordType, val)
+						}
+						continue
+					}
+
 					expectedValues, ok := d.Expected.([]string)
 					if !ok {
 						continue
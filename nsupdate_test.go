@@ -0,0 +1,52 @@
+// nsupdate_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+func TestNeverCreatedRecordIsOnlyAdded(t *testing.T) {
+	const addr = "127.0.53.8"
+	serveZone(t, addr, newTestZone(t, "example.com",
+		"www 3600 IN A 192.0.2.1",
+	))
+
+	// A record set that was never created on the server needs creating
+	discrepancies := validateOn(t, addr, []Record{testRecord("new", "A", "192.0.2.5", 300), testRecord("new", "A", "192.0.2.6", 300)}, ValidationOptions{})
+	if len(discrepancies) != 1 || !needsCreation(discrepancies[0]) || remediationAction(discrepancies[0]) != "create" {
+		t.Fatalf("got findings %+v, want one needing creation", discrepancies)
+	}
+
+	dir := t.TempDir()
+	if err := generateNSUpdateScripts(discrepancies, dir, nil, false, log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+	script, err := os.ReadFile(filepath.Join(dir, "nsupdate_"+addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "server " + addr + "\nzone example.com\n" +
+		"update add new.example.com. 300 A 192.0.2.5\n" +
+		"update add new.example.com. 300 A 192.0.2.6\n" +
+		"send\n"
+	if string(script) != want {
+		t.Errorf("got nsupdate script\n%s\nwant\n%s", script, want)
+	}
+
+	report := filepath.Join(dir, "report.txt")
+	if err := generateReport(discrepancies, report, "table", log.NewNopLogger()); err != nil {
+		t.Fatal(err)
+	}
+	text, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(text), "Needs creation (1):") {
+		t.Errorf("report doesn't list the record set under Needs creation:\n%s", text)
+	}
+}
@@ -0,0 +1,60 @@
+// pluginversion.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// pluginNames are the names NetBox lists the netbox-dns plugin under in its
+// status API, depending on the plugin version.
+var pluginNames = []string{"netbox_dns", "netbox-dns"}
+
+// statusResponse is the part of NetBox's /api/status/ answer naming the
+// installed plugins and their versions.
+type statusResponse struct {
+	Plugins map[string]string `json:"plugins"`
+}
+
+// getPluginVersion asks NetBox's status API which version of the netbox-dns
+// plugin is installed. It returns "" if the plugin isn't listed.
+func getPluginVersion(statusURL, token string, headers http.Header, logger log.Logger) (string, error) {
+	client := &http.Client{}
+	req, err := newNetBoxRequest(statusURL, token, headers)
+	if err != nil {
+		return "", err
+	}
+
+	level.Debug(logger).Log("msg", "Sending request to NetBox", "method", req.Method, "url", req.URL.String())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newNetBoxAPIError("NetBox status API", resp.StatusCode, bodyBytes)
+	}
+
+	var status statusResponse
+	if err := json.Unmarshal(bodyBytes, &status); err != nil {
+		return "", fmt.Errorf("failed to parse NetBox status: %v", err)
+	}
+	for _, name := range pluginNames {
+		if version, ok := status.Plugins[name]; ok {
+			return version, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,92 @@
+// policy.go
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// checkTTLPolicy flags NetBox records whose expected TTL falls outside the
+// allowed band. A bound of zero is not enforced. This is a data-governance check
+// and does not depend on what DNS actually serves.
+func checkTTLPolicy(records []Record, zonesByName map[string]Zone, minTTL, maxTTL int, nsApexTTLFromSOA bool, logger log.Logger) []Discrepancy {
+	var discrepancies []Discrepancy
+
+	for _, record := range records {
+		recordType := strings.ToUpper(record.Type)
+		ttl := resolveExpectedTTL(record, recordType, zonesByName, nsApexTTLFromSOA, logger)
+
+		var violation string
+		if minTTL > 0 && ttl < minTTL {
+			violation = fmt.Sprintf("TTL policy violation: TTL %d is below the minimum of %d", ttl, minTTL)
+		} else if maxTTL > 0 && ttl > maxTTL {
+			violation = fmt.Sprintf("TTL policy violation: TTL %d is above the maximum of %d", ttl, maxTTL)
+		} else {
+			continue
+		}
+
+		level.Warn(logger).Log("msg", "TTL policy violation", "fqdn", record.FQDN, "type", recordType, "ttl", ttl)
+		discrepancy := Discrepancy{
+			FQDN:        record.FQDN,
+			RecordType:  recordType,
+			ZoneName:    record.ZoneName,
+			Expected:    []string{record.Value},
+			ExpectedTTL: ttl,
+			Message:     violation,
+			Category:    CategoryTTLPolicy,
+		}
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	return discrepancies
+}
+
+// DenylistEntry is a record type and value that must never be served by DNS.
+type DenylistEntry struct {
+	Type  string `mapstructure:"type"`
+	Value string `mapstructure:"value"`
+}
+
+// Denylist holds the values that should trigger a finding whenever a server returns them.
+type Denylist []DenylistEntry
+
+// contains reports whether the given record type and value are denied.
+func (d Denylist) contains(recordType, value string) bool {
+	value = strings.TrimSuffix(strings.TrimSpace(value), ".")
+	for _, entry := range d {
+		if !strings.EqualFold(entry.Type, recordType) {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSuffix(strings.TrimSpace(entry.Value), "."), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// forbiddenValues returns a finding for every actual value on the denylist,
+// regardless of whether NetBox expects it. Findings are logged to findingLogger,
+// which already carries their log level.
+func (d Denylist) forbiddenValues(fqdn, recordType, zoneName, server string, actualValues []string, findingLogger log.Logger) []Discrepancy {
+	var discrepancies []Discrepancy
+	for _, value := range actualValues {
+		if !d.contains(recordType, value) {
+			continue
+		}
+		findingLogger.Log("msg", "Forbidden value present in DNS", "fqdn", fqdn, "type", recordType, "value", value, "server", server)
+		discrepancy := Discrepancy{
+			FQDN:       fqdn,
+			RecordType: recordType,
+			ZoneName:   zoneName,
+			Actual:     []string{value},
+			Server:     server,
+			Message:    fmt.Sprintf("Forbidden value present: %s", value),
+			Category:   CategoryForbiddenValue,
+		}
+		discrepancies = append(discrepancies, discrepancy)
+	}
+	return discrepancies
+}
@@ -0,0 +1,62 @@
+// primary.go
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// restrictToPrimary narrows the nameservers of each zone to the primary named by
+// the zone's SOA MName. Zones whose MName is not one of their NetBox nameservers
+// keep all of their servers and are reported.
+func restrictToPrimary(zoneViewToNameservers map[string][]string, zonesMap map[int]Zone, logger log.Logger) (map[string][]string, []Discrepancy) {
+	primaries := make(map[string][]string)
+	var discrepancies []Discrepancy
+
+	for _, zone := range zonesMap {
+		if zone.View == nil {
+			continue
+		}
+		key := zoneViewKey(zone.Name, zone.View.Name)
+		servers := zoneViewToNameservers[key]
+		if len(servers) == 0 {
+			continue
+		}
+
+		mname := ""
+		if zone.SoaMName != nil {
+			mname = zone.SoaMName.Name
+		}
+
+		primary := ""
+		for _, server := range servers {
+			if mname != "" && strings.EqualFold(strings.TrimSuffix(server, "."), strings.TrimSuffix(mname, ".")) {
+				primary = server
+				break
+			}
+		}
+
+		if primary == "" {
+			level.Warn(logger).Log("msg", "SOA MName is not a nameserver of the zone, validating against all servers", "zone", zone.Name, "view", zone.View.Name, "mname", mname)
+			discrepancies = append(discrepancies, Discrepancy{
+				FQDN:       zone.Name,
+				RecordType: "SOA",
+				ZoneName:   zone.Name,
+				Expected:   servers,
+				Actual:     mname,
+				Message:    fmt.Sprintf("SOA MName %q does not correspond to any NetBox nameserver of the zone", mname),
+				Category:   CategoryUnknownPrimary,
+			})
+			primaries[key] = servers
+			continue
+		}
+
+		level.Debug(logger).Log("msg", "Validating zone against its primary only", "zone", zone.Name, "view", zone.View.Name, "primary", primary)
+		primaries[key] = []string{primary}
+	}
+
+	return primaries, discrepancies
+}
@@ -0,0 +1,144 @@
+// ptr_validator.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// validateDisabledPTRs confirms that A/AAAA records marked with DisablePTR in
+// NetBox have no PTR record in their reverse zone.
+func validateDisabledPTRs(records []Record, servers []string, logger log.Logger, zoneViewToNameservers map[string][]string, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	var wg sync.WaitGroup
+	var findings findingSet
+
+	for _, record := range records {
+		recordType := strings.ToUpper(record.Type)
+		if !record.DisablePTR || (recordType != "A" && recordType != "AAAA") {
+			continue
+		}
+
+		wg.Add(1)
+		go func(record Record) {
+			defer wg.Done()
+
+			reverseName, err := dns.ReverseAddr(record.Value)
+			if err != nil {
+				level.Warn(logger).Log("msg", "Cannot derive reverse name for address", "fqdn", record.FQDN, "value", record.Value, "err", err)
+				return
+			}
+
+			reverseZone, reverseServers := findParentZoneServers(reverseName, record.ViewName, zoneViewToNameservers)
+			if len(reverseServers) == 0 {
+				level.Debug(logger).Log("msg", "No reverse zone found for address with PTR disabled, skipping", "fqdn", record.FQDN, "reverse", reverseName)
+				return
+			}
+
+			discrepancies, successfulValidations := validateNoPTR(record, reverseName, reverseZone, reverseServers, logger, opts)
+			findings.add(discrepancies, successfulValidations, nil)
+		}(record)
+	}
+
+	wg.Wait()
+	return findings.discrepancies, findings.successful
+}
+
+// validateNoPTR queries each reverse zone server and reports any PTR served for reverseName.
+func validateNoPTR(record Record, reverseName, reverseZone string, servers []string, logger log.Logger, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	var discrepancies []Discrepancy
+	var successfulValidations []ValidationRecord
+
+	for _, server := range servers {
+		level.Debug(logger).Log("msg", "Checking that no PTR exists", "fqdn", record.FQDN, "reverse", reverseName, "server", server)
+		opts.Throttle.acquire(server)
+		resp, err := queryDNSWithRetry(reverseName, dns.TypePTR, server, opts.Query)
+		opts.Throttle.release(server)
+		if err != nil {
+			level.Warn(logger).Log("msg", "DNS query error", "fqdn", reverseName, "server", server, "err", err)
+			discrepancy := Discrepancy{
+				FQDN:       reverseName,
+				RecordType: "PTR",
+				ZoneName:   reverseZone,
+				Expected:   []string{},
+				Server:     server,
+				Message:    fmt.Sprintf("DNS query error: %v", err),
+				Category:   CategoryQueryError,
+			}
+			discrepancies = append(discrepancies, discrepancy)
+			continue
+		}
+
+		actualValues := []string{}
+		actualTTL := 0
+		for _, ans := range resp.Answer {
+			if rr, ok := ans.(*dns.PTR); ok {
+				actualValues = append(actualValues, rr.Ptr)
+				actualTTL = int(rr.Hdr.Ttl)
+			}
+		}
+
+		if len(actualValues) > 0 {
+			level.Warn(logger).Log("msg", "PTR record present for address with PTR disabled", "fqdn", record.FQDN, "reverse", reverseName, "server", server)
+			discrepancy := Discrepancy{
+				FQDN:       reverseName,
+				RecordType: "PTR",
+				ZoneName:   reverseZone,
+				Expected:   []string{},
+				Actual:     actualValues,
+				ActualTTL:  actualTTL,
+				Server:     server,
+				Message:    fmt.Sprintf("PTR record present although PTR is disabled for %s", record.FQDN),
+				Category:   CategoryMismatch,
+			}
+			discrepancies = append(discrepancies, discrepancy)
+			continue
+		}
+
+		level.Info(logger).Log("msg", "No PTR record present as expected", "fqdn", record.FQDN, "reverse", reverseName, "server", server)
+		if opts.RecordSuccessful {
+			validationRecord := ValidationRecord{
+				FQDN:       reverseName,
+				RecordType: "PTR",
+				ZoneName:   reverseZone,
+				Expected:   []string{},
+				Actual:     actualValues,
+				Server:     server,
+				Message:    fmt.Sprintf("No PTR record present for %s as expected", record.FQDN),
+			}
+			successfulValidations = append(successfulValidations, validationRecord)
+		}
+	}
+
+	tagClientSubnet(discrepancies, opts.Query)
+	opts.FailFast.record(discrepancies)
+	return discrepancies, successfulValidations
+}
+
+// unmanagedReverseZone reports PTR records whose reverse zone is not among the
+// zones fetched from NetBox, so operators can decide whether to add it, instead
+// of validating them against nameservers NetBox doesn't know.
+func unmanagedReverseZone(key RecordKey, records []Record, logger log.Logger) Discrepancy {
+	var values []string
+	for _, record := range records {
+		values = append(values, record.Value)
+	}
+
+	zone := key.ZoneName
+	if zone == "" {
+		zone = "for " + key.FQDN
+	}
+	level.Info(logger).Log("msg", "Reverse zone not managed in NetBox, skipping PTR validation", "fqdn", key.FQDN, "zone", key.ZoneName, "view", key.ViewName)
+	return Discrepancy{
+		FQDN:       key.FQDN,
+		RecordType: "PTR",
+		ZoneName:   key.ZoneName,
+		Expected:   values,
+		Message:    fmt.Sprintf("Reverse zone %s not managed in NetBox", zone),
+		Category:   CategoryReverseUnmanaged,
+	}
+}
@@ -0,0 +1,107 @@
+// recordcount.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// defaultRecordCountTolerance is the percentage by which a zone's AXFR record
+// count may differ from NetBox's before the zone is flagged.
+const defaultRecordCountTolerance = 50
+
+// ZoneRecordCount is the number of records NetBox holds for a zone and the
+// number a server transferred for it.
+type ZoneRecordCount struct {
+	Zone     string
+	Server   string
+	Expected int
+	Actual   int
+}
+
+// recordCounts collects the per-zone record counts of a run and flags zones
+// whose counts differ by more than tolerance percent, as a quick sign that
+// something is very wrong before looking at individual records.
+type recordCounts struct {
+	tolerance int
+
+	mu     sync.Mutex
+	counts []ZoneRecordCount
+}
+
+// newRecordCounts returns a recordCounts flagging differences above tolerance percent.
+func newRecordCounts(tolerance int) *recordCounts {
+	return &recordCounts{tolerance: tolerance}
+}
+
+// check records the counts of a zone and reports whether they differ wildly.
+// It is safe to call on a nil recordCounts, which checks nothing.
+func (c *recordCounts) check(count ZoneRecordCount, logger log.Logger) (Discrepancy, bool) {
+	if c == nil {
+		return Discrepancy{}, false
+	}
+
+	c.mu.Lock()
+	c.counts = append(c.counts, count)
+	c.mu.Unlock()
+
+	level.Info(logger).Log("msg", "Zone record count", "zone", count.Zone, "server", count.Server, "netbox", count.Expected, "axfr", count.Actual)
+
+	diff := count.Actual - count.Expected
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff*100 <= c.tolerance*count.Expected && !(count.Expected == 0 && count.Actual > 0) {
+		return Discrepancy{}, false
+	}
+
+	level.Warn(logger).Log("msg", "Zone record counts differ", "zone", count.Zone, "server", count.Server, "netbox", count.Expected, "axfr", count.Actual, "tolerance_percent", c.tolerance)
+	return Discrepancy{
+		FQDN:       dns.Fqdn(count.Zone),
+		RecordType: "AXFR",
+		ZoneName:   count.Zone,
+		Expected:   []string{fmt.Sprint(count.Expected)},
+		Actual:     []string{fmt.Sprint(count.Actual)},
+		Server:     count.Server,
+		Message:    fmt.Sprintf("Zone has %d records in NetBox but %d in the zone transfer (tolerance %d%%)", count.Expected, count.Actual, c.tolerance),
+		Category:   CategoryRecordCount,
+	}, true
+}
+
+// all returns the counts recorded so far, ordered by zone.
+func (c *recordCounts) all() []ZoneRecordCount {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := append([]ZoneRecordCount(nil), c.counts...)
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Zone < counts[j].Zone })
+	return counts
+}
+
+// countTransferredRecords counts the records of an AXFR that NetBox could hold.
+// The closing SOA and the DNSSEC records a signer generates are left out.
+func countTransferredRecords(rrs []dns.RR) int {
+	count := 0
+	soaSeen := false
+	for _, rr := range rrs {
+		switch rr.Header().Rrtype {
+		case dns.TypeSOA:
+			if soaSeen {
+				continue
+			}
+			soaSeen = true
+		case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3, dns.TypeNSEC3PARAM:
+			continue
+		}
+		count++
+	}
+	return count
+}
@@ -0,0 +1,164 @@
+// remediation.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// RemediationCounts is the number of changes of each kind a set of findings calls for.
+type RemediationCounts struct {
+	Create      int
+	Update      int
+	FixTTL      int
+	Delete      int
+	Investigate int
+}
+
+// ZoneRemediation is the changes one zone needs.
+type ZoneRemediation struct {
+	Zone string
+	RemediationCounts
+}
+
+// RemediationPlan groups the findings of a run by the action that resolves
+// them, as an overview of the change before any nsupdate script is run.
+type RemediationPlan struct {
+	RemediationCounts
+	Zones []ZoneRemediation
+}
+
+// remediationAction returns the kind of change that resolves d. Findings no
+// update can fix, such as query errors or lame delegations, need investigating.
+func remediationAction(d Discrepancy) string {
+	switch {
+	case needsCreation(d):
+		return "create"
+	case d.Category == CategoryTTLDrift:
+		return "ttl"
+	case d.Category == CategoryMismatch || d.Category == CategoryUnexpectedCNAME:
+		return "update"
+	case d.Category == CategoryLingeringRecord:
+		return "delete"
+	default:
+		return "investigate"
+	}
+}
+
+// add counts one change of the given action.
+func (c *RemediationCounts) add(action string) {
+	switch action {
+	case "create":
+		c.Create++
+	case "update":
+		c.Update++
+	case "ttl":
+		c.FixTTL++
+	case "delete":
+		c.Delete++
+	default:
+		c.Investigate++
+	}
+}
+
+// newRemediationPlan groups discrepancies, and the records served but missing
+// from NetBox, which need deleting, by action and zone.
+func newRemediationPlan(discrepancies []Discrepancy, missingRecords []MissingRecord) RemediationPlan {
+	var plan RemediationPlan
+	byZone := make(map[string]*ZoneRemediation)
+	count := func(zoneName, action string) {
+		plan.add(action)
+		zone, ok := byZone[zoneName]
+		if !ok {
+			zone = &ZoneRemediation{Zone: zoneName}
+			byZone[zoneName] = zone
+		}
+		zone.add(action)
+	}
+
+	for _, d := range discrepancies {
+		count(d.ZoneName, remediationAction(d))
+	}
+	for _, m := range missingRecords {
+		count(m.ZoneName, "delete")
+	}
+
+	for _, zone := range byZone {
+		plan.Zones = append(plan.Zones, *zone)
+	}
+	sort.Slice(plan.Zones, func(i, j int) bool { return plan.Zones[i].Zone < plan.Zones[j].Zone })
+	return plan
+}
+
+// String summarizes the counts in one sentence, e.g. "Create 12 records, update
+// 4 values, fix 7 TTLs".
+func (c RemediationCounts) String() string {
+	var parts []string
+	if c.Create > 0 {
+		parts = append(parts, fmt.Sprintf("create %d %s", c.Create, plural(c.Create, "record", "records")))
+	}
+	if c.Update > 0 {
+		parts = append(parts, fmt.Sprintf("update %d %s", c.Update, plural(c.Update, "value", "values")))
+	}
+	if c.FixTTL > 0 {
+		parts = append(parts, fmt.Sprintf("fix %d %s", c.FixTTL, plural(c.FixTTL, "TTL", "TTLs")))
+	}
+	if c.Delete > 0 {
+		parts = append(parts, fmt.Sprintf("delete %d %s", c.Delete, plural(c.Delete, "extra", "extras")))
+	}
+	if c.Investigate > 0 {
+		parts = append(parts, fmt.Sprintf("investigate %d %s", c.Investigate, plural(c.Investigate, "finding", "findings")))
+	}
+	if len(parts) == 0 {
+		return "Nothing to change"
+	}
+	summary := strings.Join(parts, ", ")
+	return strings.ToUpper(summary[:1]) + summary[1:]
+}
+
+// String summarizes the plan, e.g. "Create 12 records, update 4 values, across 5 zones".
+func (p RemediationPlan) String() string {
+	if len(p.Zones) == 0 {
+		return p.RemediationCounts.String()
+	}
+	return fmt.Sprintf("%s, across %d %s", p.RemediationCounts, len(p.Zones), plural(len(p.Zones), "zone", "zones"))
+}
+
+// plural picks the singular or plural form of a word for n.
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return singular
+	}
+	return pluralForm
+}
+
+// writeRemediationFile writes the plan to path: the overall summary followed by
+// one line per zone, or the plan in the JSON report envelope with the json
+// report format.
+func writeRemediationFile(plan RemediationPlan, path, reportFormat string, logger log.Logger) error {
+	file, err := createReportFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to create remediation summary file: %v", err)
+	}
+	defer file.Close()
+
+	level.Debug(logger).Log("msg", "Writing remediation summary", "file", path, "zones", len(plan.Zones))
+	if reportFormat == "json" {
+		return writeJSONReport(file, plan)
+	}
+
+	fmt.Fprintln(file, plan)
+	fmt.Fprintln(file)
+	for _, zone := range plan.Zones {
+		name := zone.Zone
+		if name == "" {
+			name = "(no zone)"
+		}
+		fmt.Fprintf(file, "%s: %s\n", name, zone.RemediationCounts)
+	}
+	return nil
+}
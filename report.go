@@ -5,19 +5,250 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 )
 
+// reportSchemaVersion identifies the layout of JSON reports. Bump it whenever
+// the shape of JSONReport or JSONFinding changes.
+const reportSchemaVersion = 1
+
+// JSONReport is the versioned envelope written for JSON-formatted reports.
+type JSONReport struct {
+	SchemaVersion int         `json:"SchemaVersion"`
+	Results       interface{} `json:"Results"`
+}
+
+// JSONFinding is the typed JSON representation of a discrepancy or successful
+// validation. Expected and Actual are always arrays of strings; SOA records are
+// additionally rendered as structured sub-objects.
+type JSONFinding struct {
+	FQDN        string     `json:"FQDN"`
+	RecordType  string     `json:"RecordType"`
+	ZoneName    string     `json:"ZoneName"`
+	Expected    []string   `json:"Expected"`
+	Actual      []string   `json:"Actual"`
+	ExpectedSOA *SOARecord `json:"ExpectedSOA,omitempty"`
+	ActualSOA   *SOARecord `json:"ActualSOA,omitempty"`
+	ExpectedTTL int        `json:"ExpectedTTL"`
+	ActualTTL   int        `json:"ActualTTL"`
+	Server      string     `json:"Server"`
+	Message     string     `json:"Message,omitempty"`
+	// ClientSubnet is only set for discrepancies found with an EDNS client subnet.
+	ClientSubnet string `json:"ClientSubnet,omitempty"`
+	// Category and Severity are only set for discrepancies.
+	Category string `json:"Category,omitempty"`
+	Severity string `json:"Severity,omitempty"`
+	// ExtendedError is only set for discrepancies where the server returned Extended DNS Errors.
+	ExtendedError string `json:"ExtendedError,omitempty"`
+	// NearMiss is only set for validations that passed within the TTL tolerance.
+	NearMiss bool `json:"NearMiss,omitempty"`
+	// Description is only set for discrepancies whose NetBox records have one.
+	Description string `json:"Description,omitempty"`
+	// ElapsedMs is only set for findings from a query to a server.
+	ElapsedMs int64 `json:"ElapsedMs,omitempty"`
+	// NeedsCreation is only set for discrepancies whose record set is absent from DNS.
+	NeedsCreation bool `json:"NeedsCreation,omitempty"`
+}
+
+// newJSONFindingFromDiscrepancy converts a Discrepancy to its typed JSON form.
+func newJSONFindingFromDiscrepancy(d Discrepancy) JSONFinding {
+	expected, expectedSOA := reportValues(d.Expected)
+	actual, actualSOA := reportValues(d.Actual)
+	return JSONFinding{
+		FQDN:          d.FQDN,
+		RecordType:    d.RecordType,
+		ZoneName:      d.ZoneName,
+		Expected:      expected,
+		Actual:        actual,
+		ExpectedSOA:   expectedSOA,
+		ActualSOA:     actualSOA,
+		ExpectedTTL:   d.ExpectedTTL,
+		ActualTTL:     d.ActualTTL,
+		Server:        d.Server,
+		Message:       d.Message,
+		ClientSubnet:  d.ClientSubnet,
+		Category:      d.Category,
+		Severity:      d.Severity,
+		ExtendedError: d.ExtendedError,
+		Description:   d.Description,
+		ElapsedMs:     d.ElapsedMs,
+		NeedsCreation: needsCreation(d),
+	}
+}
+
+// newJSONFindingFromValidation converts a ValidationRecord to its typed JSON form.
+func newJSONFindingFromValidation(v ValidationRecord) JSONFinding {
+	expected, expectedSOA := reportValues(v.Expected)
+	actual, actualSOA := reportValues(v.Actual)
+	return JSONFinding{
+		FQDN:        v.FQDN,
+		RecordType:  v.RecordType,
+		ZoneName:    v.ZoneName,
+		Expected:    expected,
+		Actual:      actual,
+		ExpectedSOA: expectedSOA,
+		ActualSOA:   actualSOA,
+		ExpectedTTL: v.ExpectedTTL,
+		ActualTTL:   v.ActualTTL,
+		Server:      v.Server,
+		Message:     v.Message,
+		NearMiss:    v.NearMiss,
+		ElapsedMs:   v.ElapsedMs,
+	}
+}
+
+// reportValues normalizes an Expected/Actual value to a list of strings, also
+// returning the structured SOA record when the value is one.
+func reportValues(value interface{}) ([]string, *SOARecord) {
+	switch v := value.(type) {
+	case nil:
+		return []string{}, nil
+	case []string:
+		return append([]string{}, v...), nil
+	case string:
+		if v == "" {
+			return []string{}, nil
+		}
+		return []string{v}, nil
+	case SOARecord:
+		return []string{v.String()}, &v
+	default:
+		return []string{fmt.Sprintf("%v", v)}, nil
+	}
+}
+
+// needsCreation reports whether d is for a record set entirely absent from DNS,
+// which is fixed by adding it rather than by correcting values.
+func needsCreation(d Discrepancy) bool {
+	switch d.Category {
+	case CategoryNXDOMAIN, CategoryNoData, CategoryMissing:
+	default:
+		return false
+	}
+	if _, ok := d.Expected.(SOARecord); ok {
+		return false
+	}
+	expected, _ := reportValues(d.Expected)
+	actual, _ := reportValues(d.Actual)
+	return len(expected) > 0 && len(actual) == 0
+}
+
+// reportOrderKey is the sort key of a finding, so reports list findings in the
+// same order on every run however the concurrent validators finished.
+func reportOrderKey(zoneName, fqdn, recordType, server string, rest ...string) []string {
+	return append([]string{zoneName, strings.ToLower(fqdn), strings.ToUpper(recordType), server}, rest...)
+}
+
+// lessKey compares two sort keys field by field.
+func lessKey(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// sortDiscrepancies orders discrepancies by zone, FQDN, type and server.
+func sortDiscrepancies(discrepancies []Discrepancy) {
+	sort.SliceStable(discrepancies, func(i, j int) bool {
+		a, b := discrepancies[i], discrepancies[j]
+		return lessKey(
+			reportOrderKey(a.ZoneName, a.FQDN, a.RecordType, a.Server, a.ClientSubnet, a.Category, a.Message, fmt.Sprint(a.Actual)),
+			reportOrderKey(b.ZoneName, b.FQDN, b.RecordType, b.Server, b.ClientSubnet, b.Category, b.Message, fmt.Sprint(b.Actual)),
+		)
+	})
+}
+
+// sortValidations orders successful validations by zone, FQDN, type and server.
+func sortValidations(validations []ValidationRecord) {
+	sort.SliceStable(validations, func(i, j int) bool {
+		a, b := validations[i], validations[j]
+		return lessKey(
+			reportOrderKey(a.ZoneName, a.FQDN, a.RecordType, a.Server, a.Message, fmt.Sprint(a.Actual)),
+			reportOrderKey(b.ZoneName, b.FQDN, b.RecordType, b.Server, b.Message, fmt.Sprint(b.Actual)),
+		)
+	})
+}
+
+// elapsedMsField renders a query duration for CSV reports, leaving it empty for
+// findings that didn't come from a query.
+func elapsedMsField(elapsedMs int64) string {
+	if elapsedMs == 0 {
+		return ""
+	}
+	return fmt.Sprint(elapsedMs)
+}
+
+// filterValidationsByType keeps the successful validations of the given record
+// types, plus every near-miss, and returns how many were dropped.
+func filterValidationsByType(validations []ValidationRecord, recordTypes []string) ([]ValidationRecord, int) {
+	keep := make(map[string]bool)
+	for _, recordType := range recordTypes {
+		keep[strings.ToUpper(strings.TrimSpace(recordType))] = true
+	}
+
+	var kept []ValidationRecord
+	for _, v := range validations {
+		if v.NearMiss || keep[strings.ToUpper(v.RecordType)] {
+			kept = append(kept, v)
+		}
+	}
+	return kept, len(validations) - len(kept)
+}
+
+// sortMissingRecords orders extra DNS records by zone, FQDN, type, server and value.
+func sortMissingRecords(missingRecords []MissingRecord) {
+	sort.SliceStable(missingRecords, func(i, j int) bool {
+		a, b := missingRecords[i], missingRecords[j]
+		return lessKey(
+			reportOrderKey(a.ZoneName, a.FQDN, a.RecordType, a.Server, a.Value),
+			reportOrderKey(b.ZoneName, b.FQDN, b.RecordType, b.Server, b.Value),
+		)
+	})
+}
+
+// stdoutReportFile is the report file name that selects standard output.
+const stdoutReportFile = "-"
+
+// nopWriteCloser wraps a writer that must not be closed, such as os.Stdout.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// createReportFile opens the destination for a report, treating "-" as stdout.
+func createReportFile(reportFile string) (io.WriteCloser, error) {
+	if reportFile == stdoutReportFile {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.Create(reportFile)
+}
+
+// writeJSONReport writes results wrapped in the versioned report envelope.
+func writeJSONReport(file io.Writer, results interface{}) error {
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(JSONReport{
+		SchemaVersion: reportSchemaVersion,
+		Results:       results,
+	})
+}
+
 func generateReport(discrepancies []Discrepancy, reportFile string, reportFormat string, logger log.Logger) error {
 	if len(discrepancies) == 0 {
 		level.Info(logger).Log("msg", "No discrepancies found")
 		return nil
 	}
 
-	file, err := os.Create(reportFile)
+	file, err := createReportFile(reportFile)
 	if err != nil {
 		return fmt.Errorf("failed to create report file: %v", err)
 	}
@@ -25,14 +256,16 @@ func generateReport(discrepancies []Discrepancy, reportFile string, reportFormat
 
 	switch reportFormat {
 	case "json":
-		encoder := json.NewEncoder(file)
-		encoder.SetIndent("", "  ")
-		return encoder.Encode(discrepancies)
+		findings := make([]JSONFinding, 0, len(discrepancies))
+		for _, d := range discrepancies {
+			findings = append(findings, newJSONFindingFromDiscrepancy(d))
+		}
+		return writeJSONReport(file, findings)
 	case "csv":
 		writer := csv.NewWriter(file)
 		defer writer.Flush()
 
-		header := []string{"FQDN", "Zone Name", "Type", "Expected", "Actual", "Expected TTL", "Actual TTL", "Server", "Message"}
+		header := []string{"FQDN", "Zone Name", "Type", "Expected", "Actual", "Expected TTL", "Actual TTL", "Server", "Message", "Client Subnet", "Category", "Severity", "Extended Error", "Description", "Elapsed Ms", "Needs Creation"}
 		err := writer.Write(header)
 		if err != nil {
 			return err
@@ -51,6 +284,13 @@ func generateReport(discrepancies []Discrepancy, reportFile string, reportFormat
 				fmt.Sprintf("%d", d.ActualTTL),
 				d.Server,
 				d.Message,
+				d.ClientSubnet,
+				d.Category,
+				d.Severity,
+				d.ExtendedError,
+				d.Description,
+				elapsedMsField(d.ElapsedMs),
+				fmt.Sprint(needsCreation(d)),
 			}
 			err := writer.Write(record)
 			if err != nil {
@@ -58,10 +298,26 @@ func generateReport(discrepancies []Discrepancy, reportFile string, reportFormat
 			}
 		}
 	default:
-		// Default to table format
+		// Default to table format, listing record sets to create ahead of the rest
+		var creations, corrections []Discrepancy
 		for _, d := range discrepancies {
-			fmt.Fprintf(file, "FQDN: %s\nZone Name: %s\nType: %s\nExpected: %v\nActual: %v\nExpected TTL: %d\nActual TTL: %d\nServer: %s\nMessage: %s\n\n",
-				d.FQDN, d.ZoneName, d.RecordType, d.Expected, d.Actual, d.ExpectedTTL, d.ActualTTL, d.Server, d.Message)
+			if needsCreation(d) {
+				creations = append(creations, d)
+			} else {
+				corrections = append(corrections, d)
+			}
+		}
+		if len(creations) > 0 {
+			fmt.Fprintf(file, "Needs creation (%d):\n\n", len(creations))
+			for _, d := range creations {
+				writeDiscrepancyEntry(file, d)
+			}
+			if len(corrections) > 0 {
+				fmt.Fprintf(file, "Needs correction (%d):\n\n", len(corrections))
+			}
+		}
+		for _, d := range corrections {
+			writeDiscrepancyEntry(file, d)
 		}
 	}
 
@@ -74,7 +330,7 @@ func generateSuccessfulReport(validations []ValidationRecord, reportFile string,
 		return nil
 	}
 
-	file, err := os.Create(reportFile)
+	file, err := createReportFile(reportFile)
 	if err != nil {
 		return fmt.Errorf("failed to create successful validations report file: %v", err)
 	}
@@ -82,14 +338,16 @@ func generateSuccessfulReport(validations []ValidationRecord, reportFile string,
 
 	switch reportFormat {
 	case "json":
-		encoder := json.NewEncoder(file)
-		encoder.SetIndent("", "  ")
-		return encoder.Encode(validations)
+		findings := make([]JSONFinding, 0, len(validations))
+		for _, v := range validations {
+			findings = append(findings, newJSONFindingFromValidation(v))
+		}
+		return writeJSONReport(file, findings)
 	case "csv":
 		writer := csv.NewWriter(file)
 		defer writer.Flush()
 
-		header := []string{"FQDN", "Zone Name", "Type", "Expected", "Actual", "Expected TTL", "Actual TTL", "Server", "Message"}
+		header := []string{"FQDN", "Zone Name", "Type", "Expected", "Actual", "Expected TTL", "Actual TTL", "Server", "Message", "Elapsed Ms"}
 		err := writer.Write(header)
 		if err != nil {
 			return err
@@ -108,6 +366,7 @@ func generateSuccessfulReport(validations []ValidationRecord, reportFile string,
 				fmt.Sprintf("%d", v.ActualTTL),
 				v.Server,
 				v.Message,
+				elapsedMsField(v.ElapsedMs),
 			}
 			err := writer.Write(record)
 			if err != nil {
@@ -131,7 +390,7 @@ func generateMissingRecordsReport(missingRecords []MissingRecord, reportFile str
 		return nil
 	}
 
-	file, err := os.Create(reportFile)
+	file, err := createReportFile(reportFile)
 	if err != nil {
 		return fmt.Errorf("failed to create missing records report file: %v", err)
 	}
@@ -139,9 +398,7 @@ func generateMissingRecordsReport(missingRecords []MissingRecord, reportFile str
 
 	switch reportFormat {
 	case "json":
-		encoder := json.NewEncoder(file)
-		encoder.SetIndent("", "  ")
-		return encoder.Encode(missingRecords)
+		return writeJSONReport(file, missingRecords)
 	case "csv":
 		writer := csv.NewWriter(file)
 		defer writer.Flush()
@@ -0,0 +1,59 @@
+// retrybudget.go
+package main
+
+import (
+	"sync"
+)
+
+// retryBudget caps the number of query retries made across the whole run, so a
+// widespread outage doesn't multiply into a flood of queries against servers
+// that are already struggling. Once it is spent, failed queries are reported
+// after their first attempt.
+type retryBudget struct {
+	limit int
+
+	mu      sync.Mutex
+	used    int
+	refused int
+}
+
+// RetryBudgetUsage reports how much of the retry budget a run consumed.
+type RetryBudgetUsage struct {
+	Limit int `json:"Limit"`
+	Used  int `json:"Used"`
+	// Refused is the number of retries not made because the budget was spent.
+	Refused int `json:"Refused"`
+}
+
+// newRetryBudget returns a retryBudget allowing limit retries in total.
+func newRetryBudget(limit int) *retryBudget {
+	return &retryBudget{limit: limit}
+}
+
+// take reports whether another retry may be made, spending it from the budget.
+// It is safe to call on a nil retryBudget, which allows every retry.
+func (b *retryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used >= b.limit {
+		b.refused++
+		return false
+	}
+	b.used++
+	return true
+}
+
+// usage returns how much of the budget was spent, or nil for a nil retryBudget.
+func (b *retryBudget) usage() *RetryBudgetUsage {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return &RetryBudgetUsage{Limit: b.limit, Used: b.used, Refused: b.refused}
+}
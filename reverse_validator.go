@@ -0,0 +1,146 @@
+// reverse_validator.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// reverseTrees are the roots of the reverse DNS trees. A zone at or above them
+// does not count as a reverse zone for an address.
+var reverseTrees = []string{"in-addr.arpa.", "ip6.arpa."}
+
+// reverseCandidate groups the addresses whose reverse names share a parent
+// domain, so the reverse zone holding them is looked up once.
+type reverseCandidate struct {
+	Parent   string
+	ViewName string
+}
+
+// validateReverseZones reports A/AAAA records whose address has no reverse zone
+// to hold its PTR, neither in NetBox nor in DNS. Records with PTR disabled are
+// skipped.
+func validateReverseZones(records []Record, servers []string, logger log.Logger, zoneViewToNameservers map[string][]string, zonesByName map[string]Zone, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
+	candidates := make(map[reverseCandidate][]Record)
+	for _, record := range records {
+		recordType := strings.ToUpper(record.Type)
+		if record.DisablePTR || (recordType != "A" && recordType != "AAAA") {
+			continue
+		}
+
+		reverseName, err := dns.ReverseAddr(record.Value)
+		if err != nil {
+			level.Warn(logger).Log("msg", "Cannot derive reverse name for address", "fqdn", record.FQDN, "value", record.Value, "err", err)
+			continue
+		}
+		candidate := reverseCandidate{
+			Parent:   getParentZoneName(strings.TrimSuffix(reverseName, ".")),
+			ViewName: record.ViewName,
+		}
+		candidates[candidate] = append(candidates[candidate], record)
+	}
+
+	var resolver string
+	if config, err := dns.ClientConfigFromFile("/etc/resolv.conf"); err == nil && len(config.Servers) > 0 {
+		resolver = config.Servers[0]
+	} else {
+		level.Warn(logger).Log("msg", "No system resolver found, reverse zones are only looked up in NetBox", "err", err)
+	}
+
+	var wg sync.WaitGroup
+	var findings findingSet
+
+	for candidate, addressRecords := range candidates {
+		wg.Add(1)
+		go func(candidate reverseCandidate, addressRecords []Record) {
+			defer wg.Done()
+
+			var discrepancies []Discrepancy
+			var successful []ValidationRecord
+			reverseZone, source := findReverseZone(candidate, resolver, zoneViewToNameservers, zonesByName, logger, opts)
+			for _, record := range addressRecords {
+				reverseName, _ := dns.ReverseAddr(record.Value)
+				if reverseZone != "" {
+					level.Debug(logger).Log("msg", "Reverse zone covers address", "fqdn", record.FQDN, "address", record.Value, "reverse_zone", reverseZone, "source", source)
+					if opts.RecordSuccessful {
+						successful = append(successful, ValidationRecord{
+							FQDN:       record.FQDN,
+							RecordType: strings.ToUpper(record.Type),
+							ZoneName:   record.ZoneName,
+							Expected:   []string{reverseName},
+							Actual:     []string{reverseZone},
+							Message:    fmt.Sprintf("Reverse zone %s (%s) covers %s", reverseZone, source, record.Value),
+						})
+					}
+					continue
+				}
+
+				level.Warn(logger).Log("msg", "No reverse zone for address", "fqdn", record.FQDN, "address", record.Value, "reverse", reverseName)
+				discrepancies = append(discrepancies, Discrepancy{
+					FQDN:       record.FQDN,
+					RecordType: strings.ToUpper(record.Type),
+					ZoneName:   record.ZoneName,
+					Expected:   []string{reverseName},
+					Actual:     []string{},
+					Message:    fmt.Sprintf("No reverse zone exists to hold the PTR for %s", record.Value),
+					Category:   CategoryMissingReverseZone,
+				})
+			}
+			findings.add(discrepancies, successful, nil)
+		}(candidate, addressRecords)
+	}
+
+	wg.Wait()
+
+	opts.FailFast.record(findings.discrepancies)
+	return findings.discrepancies, findings.successful
+}
+
+// findReverseZone returns the reverse zone enclosing candidate's addresses and
+// where it was found ("netbox" or "dns"), or "" if there is none. NetBox zones
+// of the same view are preferred; otherwise the system resolver is asked which
+// zone holds the name.
+func findReverseZone(candidate reverseCandidate, resolver string, zoneViewToNameservers map[string][]string, zonesByName map[string]Zone, logger log.Logger, opts ValidationOptions) (string, string) {
+	for name := candidate.Parent; name != "" && !isReverseTreeRoot(name); name = getParentZoneName(name) {
+		if len(zoneViewToNameservers[zoneViewKey(name, candidate.ViewName)]) > 0 {
+			return name, "netbox"
+		}
+		if _, ok := zonesByName[zoneViewKey(name, candidate.ViewName)]; ok {
+			level.Debug(logger).Log("msg", "Reverse zone exists in NetBox without nameservers in this view", "zone", name, "view", candidate.ViewName)
+			return name, "netbox"
+		}
+	}
+
+	if resolver == "" {
+		return "", ""
+	}
+
+	// The SOA of the enclosing zone comes back in the answer or authority section
+	resp, err := queryDNSWithRetry(dns.Fqdn(candidate.Parent), dns.TypeSOA, resolver, opts.Query.recursive())
+	if resp == nil {
+		level.Warn(logger).Log("msg", "Could not look up reverse zone", "name", candidate.Parent, "resolver", resolver, "err", err)
+		return "", ""
+	}
+	for _, rr := range append(resp.Answer, resp.Ns...) {
+		if soa, ok := rr.(*dns.SOA); ok && !isReverseTreeRoot(soa.Hdr.Name) && dns.IsSubDomain(soa.Hdr.Name, dns.Fqdn(candidate.Parent)) {
+			return strings.TrimSuffix(soa.Hdr.Name, "."), "dns"
+		}
+	}
+	return "", ""
+}
+
+// isReverseTreeRoot reports whether name is a root of the reverse trees or above.
+func isReverseTreeRoot(name string) bool {
+	name = dns.Fqdn(strings.ToLower(name))
+	for _, root := range reverseTrees {
+		if dns.IsSubDomain(name, root) {
+			return true
+		}
+	}
+	return false
+}
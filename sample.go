@@ -0,0 +1,109 @@
+// sample.go
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// SampleInfo describes the sample a --sample-percent run validated, so a smoke
+// test can be reproduced with the same seed.
+type SampleInfo struct {
+	Percent float64
+	Seed    int64
+	Records int64
+	Sampled int64
+}
+
+// recordSampler keeps a pseudo-random share of the RRsets it sees, for quick
+// smoke tests against large instances. Whole RRsets are kept or dropped, as a
+// partial RRset would not match DNS, and the choice depends only on the RRset
+// and the seed, so it is stable across batches and reproducible across runs.
+type recordSampler struct {
+	percent float64
+	seed    int64
+	total   int64
+	sampled int64
+}
+
+// newRecordSampler returns a recordSampler keeping percent of RRsets. A seed of
+// zero is replaced by one derived from the current time.
+func newRecordSampler(percent float64, seed int64) (*recordSampler, error) {
+	if percent <= 0 || percent > 100 {
+		return nil, fmt.Errorf("sample percentage %v is not between 0 and 100", percent)
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &recordSampler{percent: percent, seed: seed}, nil
+}
+
+// apply returns the records of the sampled RRsets. A nil recordSampler keeps
+// every record.
+func (s *recordSampler) apply(records []Record) []Record {
+	if s == nil {
+		return records
+	}
+
+	var kept []Record
+	for _, record := range records {
+		if s.keeps(record) {
+			kept = append(kept, record)
+		}
+	}
+	atomic.AddInt64(&s.total, int64(len(records)))
+	atomic.AddInt64(&s.sampled, int64(len(kept)))
+	return kept
+}
+
+// filter returns the records of the sampled RRsets without counting them, for
+// records checked alongside the sample such as disabled ones. A nil
+// recordSampler keeps every record.
+func (s *recordSampler) filter(records []Record) []Record {
+	if s == nil {
+		return records
+	}
+
+	var kept []Record
+	for _, record := range records {
+		if s.keeps(record) {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// keeps reports whether the RRset of record is in the sample.
+func (s *recordSampler) keeps(record Record) bool {
+	h := fnv.New64a()
+	binary.Write(h, binary.BigEndian, s.seed)
+	h.Write([]byte(strings.ToLower(record.FQDN) + "|" + strings.ToUpper(record.Type) + "|" + record.ZoneName + "|" + record.ViewName))
+	return float64(h.Sum64()%10000) < s.percent*100
+}
+
+// info returns the sample's parameters and size, or nil without sampling.
+func (s *recordSampler) info() *SampleInfo {
+	if s == nil {
+		return nil
+	}
+	return &SampleInfo{
+		Percent: s.percent,
+		Seed:    s.seed,
+		Records: atomic.LoadInt64(&s.total),
+		Sampled: atomic.LoadInt64(&s.sampled),
+	}
+}
+
+// report logs the sample size and the seed that reproduces it.
+func (s *recordSampler) report(logger log.Logger) {
+	if info := s.info(); info != nil {
+		level.Info(logger).Log("msg", "Validated a sample of the records", "percent", info.Percent, "seed", info.Seed, "sampled", info.Sampled, "records", info.Records)
+	}
+}
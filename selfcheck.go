@@ -0,0 +1,90 @@
+// selfcheck.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/go-kit/log"
+	"github.com/miekg/dns"
+)
+
+// selfCheck writes the results of the --check pre-flight checks, one line per
+// check, and remembers whether any failed.
+type selfCheck struct {
+	w      io.Writer
+	failed bool
+}
+
+// pass reports a check that succeeded.
+func (c *selfCheck) pass(check, detail string) {
+	fmt.Fprintf(c.w, "OK    %s: %s\n", check, detail)
+}
+
+// fail reports a check that failed.
+func (c *selfCheck) fail(check string, err error) {
+	c.failed = true
+	fmt.Fprintf(c.w, "FAIL  %s: %v\n", check, err)
+}
+
+// runSelfCheck checks that a validation run could start, without validating
+// anything: NetBox accepts the token, the netbox-dns plugin is installed and its
+// API answers, and a nameserver answers DNS queries. It returns whether every
+// check passed.
+func runSelfCheck(w io.Writer, base *url.URL, nameserversEndpoint, token string, headers http.Header, nameserverFilter string, queryOpts QueryOptions, logger log.Logger) bool {
+	c := &selfCheck{w: w}
+
+	version, err := getPluginVersion(resolveURL(base, "/api/status")+"/", token, headers, logger)
+	switch {
+	case err != nil:
+		c.fail("NetBox API", err)
+	case version == "":
+		c.pass("NetBox API", "reachable, token accepted")
+		c.fail("netbox-dns plugin", fmt.Errorf("NetBox doesn't list the plugin as installed"))
+	default:
+		c.pass("NetBox API", "reachable, token accepted")
+		c.pass("netbox-dns plugin", "version "+version)
+	}
+
+	// One page of nameservers is enough to prove the plugin API answers
+	apiURL, err := url.Parse(nameserversEndpoint)
+	if err != nil {
+		c.fail("netbox-dns API", err)
+		return !c.failed
+	}
+	query := apiURL.Query()
+	query.Set("limit", "1")
+	if nameserverFilter != "" {
+		query.Set("name", nameserverFilter)
+	}
+	apiURL.RawQuery = query.Encode()
+	nameservers, err := getNameservers(apiURL.String(), token, headers, logger)
+	switch {
+	case err != nil:
+		c.fail("netbox-dns API", err)
+		return !c.failed
+	case len(nameservers) == 0:
+		c.fail("netbox-dns API", fmt.Errorf("no nameservers found"))
+		return !c.failed
+	}
+	c.pass("netbox-dns API", "nameservers readable")
+
+	ns := nameservers[0]
+	name := "."
+	if len(ns.Zones) > 0 {
+		name = dns.Fqdn(ns.Zones[0].Name)
+	}
+	resp, err := queryDNSWithRetry(name, dns.TypeSOA, ns.Name, queryOpts)
+	switch {
+	case resp == nil:
+		c.fail("DNS", fmt.Errorf("nameserver %s did not answer: %v", ns.Name, err))
+	case resp.Rcode != dns.RcodeSuccess:
+		c.pass("DNS", fmt.Sprintf("nameserver %s answered the SOA query for %s with %s", ns.Name, name, dns.RcodeToString[resp.Rcode]))
+	default:
+		c.pass("DNS", fmt.Sprintf("nameserver %s answered the SOA query for %s", ns.Name, name))
+	}
+
+	return !c.failed
+}
@@ -0,0 +1,171 @@
+// serialreport.go
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// ServerSerial is the SOA serial one server returned for a zone. Error is set
+// instead when the server gave no serial.
+type ServerSerial struct {
+	Server string
+	Serial uint32
+	// Difference is Serial minus the NetBox serial.
+	Difference int64
+	Error      string `json:",omitempty"`
+	// Outlier is set when the server does not serve the NetBox serial.
+	Outlier bool
+}
+
+// ZoneSerials lays out the NetBox serial of a zone next to each server's.
+type ZoneSerials struct {
+	Zone     string
+	View     string
+	Expected uint32
+	Servers  []ServerSerial
+	Outliers int
+}
+
+// serialTable collects the SOA serials of every validated zone for the
+// --serial-report, which shows a stale secondary at a glance.
+type serialTable struct {
+	mu    sync.Mutex
+	zones []ZoneSerials
+}
+
+// newSerialTable returns an empty serialTable.
+func newSerialTable() *serialTable {
+	return &serialTable{}
+}
+
+// add records the serials the servers of a zone returned, and the reason for
+// each server that returned none. It is safe to call on a nil serialTable,
+// which records nothing.
+func (t *serialTable) add(record Record, expected uint32, servers []string, serials map[string]uint32, failures map[string]string) {
+	if t == nil {
+		return
+	}
+
+	zone := ZoneSerials{Zone: record.ZoneName, View: record.ViewName, Expected: expected}
+	if zone.Zone == "" {
+		zone.Zone = record.FQDN
+	}
+	for _, server := range servers {
+		entry := ServerSerial{Server: server}
+		if serial, ok := serials[server]; ok {
+			entry.Serial = serial
+			entry.Difference = int64(serial) - int64(expected)
+			entry.Outlier = serial != expected
+		} else {
+			entry.Error = failures[server]
+			if entry.Error == "" {
+				entry.Error = "no SOA in answer"
+			}
+			entry.Outlier = true
+		}
+		if entry.Outlier {
+			zone.Outliers++
+		}
+		zone.Servers = append(zone.Servers, entry)
+	}
+	sort.Slice(zone.Servers, func(i, j int) bool { return zone.Servers[i].Server < zone.Servers[j].Server })
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.zones = append(t.zones, zone)
+}
+
+// all returns the zones recorded so far, ordered by zone and view.
+func (t *serialTable) all() []ZoneSerials {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	zones := append([]ZoneSerials(nil), t.zones...)
+	sort.Slice(zones, func(i, j int) bool {
+		if zones[i].Zone != zones[j].Zone {
+			return zones[i].Zone < zones[j].Zone
+		}
+		return zones[i].View < zones[j].View
+	})
+	return zones
+}
+
+// generateSerialReport writes each zone's NetBox serial and the serial of every
+// server side by side, marking the servers that differ. The table format lists
+// one block per zone; CSV has one row per zone and server.
+func generateSerialReport(zones []ZoneSerials, reportFile string, reportFormat string, logger log.Logger) error {
+	file, err := createReportFile(reportFile)
+	if err != nil {
+		return fmt.Errorf("failed to create serial report file: %v", err)
+	}
+	defer file.Close()
+
+	outliers := 0
+	for _, zone := range zones {
+		if zone.Outliers > 0 {
+			outliers++
+		}
+	}
+	level.Info(logger).Log("msg", "Writing SOA serial report", "zones", len(zones), "zones_with_outliers", outliers)
+
+	switch reportFormat {
+	case "json":
+		return writeJSONReport(file, zones)
+	case "csv":
+		writer := csv.NewWriter(file)
+		defer writer.Flush()
+
+		err := writer.Write([]string{"Zone", "View", "NetBox Serial", "Server", "Serial", "Difference", "Outlier", "Error"})
+		if err != nil {
+			return err
+		}
+		for _, zone := range zones {
+			for _, s := range zone.Servers {
+				serial, difference := "", ""
+				if s.Error == "" {
+					serial, difference = fmt.Sprint(s.Serial), fmt.Sprint(s.Difference)
+				}
+				err := writer.Write([]string{zone.Zone, zone.View, fmt.Sprint(zone.Expected), s.Server, serial, difference, fmt.Sprint(s.Outlier), s.Error})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		// Default to table format
+		for _, zone := range zones {
+			fmt.Fprintf(file, "Zone: %s", zone.Zone)
+			if zone.View != "" {
+				fmt.Fprintf(file, " (view %s)", zone.View)
+			}
+			fmt.Fprintf(file, "\nNetBox serial: %d\n", zone.Expected)
+
+			tw := tabwriter.NewWriter(file, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "  SERVER\tSERIAL\tDIFF\tSTATUS")
+			for _, s := range zone.Servers {
+				switch {
+				case s.Error != "":
+					fmt.Fprintf(tw, "  %s\t-\t-\t%s\n", s.Server, s.Error)
+				case s.Outlier:
+					fmt.Fprintf(tw, "  %s\t%d\t%+d\tOUTLIER\n", s.Server, s.Serial, s.Difference)
+				default:
+					fmt.Fprintf(tw, "  %s\t%d\t0\tok\n", s.Server, s.Serial)
+				}
+			}
+			tw.Flush()
+			fmt.Fprintln(file)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+// servermap.go
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// ZoneServers is the set of servers one zone and view will be validated
+// against. Note explains why a zone won't be validated, if it won't.
+type ZoneServers struct {
+	Zone    string
+	View    string
+	Servers []string
+	// AXFRServer is the server the zone is transferred from with --use-axfr.
+	AXFRServer string `json:",omitempty"`
+	Note       string `json:",omitempty"`
+}
+
+// buildServerMap lays out the effective mapping of each zone and view to its
+// nameservers, after view servers, exclusions and the primary or authority
+// restrictions were applied, for the zones passing the zone and view filters.
+// Zones NetBox has no nameservers for, and nameserver assignments to zones that
+// weren't fetched, are listed too, as both are mapping gaps.
+func buildServerMap(zonesByName map[string]Zone, zoneViewToNameservers map[string][]string, zoneFilter, viewFilter string, useAXFR bool) []ZoneServers {
+	keys := make(map[string]bool)
+	for key := range zonesByName {
+		keys[key] = true
+	}
+	for key := range zoneViewToNameservers {
+		keys[key] = true
+	}
+
+	var entries []ZoneServers
+	for key := range keys {
+		zoneName, viewName, _ := strings.Cut(key, "|")
+		if zoneFilter != "" && zoneName != zoneFilter {
+			continue
+		}
+		if viewFilter != "" && viewName != viewFilter {
+			continue
+		}
+
+		entry := ZoneServers{Zone: zoneName, View: viewName}
+		entry.Servers = append(entry.Servers, zoneViewToNameservers[key]...)
+		sort.Strings(entry.Servers)
+		if _, ok := zonesByName[key]; !ok {
+			entry.Note = "zone not fetched from NetBox"
+		} else if useAXFR {
+			if servers := axfrServers(zoneName, viewFilter, zoneViewToNameservers); len(servers) > 0 {
+				entry.AXFRServer = servers[0]
+			} else {
+				entry.Note = "no nameservers, not transferred"
+			}
+		} else if len(entry.Servers) == 0 {
+			entry.Note = "no nameservers, not validated"
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Zone != entries[j].Zone {
+			return entries[i].Zone < entries[j].Zone
+		}
+		return entries[i].View < entries[j].View
+	})
+	return entries
+}
+
+// writeServerMap writes the zone and view to nameservers mapping to path. CSV
+// has one row per zone and view, with the servers joined by spaces.
+func writeServerMap(entries []ZoneServers, path, reportFormat string, logger log.Logger) error {
+	file, err := createReportFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to create server map file: %v", err)
+	}
+	defer file.Close()
+
+	gaps := 0
+	for _, entry := range entries {
+		if entry.Note != "" {
+			gaps++
+		}
+	}
+	level.Info(logger).Log("msg", "Writing server map", "file", path, "zone_views", len(entries), "gaps", gaps)
+
+	switch reportFormat {
+	case "json":
+		return writeJSONReport(file, entries)
+	case "csv":
+		writer := csv.NewWriter(file)
+		defer writer.Flush()
+
+		err := writer.Write([]string{"Zone", "View", "Servers", "AXFR Server", "Note"})
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			err := writer.Write([]string{entry.Zone, entry.View, strings.Join(entry.Servers, " "), entry.AXFRServer, entry.Note})
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		// Default to table format
+		tw := tabwriter.NewWriter(file, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ZONE\tVIEW\tSERVERS\tAXFR\tNOTE")
+		for _, entry := range entries {
+			servers := strings.Join(entry.Servers, ", ")
+			if servers == "" {
+				servers = "-"
+			}
+			axfr := entry.AXFRServer
+			if axfr == "" {
+				axfr = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", entry.Zone, entry.View, servers, axfr, entry.Note)
+		}
+		tw.Flush()
+	}
+	return nil
+}
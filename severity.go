@@ -0,0 +1,179 @@
+// severity.go
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Discrepancy categories, used to assign severities.
+const (
+	CategoryNXDOMAIN        = "nxdomain"
+	CategoryMissing         = "missing"
+	CategoryMismatch        = "mismatch"
+	CategoryTTLDrift        = "ttl_drift"
+	CategoryUnexpectedCNAME = "unexpected_cname"
+	CategoryQueryError      = "query_error"
+	CategoryInvalid         = "invalid"
+	CategoryPropagation     = "propagation"
+	CategoryForbiddenValue  = "forbidden_value"
+	CategoryTTLPolicy       = "ttl_policy"
+	CategorySerialLag       = "serial_lag"
+	CategoryLameDelegation  = "lame_delegation"
+	CategoryUnknownPrimary  = "unknown_primary"
+	CategoryBrokenPrimary   = "broken_primary"
+	CategoryDanglingCNAME   = "dangling_cname"
+	// CategoryMissingReverseZone is an address with no reverse zone for its PTR.
+	CategoryMissingReverseZone = "missing_reverse_zone"
+	// CategoryReplicationLag is a zone whose servers trail the hidden primary.
+	CategoryReplicationLag = "replication_lag"
+	// CategoryRecordCount is a zone whose AXFR record count is far from NetBox's.
+	CategoryRecordCount = "record_count"
+	// CategoryInconsistentTTL is an RRset whose records have different TTLs in NetBox.
+	CategoryInconsistentTTL = "inconsistent_ttl"
+	// CategoryDuplicateZone is a zone NetBox holds more than once in one view.
+	CategoryDuplicateZone = "duplicate_zone"
+	// CategoryWildcardShadow is an explicit record answered from a covering wildcard.
+	CategoryWildcardShadow = "wildcard_shadow"
+	// CategoryNetBoxData is an inconsistency in the NetBox data itself.
+	CategoryNetBoxData = "netbox_data"
+	// CategoryNoData is a name that exists but has no records of the expected
+	// type (NOERROR NODATA), unlike CategoryNXDOMAIN for a name that doesn't exist.
+	CategoryNoData = "nodata"
+	// CategoryLingeringRecord is a record disabled in NetBox that DNS still serves.
+	CategoryLingeringRecord = "lingering_record"
+	// CategoryNSECChain is a gap or broken link in a zone's NSEC or NSEC3 chain.
+	CategoryNSECChain = "nsec_chain"
+	// CategoryTransferMismatch is a record a server transfers but answers differently, or not at all, when queried.
+	CategoryTransferMismatch = "transfer_mismatch"
+	// CategoryDanglingMX is an MX record whose exchange host has no address.
+	CategoryDanglingMX = "dangling_mx"
+	// CategoryDelegationTTL is a parent zone delegating with an NS TTL other than expected.
+	CategoryDelegationTTL = "delegation_ttl"
+	// CategoryAnswerCount is an answer holding a different number of records than its answer policy expects.
+	CategoryAnswerCount = "answer_count"
+	// CategoryReverseUnmanaged is a PTR record whose reverse zone is not among the zones fetched from NetBox.
+	CategoryReverseUnmanaged = "unmanaged_reverse_zone"
+	// CategoryCNAMELoop is a CNAME chain that loops back on itself or is longer than resolvers follow.
+	CategoryCNAMELoop = "cname_loop"
+	// CategoryDisplayMismatch is a NetBox record whose raw value and display disagree.
+	CategoryDisplayMismatch = "display_mismatch"
+)
+
+// Severity levels, from most to least urgent.
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// severityRanks orders the severity levels; higher is more urgent.
+var severityRanks = map[string]int{
+	SeverityInfo:     1,
+	SeverityWarning:  2,
+	SeverityCritical: 3,
+}
+
+// defaultSeverities is the severity of each category when no rule matches.
+// Categories not listed here are warnings.
+var defaultSeverities = map[string]string{
+	CategoryNXDOMAIN:           SeverityCritical,
+	CategoryNoData:             SeverityCritical,
+	CategoryLingeringRecord:    SeverityWarning,
+	CategoryNSECChain:          SeverityCritical,
+	CategoryTransferMismatch:   SeverityCritical,
+	CategoryDanglingMX:         SeverityWarning,
+	CategoryDelegationTTL:      SeverityWarning,
+	CategoryAnswerCount:        SeverityWarning,
+	CategoryReverseUnmanaged:   SeverityInfo,
+	CategoryCNAMELoop:          SeverityCritical,
+	CategoryDisplayMismatch:    SeverityWarning,
+	CategoryMissing:            SeverityCritical,
+	CategoryForbiddenValue:     SeverityCritical,
+	CategoryLameDelegation:     SeverityCritical,
+	CategoryBrokenPrimary:      SeverityCritical,
+	CategoryMismatch:           SeverityWarning,
+	CategoryUnexpectedCNAME:    SeverityWarning,
+	CategoryQueryError:         SeverityWarning,
+	CategoryInvalid:            SeverityWarning,
+	CategoryPropagation:        SeverityWarning,
+	CategorySerialLag:          SeverityWarning,
+	CategoryUnknownPrimary:     SeverityWarning,
+	CategoryDanglingCNAME:      SeverityWarning,
+	CategoryMissingReverseZone: SeverityWarning,
+	CategoryReplicationLag:     SeverityWarning,
+	CategoryRecordCount:        SeverityWarning,
+	CategoryInconsistentTTL:    SeverityWarning,
+	CategoryDuplicateZone:      SeverityWarning,
+	CategoryWildcardShadow:     SeverityWarning,
+	CategoryNetBoxData:         SeverityWarning,
+	CategoryTTLDrift:           SeverityInfo,
+	CategoryTTLPolicy:          SeverityInfo,
+}
+
+// SeverityRule assigns a severity to discrepancies of a category, optionally
+// only within one zone.
+type SeverityRule struct {
+	Category string `mapstructure:"category"`
+	Zone     string `mapstructure:"zone"`
+	Severity string `mapstructure:"severity"`
+}
+
+// SeverityRules are checked in order; the first matching rule wins.
+type SeverityRules []SeverityRule
+
+// validate checks that every rule names a known severity.
+func (r SeverityRules) validate() error {
+	for i, rule := range r {
+		if _, ok := severityRanks[strings.ToLower(rule.Severity)]; !ok {
+			return fmt.Errorf("severity rule %d: unknown severity %q", i+1, rule.Severity)
+		}
+	}
+	return nil
+}
+
+// severityFor returns the severity of a discrepancy.
+func (r SeverityRules) severityFor(d Discrepancy) string {
+	for _, rule := range r {
+		if rule.Category != "" && !strings.EqualFold(rule.Category, d.Category) {
+			continue
+		}
+		if rule.Zone != "" && !strings.EqualFold(strings.TrimSuffix(rule.Zone, "."), strings.TrimSuffix(d.ZoneName, ".")) {
+			continue
+		}
+		return strings.ToLower(rule.Severity)
+	}
+
+	if severity, ok := defaultSeverities[d.Category]; ok {
+		return severity
+	}
+	return SeverityWarning
+}
+
+// assignSeverities sets the severity of every discrepancy.
+func assignSeverities(discrepancies []Discrepancy, rules SeverityRules) {
+	for i := range discrepancies {
+		discrepancies[i].Severity = rules.severityFor(discrepancies[i])
+	}
+}
+
+// parseSeverity validates a severity level given on the command line.
+func parseSeverity(severity string) (string, error) {
+	severity = strings.ToLower(severity)
+	if _, ok := severityRanks[severity]; !ok {
+		return "", fmt.Errorf("unknown severity %q (expected critical, warning or info)", severity)
+	}
+	return severity, nil
+}
+
+// filterBySeverity keeps only the discrepancies at or above minSeverity.
+func filterBySeverity(discrepancies []Discrepancy, minSeverity string) []Discrepancy {
+	minRank := severityRanks[minSeverity]
+	var filtered []Discrepancy
+	for _, d := range discrepancies {
+		if severityRanks[d.Severity] >= minRank {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered
+}
@@ -2,19 +2,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/miekg/dns"
 )
 
-func validateSOARecords(records []Record, servers []string, ignoreSerialNumbers bool, logger log.Logger, nameservers []Nameserver, recordSuccessful bool) ([]Discrepancy, []ValidationRecord) {
+func validateSOARecords(records []Record, servers []string, logger log.Logger, zoneViewToNameservers map[string][]string, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
 	var wg sync.WaitGroup
-	discrepanciesChan := make(chan Discrepancy, len(records)*len(servers))
-	successfulChan := make(chan ValidationRecord, len(records)*len(servers))
+	var findings findingSet
 
 	// Filter SOA records
 	var soaRecords []Record
@@ -24,23 +27,18 @@ func validateSOARecords(records []Record, servers []string, ignoreSerialNumbers
 		}
 	}
 
-	// Create mapping of (zone, view) to nameservers
-	zoneViewToNameservers := make(map[string][]string)
-	for _, ns := range nameservers {
-		for _, zone := range ns.Zones {
-			if zone.View != nil {
-				key := fmt.Sprintf("%s|%s", zone.Name, zone.View.Name)
-				zoneViewToNameservers[key] = append(zoneViewToNameservers[key], ns.Name)
-			} else {
-				level.Warn(logger).Log("msg", "Zone has no associated view", "zone", zone.Name)
-			}
-		}
+	maxConcurrency := opts.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = defaultMaxConcurrency
 	}
+	slots := make(chan struct{}, maxConcurrency)
 
 	for _, record := range soaRecords {
+		slots <- struct{}{}
 		wg.Add(1)
 		go func(record Record) {
 			defer wg.Done()
+			defer func() { <-slots }()
 			key := RecordKey{
 				FQDN:       record.FQDN,
 				RecordType: "SOA",
@@ -49,8 +47,7 @@ func validateSOARecords(records []Record, servers []string, ignoreSerialNumbers
 			}
 			var recordServers []string
 			if key.ZoneName != "" && key.ViewName != "" {
-				zoneViewKey := fmt.Sprintf("%s|%s", key.ZoneName, key.ViewName)
-				recordServers = zoneViewToNameservers[zoneViewKey]
+				recordServers = zoneViewToNameservers[zoneViewKey(key.ZoneName, key.ViewName)]
 				if len(recordServers) == 0 {
 					// No nameservers found for this zone and view, skip validation
 					level.Warn(logger).Log("msg", "No nameservers found for zone in view, skipping validation", "zone", key.ZoneName, "view", key.ViewName)
@@ -62,34 +59,16 @@ func validateSOARecords(records []Record, servers []string, ignoreSerialNumbers
 				return
 			}
 
-			discrepancies, successfulValidations := validateSOARecord(record, recordServers, ignoreSerialNumbers, logger, recordSuccessful)
-			for _, d := range discrepancies {
-				discrepanciesChan <- d
-			}
-			for _, v := range successfulValidations {
-				successfulChan <- v
-			}
+			discrepancies, successfulValidations := validateSOARecord(record, recordServers, logger, opts)
+			findings.add(discrepancies, successfulValidations, nil)
 		}(record)
 	}
 
 	wg.Wait()
-	close(discrepanciesChan)
-	close(successfulChan)
-
-	var allDiscrepancies []Discrepancy
-	for d := range discrepanciesChan {
-		allDiscrepancies = append(allDiscrepancies, d)
-	}
-
-	var successfulValidations []ValidationRecord
-	for v := range successfulChan {
-		successfulValidations = append(successfulValidations, v)
-	}
-
-	return allDiscrepancies, successfulValidations
+	return findings.discrepancies, findings.successful
 }
 
-func validateSOARecord(record Record, servers []string, ignoreSerialNumbers bool, logger log.Logger, recordSuccessful bool) ([]Discrepancy, []ValidationRecord) {
+func validateSOARecord(record Record, servers []string, logger log.Logger, opts ValidationOptions) ([]Discrepancy, []ValidationRecord) {
 	expectedSOA := parseSOARecord(record)
 	if expectedSOA == nil {
 		level.Warn(logger).Log("msg", "Invalid SOA record format", "fqdn", record.FQDN)
@@ -97,6 +76,7 @@ func validateSOARecord(record Record, servers []string, ignoreSerialNumbers bool
 			FQDN:       record.FQDN,
 			RecordType: "SOA",
 			Message:    "Invalid SOA record format",
+			Category:   CategoryInvalid,
 		}
 		return []Discrepancy{discrepancy}, nil
 	}
@@ -110,31 +90,42 @@ func validateSOARecord(record Record, servers []string, ignoreSerialNumbers bool
 
 	var discrepancies []Discrepancy
 	var successfulValidations []ValidationRecord
+	serials := make(map[string]uint32)
+	failures := make(map[string]string)
+	mnames := make(map[string]bool)
 
 	for _, server := range servers {
 		level.Debug(logger).Log("msg", "Validating SOA record", "fqdn", record.FQDN, "server", server)
-		resp, err := queryDNSWithRetry(record.FQDN, dns.TypeSOA, server, 3)
+		opts.Throttle.acquire(server)
+		resp, err := queryDNSWithRetry(record.FQDN, dns.TypeSOA, server, opts.Query)
+		opts.Throttle.release(server)
 		if err != nil {
 			if resp != nil && resp.Rcode == dns.RcodeNameError {
 				// NXDOMAIN
 				level.Warn(logger).Log("msg", "NXDOMAIN received", "fqdn", record.FQDN, "server", server)
+				failures[server] = "NXDOMAIN"
 				discrepancy := Discrepancy{
-					FQDN:       record.FQDN,
-					RecordType: "SOA",
-					Expected:   *expectedSOA,
-					Server:     server,
-					Message:    "SOA record missing (NXDOMAIN)",
+					FQDN:          record.FQDN,
+					RecordType:    "SOA",
+					Expected:      *expectedSOA,
+					Server:        server,
+					Message:       "SOA record missing (NXDOMAIN)",
+					Category:      CategoryNXDOMAIN,
+					ExtendedError: extendedErrorText(resp),
 				}
 				discrepancies = append(discrepancies, discrepancy)
 			} else {
 				// Other errors
 				level.Warn(logger).Log("msg", "DNS query error", "fqdn", record.FQDN, "server", server, "err", err)
+				failures[server] = fmt.Sprintf("query error: %v", err)
 				discrepancy := Discrepancy{
-					FQDN:       record.FQDN,
-					RecordType: "SOA",
-					Expected:   *expectedSOA,
-					Server:     server,
-					Message:    fmt.Sprintf("DNS query error: %v", err),
+					FQDN:          record.FQDN,
+					RecordType:    "SOA",
+					Expected:      *expectedSOA,
+					Server:        server,
+					Message:       fmt.Sprintf("DNS query error: %v", err),
+					Category:      CategoryQueryError,
+					ExtendedError: extendedErrorText(resp),
 				}
 				discrepancies = append(discrepancies, discrepancy)
 			}
@@ -149,6 +140,7 @@ func validateSOARecord(record Record, servers []string, ignoreSerialNumbers bool
 				Expected:   *expectedSOA,
 				Server:     server,
 				Message:    "SOA record missing",
+				Category:   CategoryMissing,
 			}
 			discrepancies = append(discrepancies, discrepancy)
 			continue
@@ -167,9 +159,15 @@ func validateSOARecord(record Record, servers []string, ignoreSerialNumbers bool
 				}
 
 				actualTTL := int(ans.Header().Ttl)
+				serials[server] = rr.Serial
+				mnames[strings.ToLower(dns.Fqdn(rr.Ns))] = true
 
-				if !soaRecordsEqual(*expectedSOA, actualSOA, ignoreSerialNumbers) || expectedTTL != actualTTL {
+				if !soaRecordsEqual(*expectedSOA, actualSOA, opts.IgnoreSerialNumbers) || expectedTTL != actualTTL {
 					level.Warn(logger).Log("msg", "SOA record mismatch", "fqdn", record.FQDN, "server", server)
+					category := CategoryMismatch
+					if soaRecordsEqual(*expectedSOA, actualSOA, opts.IgnoreSerialNumbers) {
+						category = CategoryTTLDrift
+					}
 					discrepancy := Discrepancy{
 						FQDN:        record.FQDN,
 						RecordType:  "SOA",
@@ -178,11 +176,12 @@ func validateSOARecord(record Record, servers []string, ignoreSerialNumbers bool
 						ExpectedTTL: expectedTTL,
 						ActualTTL:   actualTTL,
 						Server:      server,
+						Category:    category,
 					}
 					discrepancies = append(discrepancies, discrepancy)
 				} else {
 					level.Info(logger).Log("msg", "SOA record validated successfully", "fqdn", record.FQDN, "server", server)
-					if recordSuccessful {
+					if opts.RecordSuccessful {
 						validationRecord := ValidationRecord{
 							FQDN:        record.FQDN,
 							RecordType:  "SOA",
@@ -201,9 +200,202 @@ func validateSOARecord(record Record, servers []string, ignoreSerialNumbers bool
 		}
 	}
 
+	opts.SerialTable.add(record, expectedSOA.Serial, servers, serials, failures)
+
+	// Servers disagreeing on the serial indicates replication lag
+	if d, ok := checkSerialConsistency(record, serials, opts.SerialLagTolerance, logger); ok {
+		discrepancies = append(discrepancies, d)
+	}
+
+	// The servers must keep up with the hidden primary they replicate from
+	if opts.HiddenPrimary != "" {
+		if d, ok := checkHiddenPrimary(record, serials, logger, opts); ok {
+			discrepancies = append(discrepancies, d)
+		}
+	}
+
+	// The primary the servers name must itself be reachable and authoritative
+	if opts.CheckSOAMName {
+		for mname := range mnames {
+			if d, ok := checkSOAMName(record, mname, logger, opts); ok {
+				discrepancies = append(discrepancies, d)
+			}
+		}
+	}
+
+	tagClientSubnet(discrepancies, opts.Query)
+	opts.FailFast.record(discrepancies)
 	return discrepancies, successfulValidations
 }
 
+// mnameLookupTimeout bounds the address lookup of a zone's SOA MName host.
+const mnameLookupTimeout = 5 * time.Second
+
+// checkSOAMName reports when the SOA MName host of a zone does not resolve, or
+// does not answer authoritatively for the zone.
+func checkSOAMName(record Record, mname string, logger log.Logger, opts ValidationOptions) (Discrepancy, bool) {
+	discrepancy := Discrepancy{
+		FQDN:       record.FQDN,
+		RecordType: "SOA",
+		ZoneName:   record.ZoneName,
+		Actual:     mname,
+		Category:   CategoryBrokenPrimary,
+	}
+
+	host := strings.TrimSuffix(mname, ".")
+	ctx, cancel := context.WithTimeout(context.Background(), mnameLookupTimeout)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+		level.Warn(logger).Log("msg", "SOA MName does not resolve", "fqdn", record.FQDN, "mname", mname, "err", err)
+		discrepancy.Message = fmt.Sprintf("SOA MName %s does not resolve: %v", mname, err)
+		return discrepancy, true
+	}
+
+	discrepancy.Server = host
+	level.Debug(logger).Log("msg", "Checking SOA MName is authoritative", "fqdn", record.FQDN, "mname", mname)
+	opts.Throttle.acquire(host)
+	resp, err := queryDNSWithRetry(dns.Fqdn(record.FQDN), dns.TypeSOA, host, opts.Query)
+	opts.Throttle.release(host)
+	if err != nil && resp == nil {
+		level.Warn(logger).Log("msg", "SOA MName is unreachable", "fqdn", record.FQDN, "mname", mname, "err", err)
+		discrepancy.Message = fmt.Sprintf("SOA MName %s is unreachable: %v", mname, err)
+		return discrepancy, true
+	}
+	if reason := lameReason(dns.Fqdn(record.FQDN), resp); reason != "" {
+		level.Warn(logger).Log("msg", "SOA MName is not authoritative", "fqdn", record.FQDN, "mname", mname, "reason", reason)
+		discrepancy.Message = fmt.Sprintf("SOA MName %s is not authoritative: %s", mname, reason)
+		return discrepancy, true
+	}
+
+	level.Debug(logger).Log("msg", "SOA MName is authoritative", "fqdn", record.FQDN, "mname", mname)
+	return Discrepancy{}, false
+}
+
+// checkHiddenPrimary queries the hidden primary for the zone's SOA and reports
+// when its serial leads any of the servers' serials by more than the tolerance.
+// The finding lists the serial of every server that trails.
+func checkHiddenPrimary(record Record, serials map[string]uint32, logger log.Logger, opts ValidationOptions) (Discrepancy, bool) {
+	primary := opts.HiddenPrimary
+	level.Debug(logger).Log("msg", "Querying hidden primary for SOA", "fqdn", record.FQDN, "primary", primary)
+	opts.Throttle.acquire(primary)
+	resp, err := queryDNSWithRetry(record.FQDN, dns.TypeSOA, primary, opts.Query)
+	opts.Throttle.release(primary)
+	if err != nil {
+		level.Warn(logger).Log("msg", "DNS query error", "fqdn", record.FQDN, "server", primary, "err", err)
+		return Discrepancy{
+			FQDN:          record.FQDN,
+			RecordType:    "SOA",
+			ZoneName:      record.ZoneName,
+			Server:        primary,
+			Message:       fmt.Sprintf("Hidden primary query error: %v", err),
+			Category:      CategoryQueryError,
+			ExtendedError: extendedErrorText(resp),
+		}, true
+	}
+
+	var primarySOA *dns.SOA
+	for _, ans := range resp.Answer {
+		if rr, ok := ans.(*dns.SOA); ok {
+			primarySOA = rr
+			break
+		}
+	}
+	if primarySOA == nil {
+		level.Warn(logger).Log("msg", "Hidden primary returned no SOA record", "fqdn", record.FQDN, "server", primary)
+		return Discrepancy{
+			FQDN:       record.FQDN,
+			RecordType: "SOA",
+			ZoneName:   record.ZoneName,
+			Server:     primary,
+			Message:    "SOA record missing on hidden primary",
+			Category:   CategoryMissing,
+		}, true
+	}
+
+	servers := make([]string, 0, len(serials))
+	for server := range serials {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+
+	var lagging []string
+	var maxLag int64
+	for _, server := range servers {
+		lag := serialDistance(serials[server], primarySOA.Serial)
+		if lag <= int64(opts.HiddenPrimaryTolerance) {
+			continue
+		}
+		lagging = append(lagging, fmt.Sprintf("%s=%d", server, serials[server]))
+		if lag > maxLag {
+			maxLag = lag
+		}
+	}
+	if len(lagging) == 0 {
+		level.Debug(logger).Log("msg", "Servers keep up with hidden primary", "fqdn", record.FQDN, "primary", primary, "serial", primarySOA.Serial)
+		return Discrepancy{}, false
+	}
+
+	level.Warn(logger).Log("msg", "Servers trail the hidden primary", "fqdn", record.FQDN, "primary", primary, "serial", primarySOA.Serial, "lag", maxLag, "lagging", strings.Join(lagging, ", "))
+	return Discrepancy{
+		FQDN:       record.FQDN,
+		RecordType: "SOA",
+		ZoneName:   record.ZoneName,
+		Expected:   []string{fmt.Sprintf("%s=%d", primary, primarySOA.Serial)},
+		Actual:     lagging,
+		Message:    fmt.Sprintf("Hidden primary %s serial %d leads servers by up to %d (tolerance %d)", primary, primarySOA.Serial, maxLag, opts.HiddenPrimaryTolerance),
+		Category:   CategoryReplicationLag,
+	}, true
+}
+
+// checkSerialConsistency reports when the SOA serials returned by the servers of
+// a zone are more than tolerance apart. The finding lists every server's serial.
+func checkSerialConsistency(record Record, serials map[string]uint32, tolerance int, logger log.Logger) (Discrepancy, bool) {
+	if len(serials) < 2 {
+		return Discrepancy{}, false
+	}
+
+	// Serials wrap around, so the lag is the largest distance between any two
+	var lag int64
+	for _, a := range serials {
+		for _, b := range serials {
+			if distance := serialDistance(a, b); distance > lag {
+				lag = distance
+			}
+		}
+	}
+	if lag <= int64(tolerance) {
+		return Discrepancy{}, false
+	}
+
+	servers := make([]string, 0, len(serials))
+	for server := range serials {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+
+	perServer := make([]string, 0, len(servers))
+	for _, server := range servers {
+		perServer = append(perServer, fmt.Sprintf("%s=%d", server, serials[server]))
+	}
+
+	level.Warn(logger).Log("msg", "SOA serials differ across servers", "fqdn", record.FQDN, "lag", lag, "serials", strings.Join(perServer, ", "))
+	return Discrepancy{
+		FQDN:       record.FQDN,
+		RecordType: "SOA",
+		ZoneName:   record.ZoneName,
+		Actual:     perServer,
+		Message:    fmt.Sprintf("SOA serials differ across servers by %d (tolerance %d)", lag, tolerance),
+		Category:   CategorySerialLag,
+	}, true
+}
+
+// serialDistance returns how far serial b is ahead of serial a in RFC 1982
+// serial number arithmetic, negative if b is behind. Serials wrap around at
+// 2^32, so 1 is 2 ahead of 4294967295.
+func serialDistance(a, b uint32) int64 {
+	return int64(int32(b - a))
+}
+
 func parseSOARecord(record Record) *SOARecord {
 	parts := strings.Fields(record.Value)
 	if len(parts) != 7 {
@@ -220,6 +412,11 @@ func parseSOARecord(record Record) *SOARecord {
 	}
 }
 
+// String renders the SOA record in zone-file field order.
+func (s SOARecord) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d", s.MName, s.RName, s.Serial, s.Refresh, s.Retry, s.Expire, s.Minimum)
+}
+
 func parseUint32(s string) uint32 {
 	var val uint32
 	fmt.Sscanf(s, "%d", &val)
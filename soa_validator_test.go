@@ -0,0 +1,74 @@
+// soa_validator_test.go
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func TestSerialDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint32
+		want int64
+	}{
+		{2024010100, 2024010105, 5},
+		{2024010105, 2024010100, -5},
+		{7, 7, 0},
+		// Across the wrap, 1 follows 4294967295
+		{4294967295, 1, 2},
+		{1, 4294967295, -2},
+		{4294967290, 10, 16},
+	}
+	for _, tt := range tests {
+		if got := serialDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("serialDistance(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSerialConsistency(t *testing.T) {
+	record := testRecord("@", "SOA", "", 3600)
+	tests := []struct {
+		name    string
+		serials map[string]uint32
+		want    bool
+	}{
+		{"in step", map[string]uint32{"ns1": 100, "ns2": 100}, false},
+		{"within tolerance", map[string]uint32{"ns1": 100, "ns2": 103}, false},
+		{"lagging", map[string]uint32{"ns1": 100, "ns2": 110, "ns3": 105}, true},
+		// One server has wrapped past 2^32 and is only a few serials ahead
+		{"wrapped, within tolerance", map[string]uint32{"ns1": 4294967294, "ns2": 1}, false},
+		{"wrapped, lagging", map[string]uint32{"ns1": 4294967290, "ns2": 20}, true},
+	}
+	for _, tt := range tests {
+		if _, got := checkSerialConsistency(record, tt.serials, 5, log.NewNopLogger()); got != tt.want {
+			t.Errorf("%s: reported lag %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSOARecordsMoreFindingsThanServers(t *testing.T) {
+	servers := []string{"127.0.57.1", "127.0.57.2", "127.0.57.3"}
+	for i, addr := range servers {
+		serveZone(t, addr, newTestZone(t, "example.com",
+			fmt.Sprintf("@ 3600 IN SOA ns1 hostmaster %d 7200 3600 1209600 3600", 100*(i+2)),
+		))
+	}
+
+	// Every server mismatches and they lag each other: more findings than servers
+	record := testRecord("@", "SOA", "ns1.example.com. hostmaster.example.com. 100 7200 3600 1209600 3600", 3600)
+	zoneViewToNameservers := map[string][]string{zoneViewKey("example.com", "default"): servers}
+	opts := ValidationOptions{Query: QueryOptions{Retries: 1}}
+
+	var discrepancies []Discrepancy
+	finishWithin(t, 10*time.Second, func() {
+		discrepancies, _ = validateSOARecords([]Record{record}, servers, log.NewNopLogger(), zoneViewToNameservers, opts)
+	})
+	got := categories(discrepancies)
+	if got[CategoryMismatch] != 3 || got[CategorySerialLag] != 1 {
+		t.Errorf("got findings %v, want 3 %s and 1 %s", got, CategoryMismatch, CategorySerialLag)
+	}
+}
@@ -0,0 +1,255 @@
+// stream.go
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// streamRecordOrdering makes NetBox return each zone's records contiguously. The
+// id tie-breaker keeps the order stable so offset pagination neither skips nor
+// repeats records.
+const streamRecordOrdering = "zone,id"
+
+// defaultMaxConcurrency is the number of streamed zones validated at once when
+// no limit is configured.
+const defaultMaxConcurrency = 4
+
+// validationPlan holds the run-wide inputs for validating NetBox records, so the
+// same checks can be applied to all records at once or to one streamed zone at
+// a time.
+type validationPlan struct {
+	servers               []string
+	logger                log.Logger
+	zoneViewToNameservers map[string][]string
+	zonesByName           map[string]Zone
+	zoneFilter            string
+	viewFilter            string
+	soaValidationMode     string
+	validateDS            bool
+	checkDisabledPTR      bool
+	checkLameDelegations  bool
+	checkDelegationTTL    bool
+	delegationTTL         int
+	checkCNAMETargets     bool
+	checkMXTargets        bool
+	checkReverseZones     bool
+	checkDisabledRecords  bool
+	resolvers             []string
+	quorum                int
+	compareTTLOnly        bool
+	minTTL                int
+	maxTTL                int
+	opts                  ValidationOptions
+}
+
+// validate checks records against the authoritative nameservers with individual queries.
+func (p validationPlan) validate(records []Record) ([]Discrepancy, []ValidationRecord) {
+	var discrepancies []Discrepancy
+	var successfulValidations []ValidationRecord
+
+	if p.soaValidationMode != "only" {
+		// DS records are validated at the parent when DS validation is enabled
+		recordsToValidate := records
+		if p.validateDS {
+			recordsToValidate = nil
+			for _, record := range records {
+				if strings.ToUpper(record.Type) != "DS" {
+					recordsToValidate = append(recordsToValidate, record)
+				}
+			}
+		}
+
+		// Validate all records except SOA
+		discrepancies, successfulValidations = validateAllRecords(recordsToValidate, p.servers, p.logger, p.zoneViewToNameservers, p.zoneFilter, p.viewFilter, p.zonesByName, p.opts)
+
+		// ALIAS records are validated by their expansion to A/AAAA
+		aliasDiscrepancies, aliasSuccessfulValidations := validateAliasRecords(recordsToValidate, p.servers, p.logger, p.zoneViewToNameservers, p.opts)
+		discrepancies = append(discrepancies, aliasDiscrepancies...)
+		successfulValidations = append(successfulValidations, aliasSuccessfulValidations...)
+	}
+
+	if p.soaValidationMode != "false" {
+		// Validate SOA records separately
+		soaDiscrepancies, soaSuccessfulValidations := validateSOARecords(records, p.servers, p.logger, p.zoneViewToNameservers, p.opts)
+		discrepancies = append(discrepancies, soaDiscrepancies...)
+		successfulValidations = append(successfulValidations, soaSuccessfulValidations...)
+	}
+
+	if p.checkDisabledPTR {
+		// Verify addresses with PTR disabled have no reverse record
+		ptrDiscrepancies, ptrSuccessfulValidations := validateDisabledPTRs(records, p.servers, p.logger, p.zoneViewToNameservers, p.opts)
+		discrepancies = append(discrepancies, ptrDiscrepancies...)
+		successfulValidations = append(successfulValidations, ptrSuccessfulValidations...)
+	}
+
+	if p.checkLameDelegations {
+		// Verify every listed nameserver answers authoritatively for its zones
+		lameDiscrepancies, lameSuccessfulValidations := validateDelegations(records, p.servers, p.logger, p.zoneViewToNameservers, p.opts)
+		discrepancies = append(discrepancies, lameDiscrepancies...)
+		successfulValidations = append(successfulValidations, lameSuccessfulValidations...)
+	}
+
+	if p.checkDelegationTTL {
+		// Verify the parent delegates each zone with the expected NS TTL
+		delegationDiscrepancies, delegationSuccessfulValidations := validateDelegationTTLs(records, p.logger, p.zoneViewToNameservers, p.delegationTTL, p.opts)
+		discrepancies = append(discrepancies, delegationDiscrepancies...)
+		successfulValidations = append(successfulValidations, delegationSuccessfulValidations...)
+	}
+
+	if p.checkCNAMETargets {
+		// Verify CNAME targets resolve
+		cnameDiscrepancies, cnameSuccessfulValidations := validateCNAMETargets(records, p.servers, p.logger, p.zoneViewToNameservers, p.opts)
+		discrepancies = append(discrepancies, cnameDiscrepancies...)
+		successfulValidations = append(successfulValidations, cnameSuccessfulValidations...)
+	}
+
+	if p.checkMXTargets {
+		// Verify MX exchanges have an address
+		mxDiscrepancies, mxSuccessfulValidations := validateMXTargets(records, p.servers, p.logger, p.zoneViewToNameservers, p.opts)
+		discrepancies = append(discrepancies, mxDiscrepancies...)
+		successfulValidations = append(successfulValidations, mxSuccessfulValidations...)
+	}
+
+	if p.checkReverseZones {
+		// Verify every address has a reverse zone to hold its PTR
+		reverseDiscrepancies, reverseSuccessfulValidations := validateReverseZones(records, p.servers, p.logger, p.zoneViewToNameservers, p.zonesByName, p.opts)
+		discrepancies = append(discrepancies, reverseDiscrepancies...)
+		successfulValidations = append(successfulValidations, reverseSuccessfulValidations...)
+	}
+
+	if p.validateDS {
+		// Validate DS records against the parent zone
+		dsDiscrepancies, dsSuccessfulValidations := validateDSRecords(records, p.servers, p.logger, p.zoneViewToNameservers, p.zoneFilter, p.viewFilter, p.opts)
+		discrepancies = append(discrepancies, dsDiscrepancies...)
+		successfulValidations = append(successfulValidations, dsSuccessfulValidations...)
+	}
+
+	return discrepancies, successfulValidations
+}
+
+// validateDisabled checks that the records NetBox marks as disabled are absent
+// from DNS. It must be given the disabled records prepareRecords dropped, so
+// the scope filters apply to them as to the published ones.
+func (p validationPlan) validateDisabled(records []Record) ([]Discrepancy, []ValidationRecord) {
+	if !p.checkDisabledRecords {
+		return nil, nil
+	}
+	return validateDisabledRecords(records, p.servers, p.logger, p.zoneViewToNameservers, p.opts)
+}
+
+// finish applies the checks that follow validation to the discrepancies found for records.
+func (p validationPlan) finish(records []Record, discrepancies []Discrepancy) []Discrepancy {
+	// Check propagation through recursive resolvers, requiring a quorum to disagree
+	if len(p.resolvers) > 0 {
+		level.Debug(p.logger).Log("msg", "Checking resolver consensus", "resolvers", strings.Join(p.resolvers, ", "), "quorum", p.quorum)
+		consensusDiscrepancies := validateRecordsConsensus(records, p.resolvers, p.quorum, p.logger, p.opts)
+		discrepancies = append(discrepancies, consensusDiscrepancies...)
+	}
+
+	// In TTL audit mode, keep only discrepancies where values match but TTLs differ
+	if p.compareTTLOnly {
+		discrepancies = filterTTLOnlyMismatches(discrepancies, p.opts.CaseSensitive)
+	}
+
+	// Check NetBox TTLs against the configured policy
+	if p.minTTL > 0 || p.maxTTL > 0 {
+		policyDiscrepancies := checkTTLPolicy(records, p.zonesByName, p.minTTL, p.maxTTL, p.opts.NSApexTTLFromSOA, p.logger)
+		discrepancies = append(discrepancies, policyDiscrepancies...)
+	}
+
+	return discrepancies
+}
+
+// streamRecordBatches fetches records from NetBox page by page and sends them on
+// batches one zone at a time, as soon as that zone is complete. Records are
+// requested in zone order, so a zone is complete once a record of another zone
+// arrives. batches is closed when fetching ends.
+func streamRecordBatches(baseURL, token string, headers http.Header, logger log.Logger, zoneFilter, viewFilter, tenantFilter string, zonesToValidate []string, batches chan<- []Record) error {
+	defer close(batches)
+
+	var pending []Record
+	pendingZone := 0
+	err := fetchDNSRecordPages(baseURL, token, headers, logger, zoneFilter, viewFilter, tenantFilter, zonesToValidate, streamRecordOrdering, func(records []Record) {
+		for _, record := range records {
+			zoneID := 0
+			if record.Zone != nil {
+				zoneID = record.Zone.ID
+			}
+			if len(pending) > 0 && zoneID != pendingZone {
+				batches <- pending
+				pending = nil
+			}
+			pending = append(pending, record)
+			pendingZone = zoneID
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		batches <- pending
+	}
+	return nil
+}
+
+// validateStream validates record batches as they arrive, running up to
+// maxConcurrency batches at once. prepare is applied to each batch before it is
+// validated, returning the records to validate and the disabled records to
+// check are absent. It returns the combined results and the number of records
+// validated.
+func validateStream(batches <-chan []Record, plan validationPlan, maxConcurrency int, prepare func([]Record) ([]Record, []Record)) ([]Discrepancy, []ValidationRecord, int) {
+	if maxConcurrency <= 0 {
+		maxConcurrency = defaultMaxConcurrency
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	slots := make(chan struct{}, maxConcurrency)
+
+	var allDiscrepancies []Discrepancy
+	var allSuccessful []ValidationRecord
+	recordCount := 0
+	batchCount := 0
+
+	for batch := range batches {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(batch []Record) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			zoneName := ""
+			if len(batch) > 0 {
+				zoneName = batch[0].ZoneName
+			}
+			span := plan.opts.Query.Telemetry.startSpan("validate.zone", "zone", zoneName)
+
+			batch, disabled := prepare(batch)
+			disabledDiscrepancies, disabledValidations := plan.validateDisabled(disabled)
+			discrepancies, successfulValidations := plan.validate(batch)
+			discrepancies = append(discrepancies, disabledDiscrepancies...)
+			successfulValidations = append(successfulValidations, disabledValidations...)
+			discrepancies = plan.finish(batch, discrepancies)
+			span.end("records", fmt.Sprint(len(batch)), "discrepancies", fmt.Sprint(len(discrepancies)))
+
+			mu.Lock()
+			defer mu.Unlock()
+			allDiscrepancies = append(allDiscrepancies, discrepancies...)
+			allSuccessful = append(allSuccessful, successfulValidations...)
+			recordCount += len(batch)
+			batchCount++
+
+			level.Info(plan.logger).Log("msg", "Validated zone", "zone", zoneName, "records", len(batch), "discrepancies", len(discrepancies), "zones_done", batchCount, "records_done", recordCount)
+		}(batch)
+	}
+
+	wg.Wait()
+	return allDiscrepancies, allSuccessful, recordCount
+}
@@ -0,0 +1,67 @@
+// summary.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ResultSummary is the rollup of a run's results written to --summary-file, for
+// dashboards that only need counts rather than the individual findings.
+type ResultSummary struct {
+	GeneratedAt     time.Time      `json:"GeneratedAt"`
+	DurationSeconds float64        `json:"DurationSeconds"`
+	Passed          bool           `json:"Passed"`
+	Records         int            `json:"Records"`
+	Servers         []string       `json:"Servers"`
+	Discrepancies   int            `json:"Discrepancies"`
+	Successful      int            `json:"Successful"`
+	ByCategory      map[string]int `json:"ByCategory"`
+	BySeverity      map[string]int `json:"BySeverity"`
+	ByZone          map[string]int `json:"ByZone"`
+	ByType          map[string]int `json:"ByType"`
+	// RetryBudget is how much of --max-total-retries was spent, if set.
+	RetryBudget *RetryBudgetUsage `json:"RetryBudget,omitempty"`
+	// Sample is the share of records validated with --sample-percent, if set.
+	Sample *SampleInfo `json:"Sample,omitempty"`
+	// RecordCounts is each transferred zone's NetBox and AXFR record counts,
+	// with --check-record-counts.
+	RecordCounts []ZoneRecordCount `json:"RecordCounts,omitempty"`
+}
+
+// newResultSummary counts the reported discrepancies by category, severity,
+// zone and record type. The run passed if nothing was reported.
+func newResultSummary(discrepancies []Discrepancy, successful, records int, servers []string, started time.Time) ResultSummary {
+	now := time.Now()
+	summary := ResultSummary{
+		GeneratedAt:     now.UTC(),
+		DurationSeconds: now.Sub(started).Seconds(),
+		Passed:          len(discrepancies) == 0,
+		Records:         records,
+		Servers:         append([]string{}, servers...),
+		Discrepancies:   len(discrepancies),
+		Successful:      successful,
+		ByCategory:      make(map[string]int),
+		BySeverity:      make(map[string]int),
+		ByZone:          make(map[string]int),
+		ByType:          make(map[string]int),
+	}
+	for _, d := range discrepancies {
+		summary.ByCategory[d.Category]++
+		summary.BySeverity[d.Severity]++
+		summary.ByZone[d.ZoneName]++
+		summary.ByType[strings.ToUpper(d.RecordType)]++
+	}
+	return summary
+}
+
+// writeSummaryFile writes summary to path in the versioned JSON report envelope.
+func writeSummaryFile(summary ResultSummary, path string) error {
+	file, err := createReportFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to create summary file: %v", err)
+	}
+	defer file.Close()
+	return writeJSONReport(file, summary)
+}
@@ -0,0 +1,46 @@
+// summary_test.go
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func TestSummaryFileRecordCounts(t *testing.T) {
+	counts := newRecordCounts(defaultRecordCountTolerance)
+	counts.check(ZoneRecordCount{Zone: "example.org", Server: "ns1", Expected: 10, Actual: 11}, log.NewNopLogger())
+	counts.check(ZoneRecordCount{Zone: "example.com", Server: "ns1", Expected: 10, Actual: 100}, log.NewNopLogger())
+
+	summary := newResultSummary(nil, 0, 20, []string{"ns1"}, time.Now())
+	summary.RecordCounts = counts.all()
+	path := filepath.Join(t.TempDir(), "summary.json")
+	if err := writeSummaryFile(summary, path); err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Results ResultSummary
+	}
+	if err := json.Unmarshal(body, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every zone's counts are written, not only the ones flagged
+	want := []ZoneRecordCount{
+		{Zone: "example.com", Server: "ns1", Expected: 10, Actual: 100},
+		{Zone: "example.org", Server: "ns1", Expected: 10, Actual: 11},
+	}
+	got := report.Results.RecordCounts
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got record counts %+v, want %+v", got, want)
+	}
+}
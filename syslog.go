@@ -0,0 +1,52 @@
+// syslog.go
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// defaultSyslogTag is the program name syslog messages are tagged with.
+const defaultSyslogTag = "netbox-dnsverify"
+
+// logFindings sends each discrepancy to findingLogger as one structured
+// message, leveled by its severity: critical findings as errors, warnings as
+// warnings and the rest as info.
+func logFindings(findingLogger log.Logger, discrepancies []Discrepancy) error {
+	for _, d := range discrepancies {
+		var leveled log.Logger
+		switch d.Severity {
+		case SeverityCritical:
+			leveled = level.Error(findingLogger)
+		case SeverityWarning:
+			leveled = level.Warn(findingLogger)
+		default:
+			leveled = level.Info(findingLogger)
+		}
+
+		expected, _ := reportValues(d.Expected)
+		actual, _ := reportValues(d.Actual)
+		err := leveled.Log(
+			"msg", "DNS discrepancy",
+			"fqdn", d.FQDN,
+			"type", d.RecordType,
+			"zone", d.ZoneName,
+			"server", d.Server,
+			"category", d.Category,
+			"severity", d.Severity,
+			"expected", fmt.Sprint(expected),
+			"actual", fmt.Sprint(actual),
+			"expected_ttl", d.ExpectedTTL,
+			"actual_ttl", d.ActualTTL,
+			"message", d.Message,
+			"description", d.Description,
+			"elapsed_ms", d.ElapsedMs,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
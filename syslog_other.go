@@ -0,0 +1,15 @@
+//go:build windows || plan9
+
+// syslog_other.go
+package main
+
+import (
+	"errors"
+
+	"github.com/go-kit/log"
+)
+
+// newSyslogLogger is not available without a syslog implementation.
+func newSyslogLogger(network, address string) (log.Logger, func() error, error) {
+	return nil, nil, errors.New("syslog is not supported on this platform")
+}
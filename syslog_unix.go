@@ -0,0 +1,24 @@
+//go:build !windows && !plan9
+
+// syslog_unix.go
+package main
+
+import (
+	"io"
+	gosyslog "log/syslog"
+
+	"github.com/go-kit/log"
+	kitsyslog "github.com/go-kit/log/syslog"
+)
+
+// newSyslogLogger returns a logger sending logfmt messages to syslog, and a
+// function closing the connection. An empty network and address use the local
+// syslog daemon; otherwise network is "udp", "tcp" or "unix".
+func newSyslogLogger(network, address string) (log.Logger, func() error, error) {
+	writer, err := gosyslog.Dial(network, address, gosyslog.LOG_INFO|gosyslog.LOG_DAEMON, defaultSyslogTag)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger := kitsyslog.NewSyslogLogger(writer, func(w io.Writer) log.Logger { return log.NewLogfmtLogger(w) })
+	return logger, writer.Close, nil
+}
@@ -0,0 +1,311 @@
+// telemetry.go
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// telemetryServiceName is reported as the OTLP resource's service.name.
+const telemetryServiceName = "netbox-dnsverify"
+
+// telemetryExportTimeout bounds each OTLP export request.
+const telemetryExportTimeout = 10 * time.Second
+
+// telemetry collects spans for the phases of a run and DNS query latency
+// metrics, and exports them to an OTLP/HTTP collector in the JSON encoding when
+// the run ends. A nil telemetry records nothing, so runs without an endpoint
+// pay no overhead.
+type telemetry struct {
+	endpoint string
+	traceID  string
+	rootID   string
+	start    time.Time
+
+	mu      sync.Mutex
+	spans   []otlpSpan
+	queries map[string]*latencyStats
+	counts  map[string]int64
+}
+
+// latencyStats accumulates the query latencies seen for one server.
+type latencyStats struct {
+	count int64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+// telemetrySpan is an in-progress span started with telemetry.startSpan.
+type telemetrySpan struct {
+	t     *telemetry
+	id    string
+	name  string
+	start time.Time
+	attrs []otlpAttribute
+}
+
+// newTelemetry returns a telemetry exporting to the OTLP/HTTP collector at
+// endpoint, e.g. "http://localhost:4318".
+func newTelemetry(endpoint string) *telemetry {
+	return &telemetry{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		traceID:  randomHex(16),
+		rootID:   randomHex(8),
+		start:    time.Now(),
+		queries:  make(map[string]*latencyStats),
+		counts:   make(map[string]int64),
+	}
+}
+
+// randomHex returns n random bytes rendered in hex, as OTLP/JSON expects IDs.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startSpan starts a span named name under the run's root span. attrs are
+// alternating keys and values.
+func (t *telemetry) startSpan(name string, attrs ...string) *telemetrySpan {
+	if t == nil {
+		return nil
+	}
+	return &telemetrySpan{
+		t:     t,
+		id:    randomHex(8),
+		name:  name,
+		start: time.Now(),
+		attrs: stringAttributes(attrs),
+	}
+}
+
+// end finishes the span, adding attrs (alternating keys and values).
+func (s *telemetrySpan) end(attrs ...string) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, stringAttributes(attrs)...)
+
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	s.t.spans = append(s.t.spans, otlpSpan{
+		TraceID:           s.t.traceID,
+		SpanID:            s.id,
+		ParentSpanID:      s.t.rootID,
+		Name:              s.name,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: unixNano(s.start),
+		EndTimeUnixNano:   unixNano(time.Now()),
+		Attributes:        s.attrs,
+	})
+}
+
+// recordQuery records the latency of one DNS query to server.
+func (t *telemetry) recordQuery(server string, latency time.Duration) {
+	if t == nil {
+		return
+	}
+	ms := float64(latency) / float64(time.Millisecond)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats, ok := t.queries[server]
+	if !ok {
+		stats = &latencyStats{min: ms, max: ms}
+		t.queries[server] = stats
+	}
+	stats.count++
+	stats.sum += ms
+	if ms < stats.min {
+		stats.min = ms
+	}
+	if ms > stats.max {
+		stats.max = ms
+	}
+}
+
+// count sets the run-wide counter name, e.g. the number of discrepancies found.
+func (t *telemetry) count(name string, value int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.counts[name] = int64(value)
+}
+
+// export ends the run's root span and sends all spans and metrics to the
+// collector. Export failures are logged but never fail the run.
+func (t *telemetry) export(logger log.Logger) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+
+	t.mu.Lock()
+	spans := append(t.spans, otlpSpan{
+		TraceID:           t.traceID,
+		SpanID:            t.rootID,
+		Name:              "run",
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: unixNano(t.start),
+		EndTimeUnixNano:   unixNano(now),
+	})
+	metrics := t.metrics(now)
+	t.mu.Unlock()
+
+	resource := otlpResource{Attributes: stringAttributes([]string{"service.name", telemetryServiceName})}
+	scope := otlpScope{Name: telemetryServiceName}
+
+	traces := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource":   resource,
+			"scopeSpans": []interface{}{map[string]interface{}{"scope": scope, "spans": spans}},
+		}},
+	}
+	if err := t.post("/v1/traces", traces); err != nil {
+		level.Warn(logger).Log("msg", "Failed to export OTLP traces", "endpoint", t.endpoint, "err", err)
+	}
+
+	metricsBody := map[string]interface{}{
+		"resourceMetrics": []interface{}{map[string]interface{}{
+			"resource":     resource,
+			"scopeMetrics": []interface{}{map[string]interface{}{"scope": scope, "metrics": metrics}},
+		}},
+	}
+	if err := t.post("/v1/metrics", metricsBody); err != nil {
+		level.Warn(logger).Log("msg", "Failed to export OTLP metrics", "endpoint", t.endpoint, "err", err)
+		return
+	}
+
+	level.Info(logger).Log("msg", "Exported OTLP telemetry", "endpoint", t.endpoint, "spans", len(spans), "metrics", len(metrics))
+}
+
+// metrics renders the query latencies and counters as OTLP metrics. The caller
+// must hold t.mu.
+func (t *telemetry) metrics(now time.Time) []interface{} {
+	start, end := unixNano(t.start), unixNano(now)
+
+	var latencyPoints []interface{}
+	for server, stats := range t.queries {
+		latencyPoints = append(latencyPoints, map[string]interface{}{
+			"attributes":        stringAttributes([]string{"server", server}),
+			"startTimeUnixNano": start,
+			"timeUnixNano":      end,
+			"count":             strconv.FormatInt(stats.count, 10),
+			"sum":               stats.sum,
+			"min":               stats.min,
+			"max":               stats.max,
+			"bucketCounts":      []string{strconv.FormatInt(stats.count, 10)},
+			"explicitBounds":    []float64{},
+		})
+	}
+
+	metrics := []interface{}{map[string]interface{}{
+		"name":        "dnsverify.dns.query.duration",
+		"description": "Latency of DNS queries, per server",
+		"unit":        "ms",
+		"histogram": map[string]interface{}{
+			"aggregationTemporality": otlpTemporalityCumulative,
+			"dataPoints":             latencyPoints,
+		},
+	}}
+
+	for name, value := range t.counts {
+		metrics = append(metrics, map[string]interface{}{
+			"name": name,
+			"unit": "1",
+			"gauge": map[string]interface{}{
+				"dataPoints": []interface{}{map[string]interface{}{
+					"timeUnixNano": end,
+					"asInt":        strconv.FormatInt(value, 10),
+				}},
+			},
+		})
+	}
+	return metrics
+}
+
+// post sends body as JSON to path on the collector.
+func (t *telemetry) post(path string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: telemetryExportTimeout}
+	resp, err := client.Post(t.endpoint+path, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector returned status code %d (%s)", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
+
+// OTLP/JSON enum values.
+const (
+	otlpSpanKindInternal      = 1
+	otlpTemporalityCumulative = 2
+)
+
+// otlpSpan is a span in the OTLP/JSON encoding.
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+}
+
+// otlpResource describes the process emitting telemetry.
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+// otlpScope names the instrumentation emitting telemetry.
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+// otlpAttribute is a string-valued OTLP attribute.
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+// stringAttributes turns alternating keys and values into OTLP attributes.
+func stringAttributes(keyvals []string) []otlpAttribute {
+	var attrs []otlpAttribute
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		attr := otlpAttribute{Key: keyvals[i]}
+		attr.Value.StringValue = keyvals[i+1]
+		attrs = append(attrs, attr)
+	}
+	return attrs
+}
+
+// unixNano renders t as OTLP/JSON's string-encoded nanoseconds since the epoch.
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
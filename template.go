@@ -0,0 +1,78 @@
+// template.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// TemplateReport is the data a --report-template is executed against.
+type TemplateReport struct {
+	Discrepancies []JSONFinding
+	Summary       RunSummary
+}
+
+// RunSummary describes the validation run behind a templated report.
+type RunSummary struct {
+	GeneratedAt   time.Time
+	Records       int
+	Servers       []string
+	Discrepancies int
+	Successful    int
+	// RecordCounts holds each transferred zone's NetBox and AXFR record counts.
+	RecordCounts []ZoneRecordCount
+	// AXFRZones holds each transferred zone's comparison counts by record type.
+	AXFRZones []AXFRZoneSummary
+}
+
+// reportTemplateFuncs are the helper functions available to report templates.
+var reportTemplateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// loadReportTemplate parses the Go text/template file at path.
+func loadReportTemplate(path string) (*template.Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report template: %v", err)
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Funcs(reportTemplateFuncs).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// generateTemplateReport renders the discrepancies and run summary through tmpl.
+// Unlike the built-in formats it is written even when there are no
+// discrepancies, so templates can report a clean run.
+func generateTemplateReport(discrepancies []Discrepancy, summary RunSummary, tmpl *template.Template, reportFile string, logger log.Logger) error {
+	file, err := createReportFile(reportFile)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %v", err)
+	}
+	defer file.Close()
+
+	findings := make([]JSONFinding, 0, len(discrepancies))
+	for _, d := range discrepancies {
+		findings = append(findings, newJSONFindingFromDiscrepancy(d))
+	}
+	summary.Discrepancies = len(findings)
+
+	level.Debug(logger).Log("msg", "Rendering report template", "template", tmpl.Name(), "discrepancies", len(findings))
+
+	return tmpl.Execute(file, TemplateReport{
+		Discrepancies: findings,
+		Summary:       summary,
+	})
+}
@@ -0,0 +1,140 @@
+// testserver_test.go
+package main
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// testZone is a zone served by serveZone: the records it answers queries with
+// and transfers on AXFR. A server whose transfers and answers disagree
+// transfers the records set in transfer instead, and queries for the types in
+// rcodes fail with the rcode given.
+type testZone struct {
+	origin   string
+	rrs      []dns.RR
+	transfer []dns.RR
+	rcodes   map[uint16]int
+}
+
+// newTestZone parses rrs, given in zone file syntax relative to origin, into a
+// testZone. The SOA record, if any, must come first for transfers.
+func newTestZone(t *testing.T, origin string, rrs ...string) *testZone {
+	t.Helper()
+	zone := &testZone{origin: dns.Fqdn(origin)}
+	for _, s := range rrs {
+		rr, err := dns.NewRR("$ORIGIN " + zone.origin + "\n" + s)
+		if err != nil {
+			t.Fatalf("invalid test record %q: %v", s, err)
+		}
+		zone.rrs = append(zone.rrs, rr)
+	}
+	return zone
+}
+
+// ServeDNS answers from the zone's records: NXDOMAIN for unknown names, NODATA
+// for known names without records of the type asked for, and the whole zone,
+// framed by its SOA, for AXFR.
+func (z *testZone) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	q := req.Question[0]
+	if q.Qtype == dns.TypeAXFR {
+		source := z.rrs
+		if z.transfer != nil {
+			source = z.transfer
+		}
+		var rrs []dns.RR
+		rrs = append(rrs, source...)
+		rrs = append(rrs, source[0])
+		ch := make(chan *dns.Envelope, 1)
+		ch <- &dns.Envelope{RR: rrs}
+		close(ch)
+		new(dns.Transfer).Out(w, req, ch)
+		w.Hijack()
+		return
+	}
+
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	if rcode, ok := z.rcodes[q.Qtype]; ok {
+		resp.Rcode = rcode
+		w.WriteMsg(resp)
+		return
+	}
+	resp.Authoritative = true
+	known := false
+	for _, rr := range z.rrs {
+		if !strings.EqualFold(rr.Header().Name, q.Name) {
+			continue
+		}
+		known = true
+		if rr.Header().Rrtype == q.Qtype {
+			resp.Answer = append(resp.Answer, rr)
+		}
+	}
+	if !known {
+		resp.Rcode = dns.RcodeNameError
+	}
+	w.WriteMsg(resp)
+}
+
+// serveZone serves zone, or any other handler, over UDP and TCP on port 53 of
+// the loopback address addr, which is where validators query a server named
+// addr. The test is skipped if the port can't be bound, e.g. without the
+// privileges to.
+func serveZone(t *testing.T, addr string, zone dns.Handler) {
+	t.Helper()
+	serveZoneTSIG(t, addr, zone, nil)
+}
+
+// serveZoneTSIG is serveZone for a server holding the TSIG secrets given by key
+// name, with which it verifies queries and signs the answers it is asked to.
+func serveZoneTSIG(t *testing.T, addr string, zone dns.Handler, secrets map[string]string) {
+	t.Helper()
+	address := net.JoinHostPort(addr, "53")
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		t.Skipf("cannot serve DNS on %s: %v", address, err)
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		conn.Close()
+		t.Skipf("cannot serve DNS on %s: %v", address, err)
+	}
+
+	var servers []*dns.Server
+	var started sync.WaitGroup
+	for _, server := range []*dns.Server{{PacketConn: conn, Handler: zone, TsigSecret: secrets}, {Listener: listener, Handler: zone, TsigSecret: secrets}} {
+		server := server
+		started.Add(1)
+		server.NotifyStartedFunc = started.Done
+		go server.ActivateAndServe()
+		servers = append(servers, server)
+	}
+	started.Wait()
+	t.Cleanup(func() {
+		for _, server := range servers {
+			server.Shutdown()
+		}
+	})
+}
+
+// finishWithin fails the test if validate doesn't return within timeout, which
+// is how a validator blocking forever shows up.
+func finishWithin(t *testing.T, timeout time.Duration, validate func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		validate()
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatalf("validation did not finish within %s", timeout)
+	}
+}
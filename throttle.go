@@ -0,0 +1,54 @@
+// throttle.go
+package main
+
+import (
+	"sync"
+)
+
+// serverThrottle caps the number of in-flight DNS queries to any single server
+// across the whole run, while still allowing different servers to be queried in
+// parallel.
+type serverThrottle struct {
+	limit int
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// newServerThrottle returns a throttle allowing up to limit concurrent queries
+// per server. A limit of zero or less disables throttling.
+func newServerThrottle(limit int) *serverThrottle {
+	return &serverThrottle{
+		limit: limit,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+// acquire blocks until a query slot for the given server is available.
+func (t *serverThrottle) acquire(server string) {
+	if t == nil || t.limit <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	slot, exists := t.slots[server]
+	if !exists {
+		slot = make(chan struct{}, t.limit)
+		t.slots[server] = slot
+	}
+	t.mu.Unlock()
+
+	slot <- struct{}{}
+}
+
+// release frees a query slot previously obtained with acquire.
+func (t *serverThrottle) release(server string) {
+	if t == nil || t.limit <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	slot := t.slots[server]
+	t.mu.Unlock()
+
+	<-slot
+}
@@ -0,0 +1,55 @@
+// token.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// stdinTokenFile is the --api-token-file value that reads the token from
+// standard input.
+const stdinTokenFile = "-"
+
+// readAPIToken reads the NetBox API token from path, or from the first line of
+// standard input when path is "-".
+func readAPIToken(path string) (string, error) {
+	if path != stdinTokenFile {
+		tokenBytes, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(tokenBytes)), nil
+	}
+
+	token, err := readLine(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token from standard input: %v", err)
+	}
+	return strings.TrimSpace(token), nil
+}
+
+// readLine reads r up to and including the first newline. It reads a byte at a
+// time rather than through a buffer, so whatever follows the line is left
+// unread for anything else consuming standard input.
+func readLine(r io.Reader) (string, error) {
+	var line strings.Builder
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				return line.String(), nil
+			}
+			line.WriteByte(b[0])
+		}
+		if errors.Is(err, io.EOF) {
+			return line.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// transport.go
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// autoTransportSizeLimit is the estimated answer size in bytes above which
+// queries go over TCP with --auto-transport. It is the EDNS buffer size many
+// servers and middleboxes cap UDP answers at to avoid fragmentation.
+const autoTransportSizeLimit = 1232
+
+// rrOverhead approximates the wire size of an answer record besides its owner
+// name and data: type, class, TTL and data length.
+const rrOverhead = 10
+
+// defaultTypeTransports are the record types queried over TCP from the start
+// with --auto-transport, because their answers are usually too large for UDP.
+var defaultTypeTransports = map[string]string{
+	"DNSKEY":     "tcp",
+	"CERT":       "tcp",
+	"OPENPGPKEY": "tcp",
+	"SMIMEA":     "tcp",
+}
+
+// transportSelector picks UDP or TCP per query: record types with a configured
+// transport always use it, and other queries switch to TCP when the answer
+// NetBox expects would likely be truncated over UDP.
+type transportSelector struct {
+	byType map[string]string
+}
+
+// newTransportSelector returns a transportSelector using the default per-type
+// transports, with overrides ("udp" or "tcp", keyed by record type) applied.
+func newTransportSelector(overrides map[string]string) (*transportSelector, error) {
+	byType := make(map[string]string)
+	for recordType, transport := range defaultTypeTransports {
+		byType[recordType] = transport
+	}
+	for recordType, transport := range overrides {
+		transport = strings.ToLower(transport)
+		if transport != "udp" && transport != "tcp" {
+			return nil, fmt.Errorf("invalid transport %q for %s (expected udp or tcp)", transport, recordType)
+		}
+		byType[strings.ToUpper(recordType)] = transport
+	}
+	return &transportSelector{byType: byType}, nil
+}
+
+// useTCP reports whether the query for the expected values of recordType at
+// fqdn should go over TCP. It is safe to call on a nil transportSelector, which
+// leaves the choice to the global transport.
+func (s *transportSelector) useTCP(fqdn, recordType string, expectedValues []string) bool {
+	if s == nil {
+		return false
+	}
+	if transport, ok := s.byType[recordType]; ok {
+		return transport == "tcp"
+	}
+
+	size := 0
+	for _, value := range expectedValues {
+		size += len(fqdn) + rrOverhead + len(value)
+	}
+	return size > autoTransportSizeLimit
+}
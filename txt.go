@@ -0,0 +1,94 @@
+// txt.go
+package main
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// normalizeTXT converts a TXT value as stored in NetBox to the text it carries, so
+// it can be compared with what a server returns. Quoted character-strings are
+// unescaped and concatenated (as SPF, DKIM and DMARC consumers do); an unquoted
+// value is taken as a single character-string.
+func normalizeTXT(value string) string {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, `"`) {
+		return unescapeCharacterString(value)
+	}
+
+	var b strings.Builder
+	inQuotes := false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && i+1 < len(value):
+			n := escapeLength(value[i:])
+			b.WriteString(unescapeCharacterString(value[i : i+n]))
+			i += n - 1
+		case c == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && (c == ' ' || c == '\t'):
+			// Whitespace between character-strings is not part of the text
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// normalizeTXTStrings converts the character-strings of a TXT record returned by
+// DNS, which are kept in escaped presentation form, to the text they carry.
+func normalizeTXTStrings(txt []string) string {
+	var b strings.Builder
+	for _, s := range txt {
+		b.WriteString(unescapeCharacterString(s))
+	}
+	return b.String()
+}
+
+// unescapeCharacterString resolves \X and \DDD escapes in a character-string.
+func unescapeCharacterString(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		if escapeLength(s[i:]) == 4 {
+			b.WriteByte((s[i+1]-'0')*100 + (s[i+2]-'0')*10 + (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i+1])
+		i++
+	}
+	return b.String()
+}
+
+// escapeLength returns the length of the escape sequence at the start of s, which
+// must begin with a backslash: 4 for \DDD and 2 for \X.
+func escapeLength(s string) int {
+	if len(s) >= 4 && isDigit(s[1]) && isDigit(s[2]) && isDigit(s[3]) {
+		return 4
+	}
+	return 2
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// comparableRRValue returns the value of rr in the form used for comparison with
+// NetBox. It matches extractRRValue except for TXT records, whose character-strings
+// are unescaped and concatenated.
+func comparableRRValue(rr dns.RR) string {
+	if txt, ok := rr.(*dns.TXT); ok {
+		return normalizeTXTStrings(txt.Txt)
+	}
+	return extractRRValue(rr)
+}
@@ -0,0 +1,58 @@
+// txt_test.go
+package main
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestNormalizeTXT(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"unquoted", "v=spf1 -all", "v=spf1 -all"},
+		{"quoted", `"v=spf1 -all"`, "v=spf1 -all"},
+		{"surrounding space", `  "hello world"  `, "hello world"},
+		{"escaped quote", `"say \"hi\""`, `say "hi"`},
+		{"escaped backslash", `"a\\b"`, `a\b`},
+		{"decimal escape", `"semi\059colon"`, "semi;colon"},
+		{"unquoted escape", `semi\;colon`, "semi;colon"},
+		{"multi-string", `"v=DKIM1; k=rsa; " "p=MIGf"`, "v=DKIM1; k=rsa; p=MIGf"},
+		{"multi-string without space", `"abc""def"`, "abcdef"},
+		{"semicolons in quotes", `"v=DMARC1; p=reject; rua=mailto:d@example.com"`, "v=DMARC1; p=reject; rua=mailto:d@example.com"},
+		{"escaped quote across strings", `"a\"" "\"b"`, `a""b`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeTXT(tt.value); got != tt.want {
+				t.Errorf("normalizeTXT(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComparableTXTMatchesNetBox(t *testing.T) {
+	tests := []struct {
+		rr     string
+		netbox string
+	}{
+		{`example.com. 300 IN TXT "v=spf1 include:_spf.example.com ~all"`, "v=spf1 include:_spf.example.com ~all"},
+		{`example.com. 300 IN TXT "say \"hi\""`, `"say \"hi\""`},
+		{`example.com. 300 IN TXT "v=DKIM1; k=rsa; " "p=MIGf"`, `"v=DKIM1; k=rsa; p=MIGf"`},
+		{`example.com. 300 IN TXT "semi;colon"`, `"semi\059colon"`},
+	}
+
+	for _, tt := range tests {
+		rr, err := dns.NewRR(tt.rr)
+		if err != nil {
+			t.Fatalf("invalid test record %q: %v", tt.rr, err)
+		}
+		if got, want := comparableRRValue(rr), normalizeTXT(tt.netbox); got != want {
+			t.Errorf("%s: server value %q, NetBox value %q", tt.rr, got, want)
+		}
+	}
+}
@@ -21,7 +21,13 @@ type Record struct {
 	DisablePTR     bool       `json:"disable_ptr"`
 	Managed        bool       `json:"managed"`
 	Status         string     `json:"status"`
+	Active         *bool      `json:"active"` // Not exposed by all plugin versions
 	Description    string     `json:"description"`
+	// RData is where some plugin versions return the record value; it is
+	// copied to Value when Value is empty.
+	RData string `json:"rdata"`
+	// Display is NetBox's rendering of the record, "name [TYPE] value".
+	Display string `json:"display"`
 	// Add other fields as needed
 }
 
@@ -43,19 +49,26 @@ type ZonesResponse struct {
 }
 
 type Zone struct {
-	ID            int     `json:"id"`
-	URL           string  `json:"url"`
-	Display       string  `json:"display"`
-	Name          string  `json:"name"`
-	View          *View   `json:"view"`
-	Status        string  `json:"status"`
-	Active        bool    `json:"active"`
-	RFC2317Prefix *string `json:"rfc2317_prefix"`
-	DefaultTTL    int     `json:"default_ttl"`
-	SoaTTL        int     `json:"soa_ttl"` // Added SoaTTL field
+	ID            int            `json:"id"`
+	URL           string         `json:"url"`
+	Display       string         `json:"display"`
+	Name          string         `json:"name"`
+	View          *View          `json:"view"`
+	Status        string         `json:"status"`
+	Active        bool           `json:"active"`
+	RFC2317Prefix *string        `json:"rfc2317_prefix"`
+	DefaultTTL    int            `json:"default_ttl"`
+	SoaTTL        int            `json:"soa_ttl"` // Added SoaTTL field
+	SoaMName      *NameserverRef `json:"soa_mname"`
 	// Add other fields as needed
 }
 
+// NameserverRef is the brief nameserver object NetBox nests in other objects.
+type NameserverRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
 type View struct {
 	ID          int    `json:"id"`
 	URL         string `json:"url"`
@@ -3,6 +3,8 @@ package main
 
 import (
 	"strings"
+
+	"github.com/miekg/dns"
 )
 
 func splitAndTrim(s string, delimiter ...string) []string {
@@ -20,3 +22,41 @@ func splitAndTrim(s string, delimiter ...string) []string {
 	}
 	return trimmed
 }
+
+// canonicalZoneName returns a zone name in the form used throughout the tool,
+// as NetBox usually stores it: without a trailing dot.
+func canonicalZoneName(name string) string {
+	return strings.TrimSuffix(strings.TrimSpace(name), ".")
+}
+
+// canonicalFQDN returns a record name in the form used throughout the tool and
+// returned by DNS: fully qualified, with a trailing dot.
+func canonicalFQDN(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+	return dns.Fqdn(name)
+}
+
+// inZone reports whether name is at or below zoneName, whether or not either
+// carries a trailing dot. Like DNS, the comparison ignores case.
+func inZone(name, zoneName string) bool {
+	return dns.IsSubDomain(dns.Fqdn(zoneName), dns.Fqdn(name))
+}
+
+// recordInZone reports whether record is one of zoneName's own records: at or
+// below its apex, and not in a more specific NetBox zone delegated from it.
+func recordInZone(record Record, zoneName string) bool {
+	if !inZone(record.FQDN, zoneName) {
+		return false
+	}
+	return record.ZoneName == "" || strings.EqualFold(canonicalZoneName(record.ZoneName), canonicalZoneName(zoneName))
+}
+
+// canonicalizeZones normalizes the names of zones as they are read from NetBox.
+func canonicalizeZones(zones []Zone) {
+	for i := range zones {
+		zones[i].Name = canonicalZoneName(zones[i].Name)
+	}
+}
@@ -0,0 +1,45 @@
+// utils_test.go
+package main
+
+import "testing"
+
+func TestCanonicalNames(t *testing.T) {
+	tests := []struct {
+		name, zone, fqdn string
+	}{
+		{"example.com", "example.com", "example.com."},
+		{"example.com.", "example.com", "example.com."},
+		{" example.com. ", "example.com", "example.com."},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if got := canonicalZoneName(tt.name); got != tt.zone {
+			t.Errorf("canonicalZoneName(%q) = %q, want %q", tt.name, got, tt.zone)
+		}
+		if got := canonicalFQDN(tt.name); got != tt.fqdn {
+			t.Errorf("canonicalFQDN(%q) = %q, want %q", tt.name, got, tt.fqdn)
+		}
+	}
+}
+
+func TestInZone(t *testing.T) {
+	tests := []struct {
+		name, zone string
+		want       bool
+	}{
+		{"www.example.com.", "example.com", true},
+		{"www.example.com", "example.com.", true},
+		{"www.example.com", "example.com", true},
+		{"www.example.com.", "example.com.", true},
+		{"example.com", "example.com.", true},
+		{"WWW.Example.COM.", "example.com", true},
+		{"www.badexample.com.", "example.com", false},
+		{"www.example.org.", "example.com.", false},
+		{"example.com.", "www.example.com", false},
+	}
+	for _, tt := range tests {
+		if got := inZone(tt.name, tt.zone); got != tt.want {
+			t.Errorf("inZone(%q, %q) = %v, want %v", tt.name, tt.zone, got, tt.want)
+		}
+	}
+}
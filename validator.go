@@ -3,8 +3,10 @@ package main
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -15,37 +17,22 @@ import (
 func validateAllRecords(
 	records []Record,
 	servers []string,
-	ignoreSerialNumbers bool,
 	logger log.Logger,
-	nameservers []Nameserver,
+	zoneViewToNameservers map[string][]string,
 	zoneFilter, viewFilter string,
-	recordSuccessful bool,
 	zonesByName map[string]Zone,
+	opts ValidationOptions,
 ) ([]Discrepancy, []ValidationRecord) {
 	var wg sync.WaitGroup
-	discrepanciesChan := make(chan Discrepancy, len(records)*len(servers))
-	successfulChan := make(chan ValidationRecord, len(records)*len(servers))
+	var findings findingSet
 
 	// Group records by FQDN and Record Type using RecordKey
 	expectedRecords := make(map[RecordKey][]Record)
 
-	// Create a mapping of (zone, view) to nameservers
-	zoneViewToNameservers := make(map[string][]string)
-	for _, ns := range nameservers {
-		for _, zone := range ns.Zones {
-			if zone.View != nil {
-				key := fmt.Sprintf("%s|%s", zone.Name, zone.View.Name)
-				zoneViewToNameservers[key] = append(zoneViewToNameservers[key], ns.Name)
-			} else {
-				level.Warn(logger).Log("msg", "Zone has no associated view", "zone", zone.Name)
-			}
-		}
-	}
-
 	// Populate expectedRecords map based on filters
 	for _, record := range records {
-		// Skip SOA records as they are handled separately
-		if strings.ToUpper(record.Type) == "SOA" {
+		// Skip SOA and ALIAS records as they are handled separately
+		if strings.ToUpper(record.Type) == "SOA" || isAliasType(record.Type) {
 			continue
 		}
 
@@ -65,6 +52,7 @@ func validateAllRecords(
 		}
 		expectedRecords[key] = append(expectedRecords[key], record)
 	}
+	wildcards := buildWildcardIndex(expectedRecords)
 
 	// Iterate over each group and validate
 	for key, records := range expectedRecords {
@@ -72,11 +60,18 @@ func validateAllRecords(
 		go func(key RecordKey, records []Record) {
 			defer wg.Done()
 
+			// A PTR outside the zones fetched from NetBox has no nameservers to ask
+			if key.RecordType == "PTR" {
+				if _, managed := zonesByName[zoneViewKey(key.ZoneName, key.ViewName)]; !managed {
+					findings.add([]Discrepancy{unmanagedReverseZone(key, records, logger)}, nil, nil)
+					return
+				}
+			}
+
 			// Determine authoritative nameservers for this record's zone and view
 			var recordServers []string
 			if key.ZoneName != "" && key.ViewName != "" {
-				zoneViewKey := fmt.Sprintf("%s|%s", key.ZoneName, key.ViewName)
-				recordServers = zoneViewToNameservers[zoneViewKey]
+				recordServers = zoneViewToNameservers[zoneViewKey(key.ZoneName, key.ViewName)]
 				if len(recordServers) == 0 {
 					// No nameservers found for this zone and view, skip validation
 					level.Warn(logger).Log("msg", "No nameservers found for zone in view, skipping validation", "zone", key.ZoneName, "view", key.ViewName)
@@ -93,39 +88,19 @@ func validateAllRecords(
 				key,
 				records,
 				recordServers,
-				ignoreSerialNumbers,
 				logger,
-				recordSuccessful,
 				zonesByName,
+				wildcards,
+				opts,
 			)
 
-			// Send discrepancies and successful validations to channels
-			for _, d := range discrepancies {
-				discrepanciesChan <- d
-			}
-			for _, v := range successfulValidations {
-				successfulChan <- v
-			}
+			findings.add(discrepancies, successfulValidations, nil)
 		}(key, records)
 	}
 
 	// Wait for all goroutines to finish
 	wg.Wait()
-	close(discrepanciesChan)
-	close(successfulChan)
-
-	// Collect all discrepancies and successful validations
-	var allDiscrepancies []Discrepancy
-	for d := range discrepanciesChan {
-		allDiscrepancies = append(allDiscrepancies, d)
-	}
-
-	var successfulValidations []ValidationRecord
-	for v := range successfulChan {
-		successfulValidations = append(successfulValidations, v)
-	}
-
-	return allDiscrepancies, successfulValidations
+	return findings.discrepancies, findings.successful
 }
 
 // validateRecordsForFQDN validates DNS records for a specific FQDN and RecordType against the authoritative nameservers.
@@ -133,173 +108,311 @@ func validateRecordsForFQDN(
 	key RecordKey,
 	records []Record,
 	servers []string,
-	ignoreSerialNumbers bool,
 	logger log.Logger,
-	recordSuccessful bool,
 	zonesByName map[string]Zone,
+	wildcards wildcardIndex,
+	opts ValidationOptions,
 ) ([]Discrepancy, []ValidationRecord) {
 	expectedValues := []string{}
 	expectedTTL := 0
+	ttlsDiffer := false
 
 	// Aggregate expected values and determine ExpectedTTL
 	for _, record := range records {
-		value := record.Value
-
-		// Handle unqualified CNAME targets by appending the zone name
-		if key.RecordType == "CNAME" && !strings.HasSuffix(value, ".") {
-			zoneName := strings.TrimRight(record.ZoneName, ".")
-			if zoneName != "" {
-				value = value + "." + zoneName + "."
-			} else {
-				value = value + "."
-			}
-		}
+		// Handle unqualified CNAME/MX/SRV targets by appending the zone name
+		value := normalizeExpectedValue(key.RecordType, record.Value, record.ZoneName)
 
 		expectedValues = append(expectedValues, value)
 
 		// Determine ExpectedTTL
-		var recordTTL int
-		if record.TTL != nil && *record.TTL > 0 {
-			recordTTL = *record.TTL
-		} else if key.RecordType == "NS" && record.Name == "@" {
-			// For NS records at the zone apex, use zone's own SOA TTL
-			if zone, ok := zonesByName[key.ZoneName]; ok {
-				if zone.SoaTTL > 0 {
-					recordTTL = zone.SoaTTL
-				} else {
-					recordTTL = record.ZoneDefaultTTL
-				}
-			} else {
-				// Zone not found, fallback to zone's default TTL
-				level.Warn(logger).Log("msg", "Zone not found for NS record", "zone", key.ZoneName)
-				recordTTL = record.ZoneDefaultTTL
-			}
-		} else {
-			// For other records, use zone's default TTL
-			recordTTL = record.ZoneDefaultTTL
-		}
+		recordTTL := resolveExpectedTTL(record, key.RecordType, zonesByName, opts.NSApexTTLFromSOA, logger)
 
 		if expectedTTL == 0 {
 			expectedTTL = recordTTL
 		} else if expectedTTL != recordTTL {
 			// Handle multiple TTLs within the same record group
 			level.Warn(logger).Log("msg", "Multiple TTLs for records with same FQDN and type", "fqdn", key.FQDN)
+			ttlsDiffer = true
 		}
 	}
 
+	var discrepancies []Discrepancy
+	var successfulValidations []ValidationRecord
+
+	// An RRset has a single TTL, so differing TTLs in NetBox make the zone ambiguous
+	if ttlsDiffer && opts.CheckRRsetTTLs {
+		discrepancies = append(discrepancies, inconsistentRRsetTTL(key, records, zonesByName, opts, logger))
+	}
+
 	// Convert RecordType to DNS query type
 	qtype, ok := dns.StringToType[key.RecordType]
 	if !ok {
-		level.Error(logger).Log("msg", "Unknown record type", "type", key.RecordType)
+		opts.severeFindingLogger(logger).Log("msg", "Unknown record type", "type", key.RecordType)
 		discrepancy := Discrepancy{
 			FQDN:       key.FQDN,
 			RecordType: key.RecordType,
 			ZoneName:   key.ZoneName,
 			Expected:   expectedValues,
 			Message:    "Unknown record type",
+			Category:   CategoryInvalid,
 		}
-		return []Discrepancy{discrepancy}, nil
+		return append(discrepancies, discrepancy), nil
 	}
 
-	var discrepancies []Discrepancy
-	var successfulValidations []ValidationRecord
+	// Large answers go over TCP from the start rather than after truncation
+	if !opts.Query.usesTCP() && opts.Transport.useTCP(key.FQDN, key.RecordType, expectedValues) {
+		level.Debug(logger).Log("msg", "Querying over TCP", "fqdn", key.FQDN, "type", key.RecordType)
+		opts.Query.TCP = true
+	}
 
 	// Query each authoritative nameserver
 	for _, server := range servers {
-		level.Debug(logger).Log(
-			"msg", "Validating records",
-			"fqdn", key.FQDN,
-			"type", key.RecordType,
-			"expected_values", expectedValues,
-			"server", server,
-		)
-		resp, err := queryDNSWithRetry(key.FQDN, qtype, server, 3)
-		if err != nil {
-			if resp != nil && resp.Rcode == dns.RcodeNameError {
-				// NXDOMAIN received, record is missing
-				level.Warn(logger).Log("msg", "NXDOMAIN received", "fqdn", key.FQDN, "server", server)
-				actualValues := []string{}
-				discrepancy := Discrepancy{
-					FQDN:        key.FQDN,
-					RecordType:  key.RecordType,
-					ZoneName:    key.ZoneName,
-					Expected:    expectedValues,
-					Actual:      actualValues,
-					ExpectedTTL: expectedTTL,
-					Server:      server,
-					Message:     "Record missing (NXDOMAIN)",
-				}
-				discrepancies = append(discrepancies, discrepancy)
-			} else {
-				// Other DNS query errors
-				level.Warn(logger).Log("msg", "DNS query error", "fqdn", key.FQDN, "server", server, "err", err)
-				discrepancy := Discrepancy{
-					FQDN:       key.FQDN,
-					RecordType: key.RecordType,
-					ZoneName:   key.ZoneName,
-					Expected:   expectedValues,
-					Server:     server,
-					Message:    fmt.Sprintf("DNS query error: %v", err),
+		start := time.Now()
+		serverDiscrepancies, serverValidations, resp := validateRecordsOnServer(key, qtype, server, expectedValues, expectedTTL, logger, opts)
+		truncated := resp != nil && resp.Truncated
+
+		// Discrepancies seen over UDP only count if they persist over TCP
+		if len(serverDiscrepancies) > 0 && opts.ConfirmOverTCP && !opts.Query.usesTCP() {
+			tcpOpts := opts
+			tcpOpts.Query.TCP = true
+			level.Debug(logger).Log("msg", "Confirming discrepancy over TCP", "fqdn", key.FQDN, "type", key.RecordType, "server", server)
+			tcpDiscrepancies, tcpValidations, tcpResp := validateRecordsOnServer(key, qtype, server, expectedValues, expectedTTL, logger, tcpOpts)
+			if len(tcpDiscrepancies) == 0 {
+				if truncated {
+					level.Info(logger).Log("msg", "UDP discrepancy was a truncation artifact, not confirmed over TCP", "fqdn", key.FQDN, "type", key.RecordType, "server", server)
+				} else {
+					level.Info(logger).Log("msg", "UDP discrepancy not confirmed over TCP", "fqdn", key.FQDN, "type", key.RecordType, "server", server)
 				}
-				discrepancies = append(discrepancies, discrepancy)
 			}
-			continue
+			serverDiscrepancies, serverValidations, resp = tcpDiscrepancies, tcpValidations, tcpResp
 		}
 
-		if len(resp.Answer) == 0 {
-			// No answer section in DNS response
-			level.Warn(logger).Log("msg", "No DNS answer", "fqdn", key.FQDN, "server", server)
+		// An answer from a covering wildcard means the explicit name is unknown to the server
+		wildcards.markShadowed(key, serverDiscrepancies, opts, logger)
+
+		// Keep the server's own explanation of failures
+		if ede := extendedErrorText(resp); ede != "" {
+			for i := range serverDiscrepancies {
+				serverDiscrepancies[i].ExtendedError = ede
+			}
+		}
+
+		elapsed := time.Since(start)
+		for i := range serverDiscrepancies {
+			serverDiscrepancies[i].ElapsedMs = elapsed.Milliseconds()
+		}
+		for i := range serverValidations {
+			serverValidations[i].ElapsedMs = elapsed.Milliseconds()
+		}
+
+		opts.Comparisons.recordServerResult(key, qtype, server, expectedValues, expectedTTL, resp, serverDiscrepancies, elapsed)
+		discrepancies = append(discrepancies, serverDiscrepancies...)
+		successfulValidations = append(successfulValidations, serverValidations...)
+	}
+
+	tagClientSubnet(discrepancies, opts.Query)
+	describeDiscrepancies(discrepancies, recordDescriptions(records))
+	opts.FailFast.record(discrepancies)
+	return discrepancies, successfulValidations
+}
+
+// inconsistentRRsetTTL reports the records of an RRset that NetBox stores with
+// different TTLs, listing each value with its TTL.
+func inconsistentRRsetTTL(key RecordKey, records []Record, zonesByName map[string]Zone, opts ValidationOptions, logger log.Logger) Discrepancy {
+	var values []string
+	ttls := make(map[int]bool)
+	for _, record := range records {
+		ttl := resolveExpectedTTL(record, key.RecordType, zonesByName, opts.NSApexTTLFromSOA, logger)
+		ttls[ttl] = true
+		values = append(values, fmt.Sprintf("%s (TTL %d)", normalizeExpectedValue(key.RecordType, record.Value, record.ZoneName), ttl))
+	}
+	sort.Strings(values)
+
+	level.Warn(logger).Log("msg", "Inconsistent RRset TTL in NetBox", "fqdn", key.FQDN, "type", key.RecordType, "ttls", len(ttls))
+	return Discrepancy{
+		FQDN:       key.FQDN,
+		RecordType: key.RecordType,
+		ZoneName:   key.ZoneName,
+		Expected:   values,
+		Message:    fmt.Sprintf("Inconsistent RRset TTL in NetBox: %d different TTLs for one %s RRset", len(ttls), key.RecordType),
+		Category:   CategoryInconsistentTTL,
+	}
+}
+
+// validateRecordsOnServer queries one server for key and compares the answer
+// with the expected values and TTL. It also returns the server's response, which
+// is nil if the query failed without one.
+func validateRecordsOnServer(
+	key RecordKey,
+	qtype uint16,
+	server string,
+	expectedValues []string,
+	expectedTTL int,
+	logger log.Logger,
+	opts ValidationOptions,
+) ([]Discrepancy, []ValidationRecord, *dns.Msg) {
+	var discrepancies []Discrepancy
+	var successfulValidations []ValidationRecord
+
+	level.Debug(logger).Log(
+		"msg", "Validating records",
+		"fqdn", key.FQDN,
+		"type", key.RecordType,
+		"expected_values", expectedValues,
+		"server", server,
+	)
+	opts.Throttle.acquire(server)
+	resp, err := queryDNSWithRetry(key.FQDN, qtype, server, opts.Query)
+	opts.Throttle.release(server)
+	if err != nil || resp.Rcode != dns.RcodeSuccess {
+		if resp != nil && resp.Rcode == dns.RcodeNameError {
+			// NXDOMAIN received, record is missing
+			level.Warn(logger).Log("msg", "NXDOMAIN received", "fqdn", key.FQDN, "server", server)
+			actualValues := []string{}
 			discrepancy := Discrepancy{
 				FQDN:        key.FQDN,
 				RecordType:  key.RecordType,
 				ZoneName:    key.ZoneName,
 				Expected:    expectedValues,
-				Actual:      []string{},
+				Actual:      actualValues,
 				ExpectedTTL: expectedTTL,
 				Server:      server,
-				Message:     "Record missing",
+				Message:     "Name does not exist (NXDOMAIN)",
+				Category:    CategoryNXDOMAIN,
 			}
 			discrepancies = append(discrepancies, discrepancy)
+		} else {
+			// Other DNS query errors, and servers answering with an error rcode
+			err = lookupFailure(resp, err)
+			level.Warn(logger).Log("msg", "DNS query error", "fqdn", key.FQDN, "server", server, "err", err)
+			discrepancy := Discrepancy{
+				FQDN:       key.FQDN,
+				RecordType: key.RecordType,
+				ZoneName:   key.ZoneName,
+				Expected:   expectedValues,
+				Server:     server,
+				Message:    fmt.Sprintf("DNS query error: %v", err),
+				Category:   CategoryQueryError,
+			}
+			discrepancies = append(discrepancies, discrepancy)
+		}
+		return discrepancies, successfulValidations, resp
+	}
+
+	if len(resp.Answer) == 0 {
+		// The name exists but has no records of this type (NOERROR NODATA)
+		level.Warn(logger).Log("msg", "No DNS answer", "fqdn", key.FQDN, "server", server)
+		message := fmt.Sprintf("Name exists but has no %s record (NODATA)", key.RecordType)
+		category := CategoryNoData
+		if qtype == dns.TypeNS && isReferral(resp, key.FQDN) {
+			// Delegation NS records and glue aren't the authoritative RRset
+			level.Debug(logger).Log("msg", "Server returned a referral instead of the authoritative NS RRset", "fqdn", key.FQDN, "server", server)
+			message = "Record missing (server returned only a referral, not the authoritative NS RRset)"
+			category = CategoryMissing
+		}
+		discrepancy := Discrepancy{
+			FQDN:        key.FQDN,
+			RecordType:  key.RecordType,
+			ZoneName:    key.ZoneName,
+			Expected:    expectedValues,
+			Actual:      []string{},
+			ExpectedTTL: expectedTTL,
+			Server:      server,
+			Message:     message,
+			Category:    category,
+		}
+		discrepancies = append(discrepancies, discrepancy)
+		return discrepancies, successfulValidations, resp
+	}
+
+	// A CNAME at the name itself means the record was replaced by an alias
+	if target, ok := unexpectedCNAME(key, resp); ok {
+		level.Warn(logger).Log("msg", "Unexpected CNAME", "fqdn", key.FQDN, "type", key.RecordType, "target", target, "server", server)
+		discrepancy := Discrepancy{
+			FQDN:        key.FQDN,
+			RecordType:  key.RecordType,
+			ZoneName:    key.ZoneName,
+			Expected:    expectedValues,
+			Actual:      []string{target},
+			ExpectedTTL: expectedTTL,
+			Server:      server,
+			Message:     fmt.Sprintf("Unexpected CNAME to %s where %s record expected", target, key.RecordType),
+			Category:    CategoryUnexpectedCNAME,
+		}
+		discrepancies = append(discrepancies, discrepancy)
+		return discrepancies, successfulValidations, resp
+	}
+
+	actualValues := []string{}
+	actualTTL := 0
+	for _, ans := range resp.Answer {
+		ttl := ans.Header().Ttl
+
+		// Only the NS RRset owned by the name itself is compared with NetBox
+		if qtype == dns.TypeNS && !ownsRRset(ans, key.FQDN, qtype) {
 			continue
 		}
 
-		actualValues := []string{}
-		actualTTL := 0
-		for _, ans := range resp.Answer {
-			var val string
-			var ttl uint32
-			ttl = ans.Header().Ttl
-
-			switch rr := ans.(type) {
-			case *dns.A:
-				val = rr.A.String()
-			case *dns.AAAA:
-				val = rr.AAAA.String()
-			case *dns.CNAME:
-				val = rr.Target
-			case *dns.NS:
-				val = rr.Ns
-			case *dns.PTR:
-				val = rr.Ptr
-			default:
-				// Handle other record types if necessary
-				continue
-			}
-			actualValues = append(actualValues, val)
+		val := comparableRRValue(ans)
+		if val == "" {
+			// Handle other record types if necessary
+			continue
+		}
+		actualValues = append(actualValues, val)
 
-			if actualTTL == 0 {
-				actualTTL = int(ttl)
-			} else if actualTTL != int(ttl) {
-				// Multiple TTLs found in DNS response
-				level.Warn(logger).Log("msg", "Multiple TTLs in DNS response", "fqdn", key.FQDN)
-			}
+		if actualTTL == 0 {
+			actualTTL = int(ttl)
+		} else if actualTTL != int(ttl) {
+			// Multiple TTLs found in DNS response
+			level.Warn(logger).Log("msg", "Multiple TTLs in DNS response", "fqdn", key.FQDN)
 		}
+	}
 
-		// Compare expected and actual values (unordered) and TTL
-		ttlMismatch := expectedTTL != actualTTL
-		if !stringSlicesEqualUnordered(expectedValues, actualValues) || ttlMismatch {
-			level.Warn(logger).Log("msg", "Record values or TTL mismatch", "fqdn", key.FQDN, "server", server)
-			discrepancy := Discrepancy{
+	// Flag values that must never be served, whatever NetBox expects
+	discrepancies = append(discrepancies, opts.Denylist.forbiddenValues(key.FQDN, key.RecordType, key.ZoneName, server, actualValues, opts.severeFindingLogger(logger))...)
+
+	// Answer policies may pin the number of records, whatever their values
+	if d, ok := opts.answerCountMismatch(key, server, expectedValues, actualValues, logger); ok {
+		discrepancies = append(discrepancies, d)
+	}
+
+	// Compare expected and actual values (unordered) and TTL
+	ttlMismatch := !ttlWithinTolerance(expectedTTL, actualTTL, opts.TTLTolerance)
+	valuesMatch, mode := opts.answerMatches(key, expectedValues, actualValues)
+	if !valuesMatch || ttlMismatch {
+		level.Warn(logger).Log("msg", "Record values or TTL mismatch", "fqdn", key.FQDN, "server", server)
+		category := CategoryMismatch
+		if valuesMatch {
+			category = CategoryTTLDrift
+		}
+		discrepancy := Discrepancy{
+			FQDN:        key.FQDN,
+			RecordType:  key.RecordType,
+			ZoneName:    key.ZoneName,
+			Expected:    expectedValues,
+			Actual:      actualValues,
+			ExpectedTTL: expectedTTL,
+			ActualTTL:   actualTTL,
+			Server:      server,
+			Category:    category,
+		}
+		if !valuesMatch && mode != AnswerExact {
+			discrepancy.Message = fmt.Sprintf("Answer does not satisfy the %s answer policy", mode)
+		}
+		if !valuesMatch && opts.DMARCAware && key.RecordType == "TXT" {
+			discrepancy.Message = dmarcDifference(expectedValues, actualValues)
+		}
+		if !valuesMatch && mode == AnswerExact {
+			if difference := fieldDifference(key.RecordType, expectedValues, actualValues); difference != "" {
+				discrepancy.Message = difference
+			}
+		}
+		discrepancies = append(discrepancies, discrepancy)
+	} else if expectedTTL != actualTTL {
+		// Passed only thanks to the TTL tolerance: the TTL is drifting
+		level.Info(logger).Log("msg", "Records validated within TTL tolerance", "fqdn", key.FQDN, "type", key.RecordType, "server", server, "expected_ttl", expectedTTL, "actual_ttl", actualTTL)
+		if opts.RecordSuccessful || opts.RecordNearMisses {
+			validationRecord := ValidationRecord{
 				FQDN:        key.FQDN,
 				RecordType:  key.RecordType,
 				ZoneName:    key.ZoneName,
@@ -308,46 +421,97 @@ func validateRecordsForFQDN(
 				ExpectedTTL: expectedTTL,
 				ActualTTL:   actualTTL,
 				Server:      server,
+				Message:     "Record validated within TTL tolerance (near-miss)",
+				NearMiss:    true,
 			}
-			discrepancies = append(discrepancies, discrepancy)
-		} else {
-			level.Info(logger).Log("msg", "Records validated successfully", "fqdn", key.FQDN, "type", key.RecordType, "server", server)
-			if recordSuccessful {
-				validationRecord := ValidationRecord{
-					FQDN:        key.FQDN,
-					RecordType:  key.RecordType,
-					ZoneName:    key.ZoneName,
-					Expected:    expectedValues,
-					Actual:      actualValues,
-					ExpectedTTL: expectedTTL,
-					ActualTTL:   actualTTL,
-					Server:      server,
-					Message:     "Record validated successfully",
-				}
-				successfulValidations = append(successfulValidations, validationRecord)
+			successfulValidations = append(successfulValidations, validationRecord)
+		}
+	} else {
+		level.Info(logger).Log("msg", "Records validated successfully", "fqdn", key.FQDN, "type", key.RecordType, "server", server)
+		if opts.RecordSuccessful {
+			validationRecord := ValidationRecord{
+				FQDN:        key.FQDN,
+				RecordType:  key.RecordType,
+				ZoneName:    key.ZoneName,
+				Expected:    expectedValues,
+				Actual:      actualValues,
+				ExpectedTTL: expectedTTL,
+				ActualTTL:   actualTTL,
+				Server:      server,
+				Message:     "Record validated successfully",
 			}
+			successfulValidations = append(successfulValidations, validationRecord)
 		}
 	}
 
-	return discrepancies, successfulValidations
+	return discrepancies, successfulValidations, resp
+}
+
+// resolveExpectedTTL determines the TTL a record is expected to be served with:
+// its own TTL if set, otherwise the zone's SOA TTL for apex NS records (when
+// nsApexTTLFromSOA is set) and the zone's default TTL for everything else.
+func resolveExpectedTTL(record Record, recordType string, zonesByName map[string]Zone, nsApexTTLFromSOA bool, logger log.Logger) int {
+	if record.TTL != nil && *record.TTL > 0 {
+		return *record.TTL
+	}
+
+	if nsApexTTLFromSOA && recordType == "NS" && record.Name == "@" {
+		// For NS records at the zone apex, use zone's own SOA TTL
+		if zone, ok := zonesByName[zoneViewKey(record.ZoneName, record.ViewName)]; ok {
+			if zone.SoaTTL > 0 {
+				return zone.SoaTTL
+			}
+			return record.ZoneDefaultTTL
+		}
+		// Zone not found, fallback to zone's default TTL
+		level.Warn(logger).Log("msg", "Zone not found for NS record", "zone", record.ZoneName)
+		return record.ZoneDefaultTTL
+	}
+
+	// For other records, use zone's default TTL
+	return record.ZoneDefaultTTL
+}
+
+// axfrServers returns the nameservers zoneName is validated against in any
+// view passing viewFilter, after exclusions and the primary or authority
+// restrictions, ordered by view and then as NetBox lists them. Zone transfers
+// use the first.
+func axfrServers(zoneName, viewFilter string, zoneViewToNameservers map[string][]string) []string {
+	var views []string
+	for key := range zoneViewToNameservers {
+		name, view, _ := strings.Cut(key, "|")
+		if name == zoneName && (viewFilter == "" || view == viewFilter) {
+			views = append(views, view)
+		}
+	}
+	sort.Strings(views)
+
+	var servers []string
+	seen := make(map[string]bool)
+	for _, view := range views {
+		for _, server := range zoneViewToNameservers[zoneViewKey(zoneName, view)] {
+			if !seen[server] {
+				seen[server] = true
+				servers = append(servers, server)
+			}
+		}
+	}
+	return servers
 }
 
 // validateAllRecordsAXFR performs validation using AXFR zone transfers.
 func validateAllRecordsAXFR(
 	records []Record,
 	servers []string,
-	ignoreSerialNumbers bool,
 	logger log.Logger,
-	nameservers []Nameserver,
+	zoneViewToNameservers map[string][]string,
 	zoneFilter, viewFilter string,
-	recordSuccessful bool,
 	zonesByName map[string]Zone,
 	tsigKeyFile string,
+	opts ValidationOptions,
 ) ([]Discrepancy, []ValidationRecord, []MissingRecord) {
 	var wg sync.WaitGroup
-	discrepanciesChan := make(chan Discrepancy, len(records)*len(servers))
-	successfulChan := make(chan ValidationRecord, len(records)*len(servers))
-	missingChan := make(chan MissingRecord, len(records)*len(servers))
+	var findings findingSet
 
 	// Parse TSIG keyfile if provided
 	var tsigKey *TSIGKey
@@ -362,33 +526,42 @@ func validateAllRecordsAXFR(
 
 	// Build a map of expected records
 	expectedRecordsMap := make(map[string]Record)
+	zoneRecordCounts := make(map[string]int)
 	for _, record := range records {
+		// ALIAS records are expanded at serve time and never transferred
+		if isAliasType(record.Type) {
+			continue
+		}
 		fqdnType := fmt.Sprintf("%s|%s", record.FQDN, strings.ToUpper(record.Type))
 		expectedRecordsMap[fqdnType] = record
+		zoneRecordCounts[record.ZoneName]++
 	}
 
 	// Iterate over each zone and perform AXFR
-	for zoneName, zone := range zonesByName {
-		// Apply zone filter
+	transferred := make(map[string]bool)
+	for _, zone := range zonesByName {
+		zoneName := zone.Name
+
+		// Apply zone and view filters
 		if zoneFilter != "" && zoneName != zoneFilter {
 			continue
 		}
+		if viewFilter != "" && zoneViewName(zone) != viewFilter {
+			continue
+		}
+
+		// Records are compared by name and type, so a zone in several views is transferred once
+		if transferred[zoneName] {
+			continue
+		}
+		transferred[zoneName] = true
 
 		wg.Add(1)
 		go func(zoneName string, zone Zone) {
 			defer wg.Done()
 
 			// Determine authoritative nameservers for this zone
-			var recordServers []string
-			for _, ns := range nameservers {
-				for _, nsZone := range ns.Zones {
-					if nsZone.Name == zoneName {
-						recordServers = append(recordServers, ns.Name)
-						break
-					}
-				}
-			}
-
+			recordServers := axfrServers(zoneName, viewFilter, zoneViewToNameservers)
 			if len(recordServers) == 0 {
 				level.Warn(logger).Log("msg", "No nameservers found for zone", "zone", zoneName)
 				return
@@ -398,129 +571,248 @@ func validateAllRecordsAXFR(
 			server := recordServers[0]
 			level.Info(logger).Log("msg", "Performing AXFR", "zone", zoneName, "server", server)
 
+			span := opts.Query.Telemetry.startSpan("axfr", "zone", zoneName, "server", server)
 			axfrRecords, err := performAXFR(zoneName, server, tsigKey, logger)
+			span.end("records", fmt.Sprint(len(axfrRecords)))
 			if err != nil {
 				level.Error(logger).Log("msg", "AXFR failed", "zone", zoneName, "server", server, "err", err)
 				return
 			}
 
-			// Build actual records map
-			actualRecordsMap := make(map[string]dns.RR)
-			for _, rr := range axfrRecords {
-				fqdnType := fmt.Sprintf("%s|%s", rr.Header().Name, dns.TypeToString[rr.Header().Rrtype])
-				actualRecordsMap[fqdnType] = rr
+			// Compare the transferred zone with NetBox
+			discrepancies, successfulValidations, missingRecords := compareZoneRecords(zoneName, server, axfrRecords, expectedRecordsMap, false, logger, opts)
+			// Only whole zones can be counted, not the records matching an FQDN filter
+			if opts.FQDNFilter == nil {
+				count := ZoneRecordCount{Zone: zoneName, Server: server, Expected: zoneRecordCounts[zoneName], Actual: countTransferredRecords(axfrRecords)}
+				if d, ok := opts.RecordCounts.check(count, logger); ok {
+					discrepancies = append(discrepancies, d)
+				}
+			}
+			if opts.CheckNSEC {
+				discrepancies = append(discrepancies, checkNSECChain(zoneName, server, axfrRecords, logger)...)
+			}
+			if opts.CrossCheckSample > 0 {
+				discrepancies = append(discrepancies, crossCheckTransfer(zoneName, server, axfrRecords, opts.CrossCheckSample, logger, opts)...)
 			}
+			opts.AXFRSummaries.add(zoneName, server, expectedRecordsMap, discrepancies, missingRecords, logger)
+			opts.FailFast.record(discrepancies)
+			findings.add(discrepancies, successfulValidations, missingRecords)
+		}(zoneName, zone)
+	}
 
-			// Compare expected and actual records
-			for key, expectedRecord := range expectedRecordsMap {
-				if !strings.HasSuffix(expectedRecord.FQDN, zoneName) {
-					continue
-				}
+	wg.Wait()
+	return findings.discrepancies, findings.successful, findings.missing
+}
 
-				actualRR, exists := actualRecordsMap[key]
-				if !exists {
-					// Record missing in DNS
-					discrepancy := Discrepancy{
-						FQDN:        expectedRecord.FQDN,
-						RecordType:  expectedRecord.Type,
-						ZoneName:    zoneName,
-						Expected:    expectedRecord.Value,
-						Actual:      "",
-						ExpectedTTL: expectedRecord.ZoneDefaultTTL,
-						Server:      server,
-						Message:     "Record missing in DNS",
-					}
-					discrepanciesChan <- discrepancy
-					continue
-				}
+// compareZoneRecords compares NetBox's expected records for a zone with the
+// records a source holds for it, such as an AXFR or a resolver cache dump.
+// Records from a cache carry remaining TTLs, so a TTL below the expected one is
+// not a mismatch, and an expected record absent from the cache is not missing.
+func compareZoneRecords(
+	zoneName, server string,
+	actualRRs []dns.RR,
+	expectedRecordsMap map[string]Record,
+	fromCache bool,
+	logger log.Logger,
+	opts ValidationOptions,
+) ([]Discrepancy, []ValidationRecord, []MissingRecord) {
+	var discrepancies []Discrepancy
+	var successfulValidations []ValidationRecord
+	var missingRecords []MissingRecord
 
-				// Compare values and TTLs
-				match, ttlMismatch := compareRecord(expectedRecord, actualRR)
-				if !match || ttlMismatch {
-					discrepancy := Discrepancy{
-						FQDN:        expectedRecord.FQDN,
-						RecordType:  expectedRecord.Type,
-						ZoneName:    zoneName,
-						Expected:    expectedRecord.Value,
-						Actual:      extractRRValue(actualRR),
-						ExpectedTTL: expectedRecord.ZoneDefaultTTL,
-						ActualTTL:   int(actualRR.Header().Ttl),
-						Server:      server,
-						Message:     "Record mismatch",
-					}
-					discrepanciesChan <- discrepancy
-					continue
-				}
+	// Build actual records map
+	actualRecordsMap := make(map[string]dns.RR)
+	for _, rr := range actualRRs {
+		fqdnType := fmt.Sprintf("%s|%s", rr.Header().Name, dns.TypeToString[rr.Header().Rrtype])
+		actualRecordsMap[fqdnType] = rr
+	}
 
-				if recordSuccessful {
-					validationRecord := ValidationRecord{
-						FQDN:        expectedRecord.FQDN,
-						RecordType:  expectedRecord.Type,
-						ZoneName:    zoneName,
-						Expected:    expectedRecord.Value,
-						Actual:      extractRRValue(actualRR),
-						ExpectedTTL: expectedRecord.ZoneDefaultTTL,
-						ActualTTL:   int(actualRR.Header().Ttl),
-						Server:      server,
-						Message:     "Record validated successfully",
-					}
-					successfulChan <- validationRecord
-				}
-			}
+	// Compare expected and actual records
+	for key, expectedRecord := range expectedRecordsMap {
+		if !recordInZone(expectedRecord, zoneName) {
+			continue
+		}
 
-			// Identify extra records in DNS not present in NetBox
-			for key, rr := range actualRecordsMap {
-				if _, exists := expectedRecordsMap[key]; !exists {
-					level.Warn(logger).Log("msg", "Extra record found in DNS not present in NetBox", "fqdn", rr.Header().Name, "type", dns.TypeToString[rr.Header().Rrtype])
-					missingRecord := MissingRecord{
-						FQDN:       rr.Header().Name,
-						RecordType: dns.TypeToString[rr.Header().Rrtype],
-						ZoneName:   zoneName,
-						Value:      extractRRValue(rr),
-						TTL:        int(rr.Header().Ttl),
-						Server:     server,
-					}
-					missingChan <- missingRecord
-				}
+		actualRR, exists := actualRecordsMap[key]
+		if !exists && fromCache {
+			level.Debug(logger).Log("msg", "Record not cached", "fqdn", expectedRecord.FQDN, "type", expectedRecord.Type)
+			continue
+		}
+		if !exists {
+			// Record missing in DNS
+			discrepancy := Discrepancy{
+				FQDN:        expectedRecord.FQDN,
+				RecordType:  expectedRecord.Type,
+				ZoneName:    zoneName,
+				Expected:    expectedRecord.Value,
+				Actual:      "",
+				ExpectedTTL: expectedRecord.ZoneDefaultTTL,
+				Server:      server,
+				Message:     "Record missing in DNS",
+				Category:    CategoryMissing,
+				Description: expectedRecord.Description,
 			}
+			opts.Comparisons.record(Comparison{
+				FQDN:        expectedRecord.FQDN,
+				RecordType:  expectedRecord.Type,
+				ZoneName:    zoneName,
+				Server:      server,
+				Expected:    []string{expectedRecord.Value},
+				Actual:      []string{},
+				ExpectedTTL: expectedRecord.ZoneDefaultTTL,
+				Outcome:     CategoryMissing,
+				Message:     discrepancy.Message,
+			})
+			discrepancies = append(discrepancies, discrepancy)
+			continue
+		}
 
-		}(zoneName, zone)
-	}
+		// A cached record's TTL counts down from the TTL it was served with
+		if fromCache && int(actualRR.Header().Ttl) <= expectedRecord.ZoneDefaultTTL {
+			actualRR = dns.Copy(actualRR)
+			actualRR.Header().Ttl = uint32(expectedRecord.ZoneDefaultTTL)
+		}
 
-	wg.Wait()
-	close(discrepanciesChan)
-	close(successfulChan)
-	close(missingChan)
+		// Compare values and TTLs
+		match, ttlMismatch := compareRecord(expectedRecord, actualRR, opts)
+		comparison := Comparison{
+			FQDN:        expectedRecord.FQDN,
+			RecordType:  expectedRecord.Type,
+			ZoneName:    zoneName,
+			Server:      server,
+			Expected:    []string{expectedRecord.Value},
+			Actual:      []string{extractRRValue(actualRR)},
+			ExpectedTTL: expectedRecord.ZoneDefaultTTL,
+			ActualTTL:   int(actualRR.Header().Ttl),
+			Outcome:     OutcomeMatch,
+		}
+		switch {
+		case !match:
+			comparison.Outcome = CategoryMismatch
+		case ttlMismatch:
+			comparison.Outcome = CategoryTTLDrift
+		case comparison.ExpectedTTL != comparison.ActualTTL:
+			comparison.Outcome = OutcomeNearMiss
+		}
+		opts.Comparisons.record(comparison)
+		if !match || ttlMismatch {
+			category := CategoryMismatch
+			if match {
+				category = CategoryTTLDrift
+			}
+			discrepancy := Discrepancy{
+				FQDN:        expectedRecord.FQDN,
+				RecordType:  expectedRecord.Type,
+				ZoneName:    zoneName,
+				Expected:    expectedRecord.Value,
+				Actual:      extractRRValue(actualRR),
+				ExpectedTTL: expectedRecord.ZoneDefaultTTL,
+				ActualTTL:   int(actualRR.Header().Ttl),
+				Server:      server,
+				Message:     "Record mismatch",
+				Category:    category,
+				Description: expectedRecord.Description,
+			}
+			discrepancies = append(discrepancies, discrepancy)
+			continue
+		}
 
-	var allDiscrepancies []Discrepancy
-	for d := range discrepanciesChan {
-		allDiscrepancies = append(allDiscrepancies, d)
+		nearMiss := expectedRecord.ZoneDefaultTTL != int(actualRR.Header().Ttl)
+		if opts.RecordSuccessful || (nearMiss && opts.RecordNearMisses) {
+			validationRecord := ValidationRecord{
+				FQDN:        expectedRecord.FQDN,
+				RecordType:  expectedRecord.Type,
+				ZoneName:    zoneName,
+				Expected:    expectedRecord.Value,
+				Actual:      extractRRValue(actualRR),
+				ExpectedTTL: expectedRecord.ZoneDefaultTTL,
+				ActualTTL:   int(actualRR.Header().Ttl),
+				Server:      server,
+				Message:     "Record validated successfully",
+			}
+			if nearMiss {
+				validationRecord.Message = "Record validated within TTL tolerance (near-miss)"
+				validationRecord.NearMiss = true
+			}
+			successfulValidations = append(successfulValidations, validationRecord)
+		}
 	}
 
-	var successfulValidations []ValidationRecord
-	for v := range successfulChan {
-		successfulValidations = append(successfulValidations, v)
+	// Flag values that must never be served, whatever NetBox expects, in every
+	// RR of a set rather than the one kept per name and type
+	for _, rr := range actualRRs {
+		discrepancies = append(discrepancies, opts.Denylist.forbiddenValues(rr.Header().Name, dns.TypeToString[rr.Header().Rrtype], zoneName, server, []string{extractRRValue(rr)}, opts.severeFindingLogger(logger))...)
 	}
 
-	var missingRecords []MissingRecord
-	for m := range missingChan {
-		missingRecords = append(missingRecords, m)
+	// Identify extra records in DNS not present in NetBox
+	for key, rr := range actualRecordsMap {
+		if !opts.FQDNFilter.matches(rr.Header().Name) {
+			continue
+		}
+		if _, exists := expectedRecordsMap[key]; !exists {
+			level.Warn(logger).Log("msg", "Extra record found in DNS not present in NetBox", "fqdn", rr.Header().Name, "type", dns.TypeToString[rr.Header().Rrtype])
+			missingRecord := MissingRecord{
+				FQDN:       rr.Header().Name,
+				RecordType: dns.TypeToString[rr.Header().Rrtype],
+				ZoneName:   zoneName,
+				Value:      extractRRValue(rr),
+				TTL:        int(rr.Header().Ttl),
+				Server:     server,
+			}
+			missingRecords = append(missingRecords, missingRecord)
+		}
 	}
 
-	return allDiscrepancies, successfulValidations, missingRecords
+	return discrepancies, successfulValidations, missingRecords
 }
 
 // compareRecord compares an expected Record from NetBox with an actual dns.RR from DNS.
-func compareRecord(expected Record, actualRR dns.RR) (match bool, ttlMismatch bool) {
-	expectedValue := expected.Value
-	actualValue := extractRRValue(actualRR)
+// TTLs within opts.TTLTolerance seconds of each other are not a mismatch.
+func compareRecord(expected Record, actualRR dns.RR, opts ValidationOptions) (match bool, ttlMismatch bool) {
+	recordType := strings.ToUpper(expected.Type)
+	expectedValue := normalizeExpectedValue(recordType, expected.Value, expected.ZoneName)
+	actualValue := comparableRRValue(actualRR)
 
-	match = strings.EqualFold(strings.TrimSpace(expectedValue), strings.TrimSpace(actualValue))
-	ttlMismatch = expected.ZoneDefaultTTL != int(actualRR.Header().Ttl)
+	match = opts.valuesMatch(recordType, []string{expectedValue}, []string{actualValue})
+	ttlMismatch = !ttlWithinTolerance(expected.ZoneDefaultTTL, int(actualRR.Header().Ttl), opts.TTLTolerance)
 
 	return match, ttlMismatch
 }
 
+// ownsRRset reports whether rr belongs to the RRset of the given type owned by name.
+func ownsRRset(rr dns.RR, name string, qtype uint16) bool {
+	return rr.Header().Rrtype == qtype && strings.EqualFold(dns.Fqdn(rr.Header().Name), dns.Fqdn(name))
+}
+
+// isReferral reports whether resp is a non-authoritative referral delegating
+// name: NS records in the authority section, usually with glue in the
+// additional section, rather than an answer.
+func isReferral(resp *dns.Msg, name string) bool {
+	if resp.Authoritative {
+		return false
+	}
+	for _, rr := range resp.Ns {
+		if rr.Header().Rrtype == dns.TypeNS && dns.IsSubDomain(dns.Fqdn(rr.Header().Name), dns.Fqdn(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// unexpectedCNAME reports the target of a CNAME owned by the queried name when
+// NetBox expects a record of another type there.
+func unexpectedCNAME(key RecordKey, resp *dns.Msg) (string, bool) {
+	if key.RecordType == "CNAME" {
+		return "", false
+	}
+	for _, ans := range resp.Answer {
+		if cname, ok := ans.(*dns.CNAME); ok && strings.EqualFold(dns.Fqdn(cname.Hdr.Name), dns.Fqdn(key.FQDN)) {
+			return cname.Target, true
+		}
+	}
+	return "", false
+}
+
 // extractRRValue extracts the value from a dns.RR record.
 func extractRRValue(rr dns.RR) string {
 	switch r := rr.(type) {
@@ -533,10 +825,80 @@ func extractRRValue(rr dns.RR) string {
 	case *dns.NS:
 		return r.Ns
 	case *dns.PTR:
-		return r.Ptr
+		return dns.Fqdn(r.Ptr)
+	case *dns.MX:
+		return fmt.Sprintf("%d %s", r.Preference, r.Mx)
+	case *dns.SRV:
+		return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target)
 	case *dns.TXT:
 		return strings.Join(r.Txt, " ")
+	case *dns.DS:
+		return fmt.Sprintf("%d %d %d %s", r.KeyTag, r.Algorithm, r.DigestType, strings.ToUpper(r.Digest))
+	case *dns.DNSKEY:
+		return fmt.Sprintf("%d %d %d %s", r.Flags, r.Protocol, r.Algorithm, r.PublicKey)
+	case *dns.CERT:
+		return fmt.Sprintf("%d %d %d %s", r.Type, r.KeyTag, r.Algorithm, r.Certificate)
+	case *dns.OPENPGPKEY:
+		return r.PublicKey
+	case *dns.TLSA:
+		return fmt.Sprintf("%d %d %d %s", r.Usage, r.Selector, r.MatchingType, strings.ToUpper(r.Certificate))
+	case *dns.SMIMEA:
+		return fmt.Sprintf("%d %d %d %s", r.Usage, r.Selector, r.MatchingType, strings.ToUpper(r.Certificate))
 	default:
 		return ""
 	}
 }
+
+// normalizeExpectedValue qualifies relative target names in a NetBox record value
+// so it can be compared with the fully-qualified names returned by DNS, and
+// unescapes TXT character-strings. PTR targets name hosts outside the reverse
+// zone, so they are always taken as absolute.
+func normalizeExpectedValue(recordType, value, zoneName string) string {
+	switch recordType {
+	case "CNAME":
+		return qualifyName(value, zoneName)
+	case "PTR":
+		return dns.Fqdn(strings.TrimSpace(value))
+	case "MX":
+		// Format: preference exchange
+		parts := strings.Fields(value)
+		if len(parts) == 2 {
+			return parts[0] + " " + qualifyName(parts[1], zoneName)
+		}
+	case "SRV":
+		// Format: priority weight port target
+		parts := strings.Fields(value)
+		if len(parts) == 4 {
+			return strings.Join(parts[:3], " ") + " " + qualifyName(parts[3], zoneName)
+		}
+	case "TXT":
+		return normalizeTXT(value)
+	case "DS":
+		return normalizeDSValue(value)
+	case "DNSKEY":
+		return normalizeDNSKEYValue(value)
+	case "CERT":
+		return normalizeCERTValue(value)
+	case "OPENPGPKEY":
+		return normalizeBase64Value(value)
+	case "TLSA", "SMIMEA":
+		return normalizeCertAssociationValue(value)
+	}
+	return value
+}
+
+// qualifyName appends the zone name to a relative (unqualified) name and expands
+// "@" to the zone apex, as a zone file would.
+func qualifyName(name, zoneName string) string {
+	zoneName = strings.TrimRight(zoneName, ".")
+	if name == "@" {
+		return zoneName + "."
+	}
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	if zoneName != "" {
+		return name + "." + zoneName + "."
+	}
+	return name + "."
+}
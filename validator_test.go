@@ -0,0 +1,323 @@
+// validator_test.go
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/miekg/dns"
+)
+
+// testRecord returns an active NetBox record in zone example.com, view default.
+func testRecord(name, recordType, value string, ttl int) Record {
+	fqdn := "example.com."
+	if name != "@" {
+		fqdn = name + ".example.com."
+	}
+	return Record{
+		Name:           name,
+		FQDN:           fqdn,
+		Type:           recordType,
+		Value:          value,
+		TTL:            &ttl,
+		ZoneName:       "example.com",
+		ViewName:       "default",
+		ZoneDefaultTTL: 3600,
+	}
+}
+
+// validateOn validates records against the single server at addr and returns
+// the findings, failing the test if validation blocks.
+func validateOn(t *testing.T, addr string, records []Record, opts ValidationOptions) []Discrepancy {
+	t.Helper()
+	opts.Query.Retries = 1
+	zonesByName := map[string]Zone{zoneViewKey("example.com", "default"): {Name: "example.com", DefaultTTL: 3600}}
+	zoneViewToNameservers := map[string][]string{zoneViewKey("example.com", "default"): {addr}}
+
+	var discrepancies []Discrepancy
+	finishWithin(t, 10*time.Second, func() {
+		discrepancies, _ = validateAllRecords(records, []string{addr}, log.NewNopLogger(), zoneViewToNameservers, "", "", zonesByName, opts)
+	})
+	return discrepancies
+}
+
+// categories counts discrepancies by category.
+func categories(discrepancies []Discrepancy) map[string]int {
+	counts := make(map[string]int)
+	for _, d := range discrepancies {
+		counts[d.Category]++
+	}
+	return counts
+}
+
+func TestValidateAllRecordsSeveralFindingsPerServer(t *testing.T) {
+	const addr = "127.0.53.1"
+	serveZone(t, addr, newTestZone(t, "example.com",
+		"www 3600 IN A 192.0.2.66",
+		"www 3600 IN A 192.0.2.67",
+	))
+
+	// A forbidden value that also mismatches gives two findings for one record on one server
+	opts := ValidationOptions{Denylist: Denylist{{Type: "A", Value: "192.0.2.66"}, {Type: "A", Value: "192.0.2.67"}}}
+	got := categories(validateOn(t, addr, []Record{testRecord("www", "A", "192.0.2.1", 3600)}, opts))
+	if got[CategoryForbiddenValue] != 2 || got[CategoryMismatch] != 1 {
+		t.Errorf("got findings %v, want 2 %s and 1 %s", got, CategoryForbiddenValue, CategoryMismatch)
+	}
+}
+
+func TestValidateAllRecordsAnswerCountAndMismatch(t *testing.T) {
+	const addr = "127.0.53.2"
+	serveZone(t, addr, newTestZone(t, "example.com",
+		"www 3600 IN A 192.0.2.10",
+		"www 3600 IN A 192.0.2.11",
+	))
+
+	// A wrong number of records that are also the wrong ones gives two findings
+	opts := ValidationOptions{AnswerPolicies: AnswerPolicies{{FQDN: "www.example.com", ExpectedCount: 1}}}
+	got := categories(validateOn(t, addr, []Record{testRecord("www", "A", "192.0.2.1", 3600)}, opts))
+	if got[CategoryAnswerCount] != 1 || got[CategoryMismatch] != 1 {
+		t.Errorf("got findings %v, want 1 %s and 1 %s", got, CategoryAnswerCount, CategoryMismatch)
+	}
+}
+
+func TestValidateAllRecordsInconsistentTTLAndMismatch(t *testing.T) {
+	const addr = "127.0.53.3"
+	serveZone(t, addr, newTestZone(t, "example.com",
+		"www 3600 IN A 192.0.2.66",
+	))
+
+	// Differing TTLs in NetBox are reported once per RRset, on top of each server's findings
+	opts := ValidationOptions{CheckRRsetTTLs: true, Denylist: Denylist{{Type: "A", Value: "192.0.2.66"}}}
+	records := []Record{testRecord("www", "A", "192.0.2.1", 300), testRecord("www", "A", "192.0.2.2", 600)}
+	got := categories(validateOn(t, addr, records, opts))
+	if got[CategoryInconsistentTTL] != 1 || got[CategoryForbiddenValue] != 1 || got[CategoryMismatch] != 1 {
+		t.Errorf("got findings %v, want 1 %s, 1 %s and 1 %s", got, CategoryInconsistentTTL, CategoryForbiddenValue, CategoryMismatch)
+	}
+}
+
+// transferFrom validates records with a zone transfer of example.com from the
+// server at addr, failing the test if validation blocks.
+func transferFrom(t *testing.T, addr string, records []Record, opts ValidationOptions) []Discrepancy {
+	t.Helper()
+	opts.Query.Retries = 1
+	zonesByName := map[string]Zone{zoneViewKey("example.com", "default"): {Name: "example.com", DefaultTTL: 3600}}
+	zoneViewToNameservers := map[string][]string{zoneViewKey("example.com", "default"): {addr}}
+
+	var discrepancies []Discrepancy
+	finishWithin(t, 10*time.Second, func() {
+		discrepancies, _, _ = validateAllRecordsAXFR(records, []string{addr}, log.NewNopLogger(), zoneViewToNameservers, "", "", zonesByName, "", opts)
+	})
+	return discrepancies
+}
+
+func TestValidateAllRecordsAXFRBrokenNSECChain(t *testing.T) {
+	const addr = "127.0.53.4"
+	serveZone(t, addr, newTestZone(t, "example.com",
+		"@ 3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 3600",
+		"www 3600 IN A 192.0.2.1",
+		"a 3600 IN A 192.0.2.2",
+		"b 3600 IN A 192.0.2.3",
+		"@ 3600 IN NSEC zzz.example.com. A SOA NSEC",
+	))
+
+	// A broken chain reports far more findings than NetBox has records in the zone
+	got := categories(transferFrom(t, addr, []Record{testRecord("www", "A", "192.0.2.1", 3600)}, ValidationOptions{CheckNSEC: true}))
+	if got[CategoryNSECChain] < 2 {
+		t.Errorf("got findings %v, want at least 2 %s", got, CategoryNSECChain)
+	}
+}
+
+func TestValidateAllRecordsAXFRCrossCheckMismatches(t *testing.T) {
+	const addr = "127.0.53.5"
+	zone := newTestZone(t, "example.com",
+		"@ 3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 3600",
+		"www 3600 IN A 192.0.2.1",
+	)
+	// The server transfers names it doesn't answer for
+	zone.transfer = newTestZone(t, "example.com",
+		"@ 3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 3600",
+		"www 3600 IN A 192.0.2.1",
+		"a 3600 IN TXT stale",
+		"b 3600 IN TXT stale",
+		"c 3600 IN TXT stale",
+	).rrs
+	serveZone(t, addr, zone)
+
+	got := categories(transferFrom(t, addr, []Record{testRecord("www", "A", "192.0.2.1", 3600)}, ValidationOptions{CrossCheckSample: 100}))
+	if got[CategoryTransferMismatch] != 3 {
+		t.Errorf("got findings %v, want 3 %s", got, CategoryTransferMismatch)
+	}
+}
+
+func TestPTRTargetsMatch(t *testing.T) {
+	tests := []struct {
+		netbox, server string
+	}{
+		{"host.example.com.", "host.example.com."},
+		{"host.example.com", "host.example.com."},
+		{" host.example.com ", "host.example.com."},
+		{"Host.Example.COM", "host.example.com."},
+		{"host.example.com.", "HOST.example.com."},
+	}
+	for _, tt := range tests {
+		rr, err := dns.NewRR("1.2.0.192.in-addr.arpa. 3600 IN PTR " + tt.server)
+		if err != nil {
+			t.Fatalf("invalid test record: %v", err)
+		}
+		expected := []string{normalizeExpectedValue("PTR", tt.netbox, "2.0.192.in-addr.arpa")}
+		actual := []string{comparableRRValue(rr)}
+		if !(ValidationOptions{}).valuesMatch("PTR", expected, actual) {
+			t.Errorf("NetBox PTR %q doesn't match server PTR %q: compared %q with %q", tt.netbox, tt.server, expected, actual)
+		}
+	}
+
+	// A different host, or the same name relative to the reverse zone, must not match
+	rr, _ := dns.NewRR("1.2.0.192.in-addr.arpa. 3600 IN PTR host.example.com.")
+	for _, netbox := range []string{"other.example.com.", "host"} {
+		expected := []string{normalizeExpectedValue("PTR", netbox, "2.0.192.in-addr.arpa")}
+		if (ValidationOptions{}).valuesMatch("PTR", expected, []string{comparableRRValue(rr)}) {
+			t.Errorf("NetBox PTR %q matches server PTR %q", netbox, rr.(*dns.PTR).Ptr)
+		}
+	}
+
+	// Case is only significant with --case-sensitive
+	expected := []string{normalizeExpectedValue("PTR", "Host.Example.com", "2.0.192.in-addr.arpa")}
+	if (ValidationOptions{CaseSensitive: true}).valuesMatch("PTR", expected, []string{comparableRRValue(rr)}) {
+		t.Errorf("PTR targets differing in case match with CaseSensitive")
+	}
+}
+
+func TestValidateAllRecordsMissingRecordCategories(t *testing.T) {
+	const addr = "127.0.53.7"
+	zone := newTestZone(t, "example.com",
+		"www 3600 IN A 192.0.2.1",
+		"mail 3600 IN MX 10 mx.example.net.",
+	)
+	zone.rcodes = map[uint16]int{dns.TypeSRV: dns.RcodeServerFailure, dns.TypeTXT: dns.RcodeRefused}
+	serveZone(t, addr, zone)
+
+	tests := []struct {
+		name   string
+		record Record
+		want   string
+	}{
+		// NXDOMAIN: the name doesn't exist at all
+		{"name absent", testRecord("nothere", "A", "192.0.2.2", 3600), CategoryNXDOMAIN},
+		// NOERROR with no answer: the name exists, the type doesn't
+		{"type absent", testRecord("mail", "AAAA", "2001:db8::1", 3600), CategoryNoData},
+		// Any other rcode is the server failing to answer, not a missing record
+		{"server failure", testRecord("www", "SRV", "0 5 5060 sip.example.com.", 3600), CategoryQueryError},
+		{"refused", testRecord("www", "TXT", "v=spf1 -all", 3600), CategoryQueryError},
+	}
+	for _, tt := range tests {
+		got := categories(validateOn(t, addr, []Record{tt.record}, ValidationOptions{}))
+		if len(got) != 1 || got[tt.want] != 1 {
+			t.Errorf("%s: got findings %v, want 1 %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAllRecordsAXFRLeavesChildZones(t *testing.T) {
+	const addr = "127.0.53.9"
+	serveZone(t, addr, newTestZone(t, "example.com",
+		"@ 3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 3600",
+		"www 3600 IN A 192.0.2.1",
+		"mail 3600 IN A 192.0.2.2",
+	))
+
+	// host.sub.example.com is in its own zone, which isn't in the parent's transfer
+	child := testRecord("host.sub", "A", "192.0.2.9", 3600)
+	child.ZoneName = "sub.example.com"
+	records := []Record{testRecord("www", "A", "192.0.2.1", 3600), testRecord("mail", "A", "192.0.2.2", 3600), child}
+
+	summaries := newAXFRSummaries()
+	got := transferFrom(t, addr, records, ValidationOptions{AXFRSummaries: summaries})
+	if len(got) != 0 {
+		t.Errorf("got findings %v, want none", categories(got))
+	}
+	all := summaries.all()
+	if len(all) != 1 {
+		t.Fatalf("got summaries %+v, want one for example.com", all)
+	}
+	for _, c := range all[0].Types {
+		if c.Type == "A" && c != (AXFRTypeCount{Type: "A", Matched: 2}) {
+			t.Errorf("got A counts %+v, want 2 matched", c)
+		}
+	}
+}
+
+func TestValidateAllRecordsAXFRForbiddenValueInRRset(t *testing.T) {
+	const addr = "127.0.57.7"
+	serveZone(t, addr, newTestZone(t, "example.com",
+		"@ 3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 3600",
+		"www 3600 IN A 192.0.2.66",
+		"www 3600 IN A 192.0.2.1",
+	))
+
+	// The decommissioned address is one of two in the set, and not the last transferred
+	opts := ValidationOptions{Denylist: Denylist{{Type: "A", Value: "192.0.2.66"}}}
+	got := categories(transferFrom(t, addr, []Record{testRecord("www", "A", "192.0.2.1", 3600)}, opts))
+	if got[CategoryForbiddenValue] != 1 {
+		t.Errorf("got findings %v, want 1 %s", got, CategoryForbiddenValue)
+	}
+}
+
+func TestAXFRServers(t *testing.T) {
+	// --primary-only left only the SOA MName of example.com in the internal view
+	zoneViewToNameservers := map[string][]string{
+		zoneViewKey("example.com", "internal"): {"ns2.example.com"},
+		zoneViewKey("example.com", "external"): {"ns3.example.com", "ns2.example.com"},
+		zoneViewKey("example.org", "internal"): {"ns1.example.com"},
+	}
+	tests := []struct {
+		viewFilter string
+		want       []string
+	}{
+		{"", []string{"ns3.example.com", "ns2.example.com"}},
+		{"internal", []string{"ns2.example.com"}},
+		{"other", nil},
+	}
+	for _, tt := range tests {
+		got := axfrServers("example.com", tt.viewFilter, zoneViewToNameservers)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("view filter %q: got servers %v, want %v", tt.viewFilter, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAllRecordsAXFRSkipsServersDenyingAuthority(t *testing.T) {
+	const lame, authoritative = "127.0.57.8", "127.0.57.9"
+	stale := newTestZone(t, "example.com",
+		"@ 3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 3600",
+		"www 3600 IN A 192.0.2.99",
+	)
+	stale.rcodes = map[uint16]int{dns.TypeSOA: dns.RcodeRefused}
+	serveZone(t, lame, stale)
+	serveZone(t, authoritative, newTestZone(t, "example.com",
+		"@ 3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 3600",
+		"www 3600 IN A 192.0.2.1",
+	))
+
+	// NetBox lists the server denying authority first
+	zone := Zone{Name: "example.com", View: &View{Name: "default"}, DefaultTTL: 3600}
+	zoneViewToNameservers := map[string][]string{zoneViewKey("example.com", "default"): {lame, authoritative}}
+	opts := ValidationOptions{Query: QueryOptions{Retries: 1}, AXFRSummaries: newAXFRSummaries()}
+	restricted, denied := restrictToAuthoritative(zoneViewToNameservers, map[int]Zone{1: zone}, "", "", log.NewNopLogger(), opts)
+	if len(denied) != 1 {
+		t.Fatalf("got %d servers denying authority, want 1", len(denied))
+	}
+
+	var discrepancies []Discrepancy
+	finishWithin(t, 10*time.Second, func() {
+		zonesByName := map[string]Zone{zoneViewKey("example.com", "default"): zone}
+		discrepancies, _, _ = validateAllRecordsAXFR([]Record{testRecord("www", "A", "192.0.2.1", 3600)}, []string{lame, authoritative}, log.NewNopLogger(), restricted, "", "", zonesByName, "", opts)
+	})
+	if len(discrepancies) != 0 {
+		t.Errorf("got findings %v, want none", categories(discrepancies))
+	}
+	if all := opts.AXFRSummaries.all(); len(all) != 1 || all[0].Server != authoritative {
+		t.Errorf("got transfers %+v, want one from %s", all, authoritative)
+	}
+}
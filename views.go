@@ -0,0 +1,100 @@
+// views.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// ViewServerGroup names the servers that serve a NetBox view in a split-horizon
+// setup.
+type ViewServerGroup struct {
+	View    string   `mapstructure:"view"`
+	Servers []string `mapstructure:"servers"`
+}
+
+// ViewServers maps views to server groups. It is the fallback for zones whose
+// nameservers NetBox doesn't tie to a view, e.g. when the plugin leaves the
+// view of the zones nested in nameservers empty.
+type ViewServers []ViewServerGroup
+
+// validate checks that every group names a view and at least one server.
+func (v ViewServers) validate() error {
+	for i, group := range v {
+		if group.View == "" {
+			return fmt.Errorf("view server group %d: no view", i+1)
+		}
+		if len(group.Servers) == 0 {
+			return fmt.Errorf("view server group %d (%s): no servers", i+1, group.View)
+		}
+	}
+	return nil
+}
+
+// serversFor returns the servers configured for view.
+func (v ViewServers) serversFor(view string) []string {
+	for _, group := range v {
+		if strings.EqualFold(group.View, view) {
+			return group.Servers
+		}
+	}
+	return nil
+}
+
+// applyViewServers fills in the servers of each zone that has none in its view
+// from the configured view server groups, and returns how many zones were
+// filled in. Zones NetBox already maps to nameservers are left alone.
+func applyViewServers(zoneViewToNameservers map[string][]string, zonesMap map[int]Zone, viewServers ViewServers, logger log.Logger) int {
+	if len(viewServers) == 0 {
+		return 0
+	}
+
+	filled := 0
+	for _, zone := range zonesMap {
+		if zone.View == nil {
+			continue
+		}
+		key := zoneViewKey(zone.Name, zone.View.Name)
+		if len(zoneViewToNameservers[key]) > 0 {
+			continue
+		}
+		servers := viewServers.serversFor(zone.View.Name)
+		if len(servers) == 0 {
+			continue
+		}
+		level.Debug(logger).Log("msg", "Using configured view servers for zone", "zone", zone.Name, "view", zone.View.Name, "servers", strings.Join(servers, ","))
+		zoneViewToNameservers[key] = append([]string(nil), servers...)
+		filled++
+	}
+	return filled
+}
+
+// defaultViewName returns the name of the view NetBox marks as the default one,
+// as found on the views of zonesMap.
+func defaultViewName(zonesMap map[int]Zone) (string, error) {
+	names := make(map[string]bool)
+	for _, zone := range zonesMap {
+		if zone.View != nil && zone.View.DefaultView {
+			names[zone.View.Name] = true
+		}
+	}
+
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("no zone is in a view marked as the default view")
+	case 1:
+		for name := range names {
+			return name, nil
+		}
+	}
+	var list []string
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return "", fmt.Errorf("several views are marked as the default view: %s", strings.Join(list, ", "))
+}
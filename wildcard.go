@@ -0,0 +1,77 @@
+// wildcard.go
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// wildcardIndex holds the expected values of the wildcard records NetBox
+// defines, keyed like the records they were grouped under.
+type wildcardIndex map[RecordKey][]string
+
+// buildWildcardIndex collects the wildcard RRsets among expectedRecords.
+func buildWildcardIndex(expectedRecords map[RecordKey][]Record) wildcardIndex {
+	wildcards := make(wildcardIndex)
+	for key, records := range expectedRecords {
+		if !strings.HasPrefix(key.FQDN, "*.") {
+			continue
+		}
+		for _, record := range records {
+			wildcards[key] = append(wildcards[key], normalizeExpectedValue(key.RecordType, record.Value, record.ZoneName))
+		}
+	}
+	return wildcards
+}
+
+// covering returns the nearest wildcard of recordType above key's name within
+// its zone and view, such as *.example.com for www.example.com.
+func (w wildcardIndex) covering(key RecordKey, recordType string) (RecordKey, []string, bool) {
+	if len(w) == 0 || strings.HasPrefix(key.FQDN, "*.") {
+		return RecordKey{}, nil, false
+	}
+	labels := dns.SplitDomainName(key.FQDN)
+	for i := 1; i < len(labels); i++ {
+		parent := dns.Fqdn(strings.Join(labels[i:], "."))
+		if !inZone(parent, key.ZoneName) {
+			break
+		}
+		wildcardKey := RecordKey{FQDN: "*." + parent, RecordType: recordType, ZoneName: key.ZoneName, ViewName: key.ViewName}
+		if values, ok := w[wildcardKey]; ok {
+			return wildcardKey, values, true
+		}
+	}
+	return RecordKey{}, nil, false
+}
+
+// markShadowed reclassifies the discrepancies for an explicit record whose
+// answer is the covering wildcard's rather than the record's own, which means
+// the server doesn't know the explicit name and the wildcard shadows it.
+func (w wildcardIndex) markShadowed(key RecordKey, discrepancies []Discrepancy, opts ValidationOptions, logger log.Logger) {
+	for i, d := range discrepancies {
+		recordType := key.RecordType
+		switch d.Category {
+		case CategoryMismatch:
+		case CategoryUnexpectedCNAME:
+			recordType = "CNAME"
+		default:
+			continue
+		}
+		actual, ok := d.Actual.([]string)
+		if !ok || len(actual) == 0 {
+			continue
+		}
+		wildcardKey, values, ok := w.covering(key, recordType)
+		if !ok || !opts.valuesMatch(recordType, values, actual) {
+			continue
+		}
+
+		level.Warn(logger).Log("msg", "Wildcard shadows explicit record", "fqdn", key.FQDN, "type", key.RecordType, "wildcard", wildcardKey.FQDN, "server", d.Server)
+		discrepancies[i].Category = CategoryWildcardShadow
+		discrepancies[i].Message = fmt.Sprintf("Server answered with wildcard %s %s instead of the explicit %s record", wildcardKey.FQDN, recordType, key.RecordType)
+	}
+}
@@ -0,0 +1,107 @@
+// zones.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+)
+
+// zoneViewName returns the name of the view a zone belongs to, or "" if NetBox
+// doesn't say.
+func zoneViewName(zone Zone) string {
+	if zone.View == nil {
+		return ""
+	}
+	return zone.View.Name
+}
+
+// buildZonesByName indexes zones by zoneViewKey(name, view), so each view of a
+// split-horizon zone keeps its own TTLs and SOA settings.
+func buildZonesByName(zonesMap map[int]Zone) map[string]Zone {
+	zonesByName := make(map[string]Zone)
+	for _, zone := range zonesMap {
+		zonesByName[zoneViewKey(zone.Name, zoneViewName(zone))] = zone
+	}
+	return zonesByName
+}
+
+// findDuplicateZones reports zones NetBox holds more than once in the same view,
+// of which only one would be used. A zone name shared by different views is a
+// normal split-horizon setup and only logged.
+func findDuplicateZones(zonesMap map[int]Zone, logger log.Logger) []Discrepancy {
+	byKey := make(map[string][]Zone)
+	views := make(map[string]map[string]bool)
+	for _, zone := range zonesMap {
+		key := zoneViewKey(zone.Name, zoneViewName(zone))
+		byKey[key] = append(byKey[key], zone)
+		if views[zone.Name] == nil {
+			views[zone.Name] = make(map[string]bool)
+		}
+		views[zone.Name][zoneViewName(zone)] = true
+	}
+
+	for name, zoneViews := range views {
+		if len(zoneViews) > 1 {
+			level.Debug(logger).Log("msg", "Zone exists in several views", "zone", name, "views", len(zoneViews))
+		}
+	}
+
+	var discrepancies []Discrepancy
+	for _, zones := range byKey {
+		if len(zones) < 2 {
+			continue
+		}
+
+		var ids []string
+		for _, zone := range zones {
+			ids = append(ids, fmt.Sprint(zone.ID))
+		}
+		sort.Strings(ids)
+
+		zone := zones[0]
+		level.Warn(logger).Log("msg", "Duplicate zone in NetBox", "zone", zone.Name, "view", zoneViewName(zone), "ids", strings.Join(ids, ","))
+		discrepancies = append(discrepancies, Discrepancy{
+			FQDN:       dns.Fqdn(zone.Name),
+			RecordType: "SOA",
+			ZoneName:   zone.Name,
+			Expected:   []string{"1 zone"},
+			Actual:     ids,
+			Message:    fmt.Sprintf("Zone %s is defined %d times in view %q in NetBox (IDs %s)", zone.Name, len(zones), zoneViewName(zone), strings.Join(ids, ", ")),
+			Category:   CategoryDuplicateZone,
+		})
+	}
+	return discrepancies
+}
+
+// nameserverZoneScope returns the zoneViewKey of every zone and view the given
+// nameservers serve, so --nameserver validates only what they are responsible for.
+func nameserverZoneScope(nameservers []Nameserver) map[string]bool {
+	scope := make(map[string]bool)
+	for _, ns := range nameservers {
+		for _, zone := range ns.Zones {
+			scope[zoneViewKey(zone.Name, zoneViewName(zone))] = true
+		}
+	}
+	return scope
+}
+
+// restrictToScope returns the records whose zone and view are in scope. A nil
+// scope keeps every record.
+func restrictToScope(records []Record, scope map[string]bool) []Record {
+	if scope == nil {
+		return records
+	}
+
+	var kept []Record
+	for _, record := range records {
+		if scope[zoneViewKey(record.ZoneName, record.ViewName)] {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}